@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-23 11:37:24
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 14:21:42
+* @Last Modified time: 2015-11-23 21:27:14
  */
 
 package main
@@ -28,6 +28,7 @@ import (
 
 	"github.com/ssoudan/edisonIsThePilot/alarm"
 	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/drivers/board"
 	"github.com/ssoudan/edisonIsThePilot/drivers/pwm"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 )
@@ -36,6 +37,13 @@ var log = logger.Log("alarmControl")
 
 func main() {
 
+	if conf.LoadError != nil {
+		log.Fatalf("Invalid configuration in %s -- exiting: %v", conf.File(), conf.LoadError)
+	}
+	if err := board.Setup(conf.Conf, conf.Pins); err != nil {
+		log.Fatalf("Invalid board -- exiting: %v", err)
+	}
+
 	panicChan := make(chan interface{})
 	go func() {
 		select {
@@ -47,7 +55,7 @@ func main() {
 	////////////////////////////////////////
 	// a nice and delicate alarm
 	////////////////////////////////////////
-	alarmPwm := func(pin byte, pwmId byte) *pwm.Pwm {
+	alarmPwm := func(pin byte, pwmId byte) pwm.Pwm {
 
 		// kill the process (via log.Fatal) in case we can't create the PWM
 		pwm, err := pwm.New(pwmId, pin)
@@ -69,7 +77,7 @@ func main() {
 		}
 
 		return pwm
-	}(conf.AlarmGpioPin, conf.AlarmGpioPWM)
+	}(conf.Pins.AlarmGpioPin, conf.Pins.AlarmGpioPWM)
 	// defer alarmPwm.Unexport() // Don't do that it can disable the alarms for the autopilot program
 
 	theAlarm := alarm.New(alarmPwm)
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-10-10 17:19:10
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-13 17:24:09
+* @Last Modified time: 2015-11-25 20:36:09
  */
 package main
 
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/drivers/board"
 	"github.com/ssoudan/edisonIsThePilot/drivers/sincos"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 )
@@ -34,7 +35,14 @@ var log = logger.Log("ap100Control")
 
 func main() {
 
-	compass := sincos.New(conf.I2CBus, conf.SinAddress, conf.CosAddress)
+	if conf.LoadError != nil {
+		log.Fatalf("Invalid configuration in %s -- exiting: %v", conf.File(), conf.LoadError)
+	}
+	if err := board.Setup(conf.Conf, conf.Pins); err != nil {
+		log.Fatalf("Invalid board -- exiting: %v", err)
+	}
+
+	compass := sincos.New(conf.Pins.I2CBus, conf.Pins.SinAddress, conf.Pins.CosAddress)
 
 	for i := uint16(0); i <= 360; i++ {
 		compass.UpdateCourse(i)
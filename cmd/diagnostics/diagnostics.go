@@ -0,0 +1,122 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-05 18:41:26
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-05 18:41:26
+ */
+
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/diagnostics"
+	"github.com/ssoudan/edisonIsThePilot/drivers/board"
+	"github.com/ssoudan/edisonIsThePilot/drivers/gpio"
+	"github.com/ssoudan/edisonIsThePilot/drivers/i2c"
+	"github.com/ssoudan/edisonIsThePilot/drivers/sysfs"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
+
+	"github.com/tarm/serial"
+)
+
+var log = logger.Log("diagnostics")
+
+// Version is the version of this code -- sets at compilation time
+var Version = "unknown"
+
+var interactive = flag.Bool("interactive", true, "ask the operator to confirm what the checks do - the motor is only jogged when interactive")
+var jsonReport = flag.String("json", "", "file where the JSON report is written - - for the standard output")
+var gpsPeriod = flag.Duration("gps-period", 10*time.Second, "duration of the reading of the GPS sentences")
+var fakeHardware = flag.Bool("fake-hardware", false, "drive an in-memory model of the sysfs gpio, the pwm of the PwmChip, the pinmux and the i2c instead of the hardware - the gpio of the cdev backend go through the sysfs model")
+var useDefaults = flag.Bool("defaults", false, "run with the default values when the configuration file is missing or can't be parsed")
+
+func main() {
+	flag.Parse()
+
+	if *useDefaults {
+		conf.UseDefaults()
+	}
+	if conf.LoadError != nil {
+		log.Fatalf("Invalid configuration in %s -- exiting: %v", conf.File(), conf.LoadError)
+	}
+	if err := board.Setup(conf.Conf, conf.Pins); err != nil {
+		log.Fatalf("Invalid board -- exiting: %v", err)
+	}
+	if *fakeHardware {
+		log.Warning("Using a fake hardware -- nothing will be driven")
+		sysfs.Set(sysfs.NewFake(int(conf.Pins.PwmChip)))
+		// the character devices can't be modelled
+		gpio.SetBackend(gpio.NewSysfs)
+		i2c.SetOpener(i2c.NewMock().Open)
+	}
+
+	var confirm diagnostics.Confirm
+	if *interactive {
+		confirm = diagnostics.NewConfirm(os.Stdin, os.Stdout)
+	}
+
+	openBus := func() (i2c.Bus, error) {
+		return i2c.Open(conf.Pins.I2CBus)
+	}
+	openGps := func() (io.ReadCloser, error) {
+		return serial.OpenPort(&serial.Config{Name: conf.Conf.GpsSerialPort, Baud: 9600})
+	}
+
+	report := diagnostics.Run(Version, conf.Conf.Board,
+		diagnostics.GpioExport(conf.Pins),
+		diagnostics.Leds(conf.Pins, confirm),
+		diagnostics.Alarm(conf.Pins, confirm),
+		diagnostics.MotorJog(func() (board.Actuator, error) { return board.NewActuator(conf.Conf, conf.Pins) }, confirm),
+		diagnostics.I2CProbe(openBus, diagnostics.Devices(conf.Pins)),
+		diagnostics.DacSweep(openBus, conf.Pins.SinAddress, conf.Pins.CosAddress, confirm),
+		diagnostics.GPS(openGps, *gpsPeriod),
+	)
+
+	if err := report.WriteText(os.Stdout); err != nil {
+		log.Error("Failed to write the report: %v", err)
+	}
+
+	switch *jsonReport {
+	case "":
+	case "-":
+		if err := report.WriteJSON(os.Stdout); err != nil {
+			log.Error("Failed to write the JSON report: %v", err)
+		}
+	default:
+		f, err := os.Create(*jsonReport)
+		if err != nil {
+			log.Fatalf("Failed to create the JSON report: %v", err)
+		}
+		if err := report.WriteJSON(f); err != nil {
+			log.Error("Failed to write the JSON report: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			log.Error("Failed to write the JSON report: %v", err)
+		}
+	}
+
+	if !report.Passed {
+		os.Exit(1)
+	}
+}
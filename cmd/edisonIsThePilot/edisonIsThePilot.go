@@ -18,12 +18,16 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-18 12:20:59
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 16:48:53
+* @Last Modified time: 2015-12-05 18:41:26
  */
 
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/ssoudan/edisonIsThePilot/alarm"
@@ -31,15 +35,21 @@ import (
 	"github.com/ssoudan/edisonIsThePilot/conf"
 	"github.com/ssoudan/edisonIsThePilot/control"
 	"github.com/ssoudan/edisonIsThePilot/dashboard"
+	"github.com/ssoudan/edisonIsThePilot/diagnostics"
+	"github.com/ssoudan/edisonIsThePilot/drivers/board"
 	"github.com/ssoudan/edisonIsThePilot/drivers/gpio"
-	"github.com/ssoudan/edisonIsThePilot/drivers/motor"
+	"github.com/ssoudan/edisonIsThePilot/drivers/i2c"
+	"github.com/ssoudan/edisonIsThePilot/drivers/ina219"
 	"github.com/ssoudan/edisonIsThePilot/drivers/pwm"
 	"github.com/ssoudan/edisonIsThePilot/drivers/sincos"
+	"github.com/ssoudan/edisonIsThePilot/drivers/sysfs"
 	"github.com/ssoudan/edisonIsThePilot/gps"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/pid"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/utils"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/webserver"
+	"github.com/ssoudan/edisonIsThePilot/keypad"
 	"github.com/ssoudan/edisonIsThePilot/pilot"
 	"github.com/ssoudan/edisonIsThePilot/steering"
 	"github.com/ssoudan/edisonIsThePilot/tracer"
@@ -50,9 +60,53 @@ var log = logger.Log("edisonIsThePilot")
 // Version is the version of this code -- sets at compilation time
 var Version = "unknown"
 
+var checkConfig = flag.Bool("check-config", false, "check the configuration file and exit")
+var useDefaults = flag.Bool("defaults", false, "run with the default values when the configuration file is missing or can't be parsed")
+var fakeHardware = flag.Bool("fake-hardware", false, "drive an in-memory model of the sysfs gpio, the pwm of the PwmChip, the pinmux and the i2c instead of the hardware - the gpio of the cdev backend go through the sysfs model")
+
+// checkConfiguration validates the configuration file and returns the exit code
+func checkConfiguration(file string) int {
+	_, err := conf.Load(file)
+	if problems, ok := err.(conf.ValidationError); ok {
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, problem)
+		}
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		return 1
+	}
+
+	fmt.Printf("%s: OK\n", file)
+	return 0
+}
+
 func main() {
+	flag.Parse()
+	if *checkConfig {
+		os.Exit(checkConfiguration(conf.File()))
+	}
+
 	log.Info("Starting -- version %s", Version)
 
+	if *useDefaults {
+		conf.UseDefaults()
+	}
+	if conf.LoadError != nil {
+		log.Fatalf("Version %v -- Invalid configuration in %s -- exiting: %v", Version, conf.File(), conf.LoadError)
+	}
+	if err := board.Setup(conf.Conf, conf.Pins); err != nil {
+		log.Fatalf("Invalid board -- exiting: %v", err)
+	}
+	if *fakeHardware {
+		log.Warning("Using a fake hardware -- nothing will be driven")
+		sysfs.Set(sysfs.NewFake(int(conf.Pins.PwmChip)))
+		// the character devices can't be modelled
+		gpio.SetBackend(gpio.NewSysfs)
+		i2c.SetOpener(i2c.NewMock().Open)
+	}
+
 	panicChan := make(chan interface{})
 	defer func() {
 		if r := recover(); r != nil {
@@ -65,7 +119,7 @@ func main() {
 		case m := <-panicChan:
 
 			// kill the process (via log.Fatal) in case we can't create the PWM
-			if pwm, err := pwm.New(conf.AlarmGpioPWM, conf.AlarmGpioPin); err == nil {
+			if pwm, err := pwm.New(conf.Pins.AlarmGpioPWM, conf.Pins.AlarmGpioPin); err == nil {
 				if !pwm.IsExported() {
 					err = pwm.Export()
 					if err != nil {
@@ -78,27 +132,51 @@ func main() {
 				log.Error("Failed to raise the alarm")
 			}
 			// The motor
-			motor := motor.New(
-				conf.MotorStepPin,
-				conf.MotorStepPwm,
-				conf.MotorDirPin,
-				conf.MotorSleepPin)
-			if err := motor.Disable(); err != nil {
+			if motor, err := board.NewActuator(conf.Conf, conf.Pins); err != nil {
 				log.Error("Failed to stop the motor")
+			} else {
+				if err := motor.Disable(); err != nil {
+					log.Error("Failed to stop the motor")
+				}
+				motor.Unexport()
+			}
+			// The clutch
+			if conf.Pins.ClutchPin != conf.NoPin {
+				if clutch, err := board.NewClutch(conf.Pins); err != nil {
+					log.Error("Failed to release the clutch")
+				} else {
+					clutch.Unexport()
+				}
 			}
-			motor.Unexport()
 
 			log.Fatalf("Version %v -- Received a panic error -- exiting: %v", Version, m)
 		}
 	}()
 
+	// events streamed to the UI - published without blocking by the pilot
+	eventChan := make(chan interface{}, webserver.EventBufferSize)
+
 	ws := webserver.New(Version)
 	ws.SetPanicChan(panicChan)
+	ws.SetEventChan(eventChan)
+	ws.SetConfiguration(conf.Conf, conf.File())
+	ws.SetAuthenticator(webserver.NewAuthenticator(
+		strings.Split(conf.Conf.ControlTokens, ","),
+		strings.Split(conf.Conf.ReadOnlyTokens, ","),
+		conf.Conf.ControlPIN,
+		conf.Conf.ReadOnlyPIN,
+		time.Duration(conf.Conf.SessionTimeoutInMinutes)*time.Minute))
 	ws.Start()
 
 	////////////////////////////////////////
 	// Init the IO
 	////////////////////////////////////////
+	// self-test of the LEDs and the alarm - see cmd/diagnostics for the whole test plan
+	selfTest := diagnostics.Run(Version, conf.Conf.Board, diagnostics.Leds(conf.Pins, nil), diagnostics.Alarm(conf.Pins, nil))
+	if !selfTest.Passed {
+		log.Panicf("Self-test failed: %+v", selfTest.Results)
+	}
+
 	// the LEDs
 	mapMessageToGPIO := func(message string, pin byte) gpio.Gpio {
 
@@ -121,23 +199,6 @@ func main() {
 			log.Panic(err)
 		}
 
-		// Test Disabled and Enabled state for each LEDs
-		err = g.Disable()
-		if err != nil {
-			log.Panic(err)
-		}
-
-		log.Info("[AUTOTEST] %s LED is ON", message)
-		time.Sleep(1 * time.Second)
-
-		err = g.Enable()
-		if err != nil {
-			log.Panic(err)
-		}
-
-		log.Info("[AUTOTEST] %s LED is OFF", message)
-		time.Sleep(1 * time.Second)
-
 		err = g.Disable()
 		if err != nil {
 			log.Panic(err)
@@ -145,8 +206,8 @@ func main() {
 
 		return g
 	}
-	dashboardGPIOs := make(map[string]gpio.Gpio, len(conf.MessageToPin))
-	for _, v := range conf.MessageToPin {
+	dashboardGPIOs := make(map[string]gpio.Gpio, len(conf.Pins.Leds))
+	for _, v := range conf.Pins.Leds {
 		g := mapMessageToGPIO(v.Message, v.Pin)
 		dashboardGPIOs[v.Message] = g
 	}
@@ -198,20 +259,70 @@ func main() {
 		}
 
 		return g
-	}(conf.SwitchGpioPin)
+	}(conf.Pins.SwitchGpioPin)
 	defer switchGpio.Unexport()
 
+	// the keypad buttons
+	buttonGpio := func(pin byte) gpio.Gpio {
+
+		// kill the process (via log.Panic) in case we can't create the GPIO
+		err := gpio.EnableGPIO(pin)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		var g = gpio.New(pin)
+		if !g.IsExported() {
+			err = g.Export()
+			if err != nil {
+				log.Panic(err)
+			}
+		}
+
+		err = g.SetDirection(gpio.InDirection)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		err = g.SetActiveLevel(gpio.ActiveHigh)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		return g
+	}
+	buttonGPIOs := make(map[string]gpio.Gpio, len(conf.Pins.Buttons))
+	for _, v := range conf.Pins.Buttons {
+		buttonGPIOs[v.Button] = buttonGpio(v.Pin)
+	}
+	defer func() {
+		for _, g := range buttonGPIOs {
+			g.Unexport()
+		}
+	}()
+
 	// The motor
-	motor := motor.New(
-		conf.MotorStepPin,
-		conf.MotorStepPwm,
-		conf.MotorDirPin,
-		conf.MotorSleepPin)
+	motor, err := board.NewActuator(conf.Conf, conf.Pins)
+	if err != nil {
+		log.Panic(err)
+	}
 	defer motor.Disable()
 	defer motor.Unexport()
 
+	// The clutch
+	var clutch gpio.Gpio
+	if conf.Pins.ClutchPin != conf.NoPin {
+		g, err := board.NewClutch(conf.Pins)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer g.Unexport()
+		defer g.Disable()
+		clutch = g
+	}
+
 	// The alarm
-	alarmPwm := func(pin byte, pwmId byte) *pwm.Pwm {
+	alarmPwm := func(pin byte, pwmId byte) pwm.Pwm {
 
 		// kill the process (via log.Panic) in case we can't create the PWM
 		pwm, err := pwm.New(pwmId, pin)
@@ -232,23 +343,12 @@ func main() {
 			log.Panic(err)
 		}
 
-		if err = pwm.Enable(); err != nil {
-			log.Panic(err)
-		}
-		log.Info("[AUTOTEST] alarm is ON")
-
-		time.Sleep(2 * time.Second)
-		if err = pwm.Disable(); err != nil {
-			log.Panic(err)
-		}
-		log.Info("[AUTOTEST] alarm is OFF")
-
 		return pwm
-	}(conf.AlarmGpioPin, conf.AlarmGpioPWM)
+	}(conf.Pins.AlarmGpioPin, conf.Pins.AlarmGpioPWM)
 	defer alarmPwm.Unexport()
 
 	// The compass sincos output interface
-	compass := sincos.New(conf.I2CBus, conf.SinAddress, conf.CosAddress)
+	compass := sincos.New(conf.Pins.I2CBus, conf.Pins.SinAddress, conf.Pins.CosAddress)
 
 	////////////////////////////////////////
 	// a nice and delicate alarm
@@ -278,14 +378,40 @@ func main() {
 	steeringChan := make(chan interface{})
 	steering.SetInputChan(steeringChan)
 	steering.SetPanicChan(panicChan)
+	if clutch != nil {
+		steering.SetClutch(clutch, time.Duration(conf.Conf.ClutchEngageInMilliseconds)*time.Millisecond,
+			time.Duration(conf.Conf.ClutchReleaseInMilliseconds)*time.Millisecond)
+	}
+	if conf.Pins.INA219Address != conf.NoPin {
+		bus, err := i2c.Open(conf.Pins.I2CBus)
+		if err != nil {
+			log.Panic(err)
+		}
+		defer bus.Close()
+		sensor := ina219.New(bus, conf.Pins.INA219Address, conf.Conf.ShuntResistanceInMilliOhms/1000)
+		steering.SetCurrentSensor(sensor, conf.Conf.MotorOvercurrentInAmps, conf.Conf.MotorStallCurrentInAmps,
+			time.Duration(conf.Conf.StallTimeoutInMilliseconds)*time.Millisecond)
+	}
 
 	////////////////////////////////////////
 	// a stunning tracer
 	////////////////////////////////////////
+	var trackStore *tracer.Store
+	if conf.Conf.TrackDirectory != "" {
+		store, err := tracer.NewStore(conf.Conf.TrackDirectory, conf.Conf.TrackFileMaxSizeInBytes)
+		if err != nil {
+			log.Panic(err)
+		}
+		trackStore = store
+	}
+	tracerChan := make(chan interface{}, tracer.InputBufferSize)
 	tracer := tracer.New(conf.Conf.TraceSize)
-	tracerChan := make(chan interface{})
 	tracer.SetInputChan(tracerChan)
 	tracer.SetPanicChan(panicChan)
+	tracer.SetMaxRange(time.Duration(conf.Conf.TrackQueryMaxRangeInHours) * time.Hour)
+	if trackStore != nil {
+		tracer.SetStore(trackStore)
+	}
 	ws.SetTracer(tracer)
 
 	////////////////////////////////////////
@@ -305,9 +431,12 @@ func main() {
 	thePilot := pilot.New(pidController, conf.Conf.Bounds)
 	pilotChan := make(chan interface{})
 	thePilot.SetInputChan(pilotChan)
+	steering.SetErrorChan(pilotChan)
 	thePilot.SetDashboardChan(dashboardChan)
 	thePilot.SetAlarmChan(alarmChan)
 	thePilot.SetSteeringChan(steeringChan)
+	thePilot.SetTracerChan(tracerChan)
+	thePilot.SetEventChan(eventChan)
 	thePilot.SetPanicChan(panicChan)
 	ws.SetPilot(thePilot)
 
@@ -317,6 +446,35 @@ func main() {
 	control := control.New(switchGpio, thePilot)
 	control.SetPanicChan(panicChan)
 
+	// react to the edges of the switch when the gpio reports them - otherwise it is polled
+	var switchWatcher *gpio.Watcher
+	if err := switchGpio.SetEdge(gpio.EdgeBoth); err != nil {
+		log.Warning("No edge events for the autopilot switch - polling it: %v", err)
+	} else {
+		switchWatcher = gpio.NewWatcher(switchGpio, gpio.DefaultDebouncePeriod)
+		switchWatcher.SetPanicChan(panicChan)
+		control.SetEventChan(switchWatcher.Values())
+	}
+
+	////////////////////////////////////////
+	// a handy keypad
+	////////////////////////////////////////
+	buttons := make(map[string]types.Readable, len(buttonGPIOs))
+	for b, g := range buttonGPIOs {
+		buttons[b] = g
+	}
+	theKeypad := keypad.New(buttons)
+	theKeypad.SetPanicChan(panicChan)
+	theKeypad.Bind(keypad.Short(keypad.Minus10), func() error { return thePilot.AdjustHeading(-pilot.LargeHeadingAdjustment) })
+	theKeypad.Bind(keypad.Short(keypad.Minus1), func() error { return thePilot.AdjustHeading(-pilot.SmallHeadingAdjustment) })
+	theKeypad.Bind(keypad.Short(keypad.Plus1), func() error { return thePilot.AdjustHeading(pilot.SmallHeadingAdjustment) })
+	theKeypad.Bind(keypad.Short(keypad.Plus10), func() error { return thePilot.AdjustHeading(pilot.LargeHeadingAdjustment) })
+	theKeypad.Bind(keypad.Short(keypad.Auto), thePilot.Enable)
+	theKeypad.Bind(keypad.Long(keypad.Auto), thePilot.RecaptureHeading)
+	theKeypad.Bind(keypad.Short(keypad.Standby), thePilot.Disable)
+	theKeypad.Bind(keypad.Short(keypad.Minus1, keypad.Minus10), func() error { return thePilot.Tack(pilot.Port) })
+	theKeypad.Bind(keypad.Short(keypad.Plus1, keypad.Plus10), func() error { return thePilot.Tack(pilot.Starboard) })
+
 	////////////////////////////////////////
 	// a friendly interface to the AP100
 	////////////////////////////////////////
@@ -325,6 +483,19 @@ func main() {
 	ap100.SetInputChan(headingChan)
 	ap100.SetPanicChan(panicChan)
 
+	////////////////////////////////////////
+	// a breezy wind instrument
+	////////////////////////////////////////
+	if conf.Conf.WindSerialPort != "" {
+		wind := gps.New(conf.Conf.WindSerialPort)
+		wind.SetBaud(conf.Conf.WindSerialBaud)
+		wind.SetMessagesChan(pilotChan)
+		wind.SetHeadingChan(headingChan)
+		// no error channel: wind errors are only logged, the pilot notices when the wind data goes stale
+		wind.SetPanicChan(panicChan)
+		wind.Start()
+	}
+
 	////////////////////////////////////////
 	// a wonderful gps
 	////////////////////////////////////////
@@ -333,15 +504,20 @@ func main() {
 	gps.SetHeadingChan(headingChan)
 	gps.SetErrorChan(pilotChan)
 	gps.SetPanicChan(panicChan)
-	gps.SetTracerChan(tracerChan)
 
 	tracer.Start()
 	defer tracer.Shutdown()
 	ap100.Start()
 	defer ap100.Shutdown()
 	gps.Start()
+	if switchWatcher != nil {
+		switchWatcher.Start()
+		defer switchWatcher.Shutdown()
+	}
 	control.Start()
 	defer control.Shutdown()
+	theKeypad.Start()
+	defer theKeypad.Shutdown()
 	alarm.Start()
 	defer alarm.Shutdown()
 	dashboard.Start()
@@ -351,6 +527,23 @@ func main() {
 	thePilot.Start()
 	defer thePilot.Shutdown()
 
+	if err := thePilot.SetResponse(pilot.Response(conf.Conf.Response)); err != nil {
+		log.Panic(err)
+	}
+
+	////////////////////////////////////////
+	// a watchful configuration
+	////////////////////////////////////////
+	reloader := conf.NewReloader(conf.File(), conf.Conf)
+	reloader.SetPanicChan(panicChan)
+	reloader.Subscribe(thePilot.Configure)
+	reloader.Subscribe(func(c conf.Configuration) error {
+		ws.SetConfiguration(c, conf.File())
+		return nil
+	})
+	reloader.Start()
+	defer reloader.Shutdown()
+
 	// Wait until we receive a signal
 	utils.WaitForInterrupt(func() {
 		log.Info("Interrupted - exiting")
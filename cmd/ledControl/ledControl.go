@@ -18,13 +18,14 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-26 17:50:09
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 13:10:36
+* @Last Modified time: 2015-11-23 21:27:14
  */
 
 package main
 
 import (
 	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/drivers/board"
 	"github.com/ssoudan/edisonIsThePilot/drivers/gpio"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 )
@@ -33,6 +34,13 @@ var log = logger.Log("ledControl")
 
 func main() {
 
+	if conf.LoadError != nil {
+		log.Fatalf("Invalid configuration in %s -- exiting: %v", conf.File(), conf.LoadError)
+	}
+	if err := board.Setup(conf.Conf, conf.Pins); err != nil {
+		log.Fatalf("Invalid board -- exiting: %v", err)
+	}
+
 	mapMessageToGPIO := func(message string, pin byte) gpio.Gpio {
 
 		// kill the process (via log.Panic -> recover -> panicChan -> go routine -> log.Fatal) in case we can't create the GPIO
@@ -62,8 +70,8 @@ func main() {
 
 		return g
 	}
-	dashboardGPIOs := make(map[string]gpio.Gpio, len(conf.MessageToPin))
-	for _, v := range conf.MessageToPin {
+	dashboardGPIOs := make(map[string]gpio.Gpio, len(conf.Pins.Leds))
+	for _, v := range conf.Pins.Leds {
 		g := mapMessageToGPIO(v.Message, v.Pin)
 		dashboardGPIOs[v.Message] = g
 	}
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-22 13:24:54
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 14:23:56
+* @Last Modified time: 2015-11-27 22:08:45
  */
 
 package main
@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/drivers/board"
 	"github.com/ssoudan/edisonIsThePilot/drivers/motor"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 )
@@ -61,16 +62,22 @@ type step struct {
 
 func main() {
 
+	if conf.LoadError != nil {
+		log.Fatalf("Invalid configuration in %s -- exiting: %v", conf.File(), conf.LoadError)
+	}
+	if err := board.Setup(conf.Conf, conf.Pins); err != nil {
+		log.Fatalf("Invalid board -- exiting: %v", err)
+	}
+
 	if _, err := parser.Parse(); err != nil {
 		log.Fatalf("failed to parse options: %v", err)
 	}
 
 	log.Info("%v", opts)
-	motor := motor.New(
-		conf.MotorStepPin,
-		conf.MotorStepPwm,
-		conf.MotorDirPin,
-		conf.MotorSleepPin)
+	motor, err := motor.New(board.MotorConfig(conf.Conf, conf.Pins))
+	if err != nil {
+		log.Fatalf("Failed to create the motor -- exiting: %v", err)
+	}
 
 	sol := step{392, time.Duration(250 * time.Millisecond), time.Duration(0 * time.Millisecond)}
 	solLL := step{392, time.Duration(1000 * time.Millisecond), time.Duration(0 * time.Millisecond)}
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-22 13:24:54
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-09-25 11:59:31
+* @Last Modified time: 2015-11-27 22:08:45
  */
 
 package main
@@ -26,6 +26,7 @@ package main
 import (
 	"fmt"
 	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/drivers/board"
 	"github.com/ssoudan/edisonIsThePilot/drivers/motor"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 	"math"
@@ -34,18 +35,16 @@ import (
 
 var log = logger.Log("motorCalibration")
 
-var numberOfSteps = float64(200)
-
-func rotationInDegreeToMove(speed uint32, rotationInDegree float64) (clockwise bool, duration time.Duration) {
+func rotationInDegreeToMove(speed uint32, rotationInDegree float64, stepsPerRevolution uint32) (clockwise bool, duration time.Duration) {
 	clockwise = rotationInDegree > 0.
 	duration = time.Duration(
-		math.Abs(rotationInDegree/360.*numberOfSteps/float64(speed)) * float64(time.Second))
+		math.Abs(rotationInDegree/360.*float64(stepsPerRevolution)/float64(speed)) * float64(time.Second))
 
 	return
 }
 func doStep(motor *motor.Motor, s step) {
 
-	clockwise, duration := rotationInDegreeToMove(s.stepsBySecond, s.rotationInDegree)
+	clockwise, duration := rotationInDegreeToMove(s.stepsBySecond, s.rotationInDegree, motor.StepsPerRevolution())
 
 	motor.Enable()
 	log.Info("[%3d] Moving [%6s] -- clockwise[%v] at %v[steps/s] for %v", s.id, fmt.Sprintf("%3.2f", s.rotationInDegree), clockwise, s.stepsBySecond, duration)
@@ -61,18 +60,24 @@ type step struct {
 
 func main() {
 
-	motor := motor.New(
-		conf.MotorStepPin,
-		conf.MotorStepPwm,
-		conf.MotorDirPin,
-		conf.MotorSleepPin)
+	if conf.LoadError != nil {
+		log.Fatalf("Invalid configuration in %s -- exiting: %v", conf.File(), conf.LoadError)
+	}
+	if err := board.Setup(conf.Conf, conf.Pins); err != nil {
+		log.Fatalf("Invalid board -- exiting: %v", err)
+	}
+
+	motor, err := motor.New(board.MotorConfig(conf.Conf, conf.Pins))
+	if err != nil {
+		log.Fatalf("Failed to create the motor -- exiting: %v", err)
+	}
 
 	stepCount := 201
 
 	steps := make([]step, stepCount)
 
 	for i := 1; i < stepCount; i++ {
-		steps[i] = step{id: i, stepsBySecond: 200, rotationInDegree: float64(i) * 1.8}
+		steps[i] = step{id: i, stepsBySecond: motor.StepsPerRevolution(), rotationInDegree: float64(i) * 1.8}
 	}
 	fmt.Println(steps)
 
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-27 22:18:56
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 16:41:12
+* @Last Modified time: 2015-11-29 17:44:12
  */
 
 package main
@@ -31,8 +31,8 @@ import (
 
 	"github.com/ssoudan/edisonIsThePilot/conf"
 	"github.com/ssoudan/edisonIsThePilot/control"
+	"github.com/ssoudan/edisonIsThePilot/drivers/board"
 	"github.com/ssoudan/edisonIsThePilot/drivers/gpio"
-	"github.com/ssoudan/edisonIsThePilot/drivers/motor"
 	"github.com/ssoudan/edisonIsThePilot/gps"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/utils"
@@ -59,6 +59,13 @@ var parser = flags.NewParser(&opts, flags.Default)
 
 func main() {
 
+	if conf.LoadError != nil {
+		log.Fatalf("Invalid configuration in %s -- exiting: %v", conf.File(), conf.LoadError)
+	}
+	if err := board.Setup(conf.Conf, conf.Pins); err != nil {
+		log.Fatalf("Invalid board -- exiting: %v", err)
+	}
+
 	// parse inputs
 	if _, err := parser.Parse(); err != nil {
 		log.Fatalf("failed to parse options: %v", err)
@@ -86,11 +93,10 @@ func main() {
 	stepperChan := make(chan interface{})
 
 	// The motor
-	motor := motor.New(
-		conf.MotorStepPin,
-		conf.MotorStepPwm,
-		conf.MotorDirPin,
-		conf.MotorSleepPin)
+	motor, err := board.NewActuator(conf.Conf, conf.Pins)
+	if err != nil {
+		log.Panic(err)
+	}
 	defer motor.Unexport()
 
 	// the input button
@@ -134,7 +140,7 @@ func main() {
 		}
 
 		return g
-	}(conf.SwitchGpioPin)
+	}(conf.Pins.SwitchGpioPin)
 	defer switchGpio.Unexport()
 
 	////////////////////////////////////////
@@ -145,16 +151,6 @@ func main() {
 	steering.SetInputChan(steeringChan)
 	steering.SetPanicChan(panicChan)
 
-	////////////////////////////////////////
-	// a fake tracer
-	////////////////////////////////////////
-	tracerChan := make(chan interface{})
-	go func() {
-		for {
-			<-tracerChan
-		}
-	}()
-
 	////////////////////////////////////////
 	// a wonderful gps
 	////////////////////////////////////////
@@ -162,7 +158,6 @@ func main() {
 	gps.SetMessagesChan(stepperChan)
 	gps.SetErrorChan(stepperChan)
 	gps.SetPanicChan(panicChan)
-	gps.SetTracerChan(tracerChan)
 
 	////////////////////////////////////////
 	// a crazy stepper
@@ -18,21 +18,26 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-27 22:18:56
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 16:45:46
+* @Last Modified time: 2015-12-01 21:12:40
  */
 
 package main
 
 import (
+	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/ssoudan/edisonIsThePilot/conf"
 	"github.com/ssoudan/edisonIsThePilot/dashboard"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/utils"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/webserver"
 	"github.com/ssoudan/edisonIsThePilot/pilot"
+	"github.com/ssoudan/edisonIsThePilot/tracer"
 )
 
 var log = logger.Log("webserver")
@@ -53,6 +58,16 @@ func (f fakeTracer) GetPoints() []types.Point {
 	return f.points
 }
 
+// GetFilteredPoints returns the trace selected by the filter as an array of types.Point
+func (f fakeTracer) GetFilteredPoints(filter tracer.Filter) []types.Point {
+	return filter.Apply(f.points)
+}
+
+// GetTrack returns the trace as an array of types.Point
+func (f fakeTracer) GetTrack(from, to time.Time) ([]types.Point, error) {
+	return f.points, nil
+}
+
 // GetDashboardInfoAction returns the LEDs status in this mock implementation
 func (q queryable) GetDashboardInfoAction() map[string]bool {
 	return q.leds
@@ -64,6 +79,8 @@ type fakePilot struct {
 	setPoint      float64
 	course        float64
 	speed         float64
+	response      pilot.Response
+	mode          pilot.Mode
 }
 
 func (p *fakePilot) GetInfoAction() pilot.Info {
@@ -71,14 +88,23 @@ func (p *fakePilot) GetInfoAction() pilot.Info {
 	p.course = p.course + (r.Float64()*5 - 2.5)
 
 	pi := pilot.Info{
-		Enabled:       p.enabled,
-		HeadingOffset: p.headingOffset,
-		SetPoint:      p.setPoint,
-		Course:        p.course,
-		Speed:         p.speed,
+		Enabled:        p.enabled,
+		HeadingOffset:  p.headingOffset,
+		SetPoint:       p.setPoint,
+		Course:         p.course,
+		Speed:          p.speed,
+		Response:       p.response,
+		ActiveResponse: p.response,
+		Mode:           p.mode,
+		WindAngle:      45,
+		ApparentWind:   45 + (r.Float64()*10 - 5),
+		WindSpeed:      12,
 	}
 	return pi
 }
+func (p *fakePilot) Configure(configuration conf.Configuration) error {
+	return conf.Validate(configuration)
+}
 func (p *fakePilot) Enable() error {
 	p.enabled = true
 	return nil
@@ -91,9 +117,59 @@ func (p *fakePilot) SetOffset(headingOffset float64) error {
 	p.headingOffset = headingOffset
 	return nil
 }
+func (p *fakePilot) SetResponse(response pilot.Response) error {
+	p.response = response
+	return nil
+}
+func (p *fakePilot) AdjustHeading(delta float64) error {
+	p.setPoint = math.Mod(p.setPoint+delta+360, 360)
+	return nil
+}
+func (p *fakePilot) SetHeading(heading float64) error {
+	p.setPoint = heading
+	return nil
+}
+func (p *fakePilot) RecaptureHeading() error {
+	p.setPoint = p.course
+	return nil
+}
+func (p *fakePilot) SetMode(mode pilot.Mode) error {
+	p.mode = mode
+	return nil
+}
+func (p *fakePilot) Tack(side pilot.Side) error {
+	if side == pilot.Port {
+		return p.AdjustHeading(-100)
+	}
+	return p.AdjustHeading(100)
+}
 
 var r = rand.New(rand.NewSource(99))
 
+// fakePoints generates ten minutes of a wobbly course
+func fakePoints(now time.Time) []types.Point {
+	points := make([]types.Point, 600)
+	for i := range points {
+		headingError := r.Float64()*10 - 5
+		points[i] = types.Point{
+			Latitude:        45. + float64(i)*1e-4,
+			Longitude:       5.,
+			Time:            types.JSONTime(now.Add(time.Duration(i-len(points)) * time.Second)),
+			Course:          headingError,
+			Speed:           5 + r.Float64(),
+			SetPoint:        0,
+			HeadingError:    headingError,
+			PIDOutput:       -2 * headingError,
+			Proportional:    -1.5 * headingError,
+			Integral:        -0.1 * headingError,
+			Derivative:      -0.4 * headingError,
+			Steering:        -2 * headingError,
+			SteeringEnabled: true,
+		}
+	}
+	return points
+}
+
 func main() {
 	panicChan := make(chan interface{})
 	defer func() {
@@ -110,19 +186,30 @@ func main() {
 		}
 	}()
 
+	fake := &fakePilot{mode: pilot.Compass}
+	eventChan := make(chan interface{}, webserver.EventBufferSize)
+
+	// publish the fake pilot state every second for the live clients
+	go func() {
+		for range time.Tick(time.Second) {
+			types.Publish(eventChan, types.Event{Type: types.AutopilotEvent, Data: fake.GetInfoAction()})
+		}
+	}()
+
 	ws := webserver.New(Version)
 	ws.SetPanicChan(panicChan)
-	ws.SetPilot(&fakePilot{})
-	ws.SetTracer(&fakeTracer{points: []types.Point{{
-		Latitude:  45.,
-		Longitude: 5.,
-		Time:      types.JSONTime(time.Now())}}})
+	ws.SetEventChan(eventChan)
+	ws.SetConfiguration(conf.Conf, filepath.Join(os.TempDir(), "edisonIsThePilot.properties"))
+	ws.SetPilot(fake)
+	ws.SetTracer(&fakeTracer{points: fakePoints(time.Now())})
 	ws.SetDashboard(queryable{leds: map[string]bool{
 		dashboard.NoGPSFix:                true,
 		dashboard.InvalidGPSData:          true,
 		dashboard.SpeedTooLow:             false,
 		dashboard.HeadingErrorOutOfBounds: true,
 		dashboard.CorrectionAtLimit:       true,
+		dashboard.WindDataStale:           false,
+		dashboard.MotorFault:              false,
 	}})
 	ws.Start()
 
@@ -0,0 +1,469 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-18 21:38:44
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-03 19:27:55
+ */
+
+package conf
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/dashboard"
+	"github.com/ssoudan/edisonIsThePilot/drivers/gpio"
+	"github.com/ssoudan/edisonIsThePilot/drivers/motor"
+	"github.com/ssoudan/edisonIsThePilot/keypad"
+)
+
+// MessagePin is the mapping of pin to a message
+type MessagePin struct {
+	Message string
+	Pin     byte
+}
+
+// ButtonPin is the mapping of pin to a keypad button
+type ButtonPin struct {
+	Button string
+	Pin    byte
+}
+
+// PinMap is the wiring of the pilot on a board
+type PinMap struct {
+	Leds    []MessagePin // LED of each dashboard message
+	Buttons []ButtonPin  // input pin of each keypad button - a keypad can have less buttons
+
+	GpioBackend  string // gpio.SysfsBackend or gpio.CdevBackend
+	GpioChip     string // character device of the gpio chip - for gpio.CdevBackend
+	Platform     string // EdisonPlatform (pins multiplexed through debugfs, pwm limits of the Edison) or GenericPlatform
+	PwmChip      byte   // N of the /sys/class/pwm/pwmchipN of the pwm
+	PwmMinPeriod string // shortest period of the pwm (e.g. 100ns) - empty for the one of the Platform
+	PwmMaxPeriod string // longest period of the pwm (e.g. 1s) - empty for the one of the Platform
+
+	AlarmGpioPin  byte // pin where the alarm is connected
+	AlarmGpioPWM  byte // pwm where the alarm is connected
+	SwitchGpioPin byte // pin where the autopilot switch is connected
+	MotorDirPin   byte // direction pin for the motor - DIR or IN1 of an H-bridge
+	MotorSleepPin byte // enable (or sleep) pin for the motor - see MotorEnableActiveLow
+	MotorStepPin  byte // step pin for the motor - PWM input of an H-bridge
+	MotorStepPwm  byte // pwm where the step pin of the motor is connected
+	MotorMS1Pin   byte // microstep selection pins of the motor driver - NoPin when set with jumpers
+	MotorMS2Pin   byte
+	MotorMS3Pin   byte
+	MotorIn2Pin   byte // IN2 pin of an in1-in2 H-bridge (IN1 is MotorDirPin) - NoPin when not wired
+	MotorSensePin byte // over-current input of the H-bridge, high when the motor stalls - NoPin when not wired
+	ClutchPin     byte // output engaging the clutch of the drive unit when high - NoPin when there is none
+	I2CBus        byte // i2c bus of the Sin/Cos interface
+	I2CSclPin     byte // pin of the SCL line of the i2c bus
+	I2CSdaPin     byte // pin of the SDA line of the i2c bus
+	SinAddress    byte // i2c address of the Sine DAC (MCP4725)
+	CosAddress    byte // i2c address of the Cosine DAC (MCP4725)
+	INA219Address byte // i2c address of the current sensor of the motor (INA219) - NoPin when there is none
+}
+
+// Names of the board profiles
+const (
+	EdisonMiniBreakout = "edison-mini-breakout"
+	EdisonArduino      = "edison-arduino"
+	RaspberryPi        = "raspberry-pi"
+)
+
+// NoPin is the pin of the optional inputs which are not wired
+const NoPin = motor.NoPin
+
+// optionalPins are the fields of PinMap which can be disconnected
+var optionalPins = map[string]bool{
+	"MotorMS1Pin":   true,
+	"MotorMS2Pin":   true,
+	"MotorMS3Pin":   true,
+	"MotorIn2Pin":   true,
+	"MotorSensePin": true,
+	"INA219Address": true,
+	"ClutchPin":     true,
+}
+
+// Actuators of the steering
+const (
+	StepperActuator = "stepper"
+	HBridgeActuator = "hbridge"
+)
+
+// Names of the platforms
+const (
+	EdisonPlatform  = "edison"
+	GenericPlatform = "generic"
+)
+
+// Boards are the known board profiles
+var Boards = map[string]PinMap{
+	EdisonMiniBreakout: {
+		Leds: []MessagePin{
+			{dashboard.NoGPSFix, 43},                // J19 - pin 11
+			{dashboard.InvalidGPSData, 48},          // J19 - pin 6
+			{dashboard.SpeedTooLow, 40},             // J19 - pin 10
+			{dashboard.HeadingErrorOutOfBounds, 82}, // J19 - pin 13
+			{dashboard.CorrectionAtLimit, 83},       // J19 - pin 14
+			{dashboard.WindDataStale, 44},           // J19 - pin 4
+			{dashboard.MotorFault, 77},              // J19 - pin 12
+		},
+		Buttons: []ButtonPin{
+			{keypad.Minus10, 45}, // J20 - pin 3
+			{keypad.Minus1, 47},  // J20 - pin 4
+			{keypad.Plus1, 49},   // J20 - pin 5
+			{keypad.Plus10, 15},  // J20 - pin 6
+			{keypad.Auto, 84},    // J20 - pin 7
+			{keypad.Standby, 42}, // J20 - pin 8
+		},
+		GpioBackend:   gpio.SysfsBackend,
+		GpioChip:      "/dev/gpiochip0",
+		Platform:      EdisonPlatform,
+		PwmChip:       0,
+		AlarmGpioPin:  183, // J18 - pin 8
+		AlarmGpioPWM:  3,
+		SwitchGpioPin: 46,  // J19 - pin 5
+		MotorDirPin:   165, // J18 - pin 2
+		MotorSleepPin: 12,  // J18 - pin 7
+		MotorStepPin:  182, // J17 - pin 1
+		MotorStepPwm:  2,
+		MotorMS1Pin:   NoPin,
+		MotorMS2Pin:   NoPin,
+		MotorMS3Pin:   NoPin,
+		MotorIn2Pin:   NoPin,
+		MotorSensePin: NoPin,
+		ClutchPin:     NoPin,
+		I2CBus:        6,
+		I2CSclPin:     27,
+		I2CSdaPin:     28,
+		SinAddress:    0x62,
+		CosAddress:    0x63,
+		INA219Address: NoPin,
+	},
+	// IO0/IO1 are the UART of the GPS and A4/A5 the i2c bus: 16 pins are left, the keypad only has 4 buttons
+	EdisonArduino: {
+		Leds: []MessagePin{
+			{dashboard.NoGPSFix, 41},                // IO10
+			{dashboard.InvalidGPSData, 48},          // IO7
+			{dashboard.SpeedTooLow, 40},             // IO13
+			{dashboard.HeadingErrorOutOfBounds, 43}, // IO11
+			{dashboard.CorrectionAtLimit, 42},       // IO12
+			{dashboard.WindDataStale, 44},           // A0
+			{dashboard.MotorFault, 12},              // IO3
+		},
+		Buttons: []ButtonPin{
+			{keypad.Minus1, 45},  // A1
+			{keypad.Plus1, 46},   // A2
+			{keypad.Auto, 47},    // A3
+			{keypad.Standby, 49}, // IO8
+		},
+		GpioBackend:   gpio.SysfsBackend,
+		GpioChip:      "/dev/gpiochip0",
+		Platform:      EdisonPlatform,
+		PwmChip:       0,
+		AlarmGpioPin:  183, // IO9
+		AlarmGpioPWM:  3,
+		SwitchGpioPin: 129, // IO4
+		MotorDirPin:   128, // IO2
+		MotorSleepPin: 13,  // IO5
+		MotorStepPin:  182, // IO6
+		MotorStepPwm:  2,
+		MotorMS1Pin:   NoPin,
+		MotorMS2Pin:   NoPin,
+		MotorMS3Pin:   NoPin,
+		MotorIn2Pin:   NoPin,
+		MotorSensePin: NoPin,
+		ClutchPin:     NoPin,
+		I2CBus:        6,
+		I2CSclPin:     27,
+		I2CSdaPin:     28,
+		SinAddress:    0x62,
+		CosAddress:    0x63,
+		INA219Address: NoPin,
+	},
+	// BCM numbering - the alarm and the motor step are on the two hardware PWM
+	RaspberryPi: {
+		Leds: []MessagePin{
+			{dashboard.NoGPSFix, 5},                 // pin 29
+			{dashboard.InvalidGPSData, 6},           // pin 31
+			{dashboard.SpeedTooLow, 12},             // pin 32
+			{dashboard.HeadingErrorOutOfBounds, 16}, // pin 36
+			{dashboard.CorrectionAtLimit, 20},       // pin 38
+			{dashboard.WindDataStale, 21},           // pin 40
+			{dashboard.MotorFault, 26},              // pin 37
+		},
+		Buttons: []ButtonPin{
+			{keypad.Minus10, 17}, // pin 11
+			{keypad.Minus1, 27},  // pin 13
+			{keypad.Plus1, 22},   // pin 15
+			{keypad.Plus10, 10},  // pin 19
+			{keypad.Auto, 9},     // pin 21
+			{keypad.Standby, 11}, // pin 23
+		},
+		GpioBackend:   gpio.CdevBackend,
+		GpioChip:      "/dev/gpiochip0",
+		Platform:      GenericPlatform,
+		PwmChip:       0,
+		AlarmGpioPin:  13, // pin 33
+		AlarmGpioPWM:  1,
+		SwitchGpioPin: 25, // pin 22
+		MotorDirPin:   23, // pin 16
+		MotorSleepPin: 24, // pin 18
+		MotorStepPin:  18, // pin 12
+		MotorStepPwm:  0,
+		MotorMS1Pin:   NoPin,
+		MotorMS2Pin:   NoPin,
+		MotorMS3Pin:   NoPin,
+		MotorIn2Pin:   NoPin,
+		MotorSensePin: NoPin,
+		ClutchPin:     NoPin,
+		I2CBus:        1,
+		I2CSclPin:     3, // pin 5
+		I2CSdaPin:     2, // pin 3
+		SinAddress:    0x62,
+		CosAddress:    0x63,
+		INA219Address: NoPin,
+	},
+}
+
+// boardNames returns the names of the known board profiles
+func boardNames() []string {
+	names := []string{}
+	for name := range Boards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// override changes one pin of the map: name is a field of PinMap, Led.<message> or Button.<button> -
+// "none" disconnects a LED or a button
+func (m *PinMap) override(name, value string) error {
+	if field := reflect.ValueOf(m).Elem().FieldByName(name); field.IsValid() && field.Kind() == reflect.String {
+		field.SetString(value)
+		return nil
+	}
+
+	pin, err := strconv.ParseUint(value, 0, 8)
+	if err != nil && value != "none" {
+		return fmt.Errorf("invalid value for %s: %s", name, value)
+	}
+
+	switch {
+	case strings.HasPrefix(name, "Led."):
+		message := strings.TrimPrefix(name, "Led.")
+		leds := []MessagePin{}
+		for _, l := range m.Leds {
+			if l.Message != message {
+				leds = append(leds, l)
+			}
+		}
+		if value != "none" {
+			leds = append(leds, MessagePin{message, byte(pin)})
+		}
+		m.Leds = leds
+		return nil
+
+	case strings.HasPrefix(name, "Button."):
+		button := strings.TrimPrefix(name, "Button.")
+		buttons := []ButtonPin{}
+		for _, b := range m.Buttons {
+			if b.Button != button {
+				buttons = append(buttons, b)
+			}
+		}
+		if value != "none" {
+			buttons = append(buttons, ButtonPin{button, byte(pin)})
+		}
+		m.Buttons = buttons
+		return nil
+	}
+
+	field := reflect.ValueOf(m).Elem().FieldByName(name)
+	if !field.IsValid() || field.Kind() != reflect.Uint8 {
+		return fmt.Errorf("unknown pin %s", name)
+	}
+	if value == "none" {
+		if !optionalPins[name] {
+			return fmt.Errorf("%s can't be disconnected", name)
+		}
+		pin = NoPin
+	}
+	field.SetUint(pin)
+	return nil
+}
+
+// PwmLimits returns the periods of PwmMinPeriod and PwmMaxPeriod - 0 when they are not set
+func (m PinMap) PwmLimits() (min, max time.Duration, err error) {
+	parse := func(name, value string) (time.Duration, error) {
+		if value == "" {
+			return 0, nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("invalid %s: %s", name, value)
+		}
+		return d, nil
+	}
+
+	if min, err = parse("PwmMinPeriod", m.PwmMinPeriod); err != nil {
+		return 0, 0, err
+	}
+	if max, err = parse("PwmMaxPeriod", m.PwmMaxPeriod); err != nil {
+		return 0, 0, err
+	}
+	if min != 0 && max != 0 && min > max {
+		return 0, 0, fmt.Errorf("PwmMinPeriod (%v) is longer than PwmMaxPeriod (%v)", min, max)
+	}
+	return min, max, nil
+}
+
+// conflicts lists the pins used twice
+func (m PinMap) conflicts() []string {
+	problems := []string{}
+
+	switch m.GpioBackend {
+	case gpio.SysfsBackend:
+	case gpio.CdevBackend:
+		if m.GpioChip == "" {
+			problems = append(problems, "GpioChip is required by the cdev gpio backend")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unknown gpio backend %s - expecting %s or %s", m.GpioBackend, gpio.SysfsBackend, gpio.CdevBackend))
+	}
+
+	if m.Platform != EdisonPlatform && m.Platform != GenericPlatform {
+		problems = append(problems, fmt.Sprintf("unknown platform %s - expecting %s or %s", m.Platform, EdisonPlatform, GenericPlatform))
+	}
+	if _, _, err := m.PwmLimits(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	gpios := make(map[byte]string)
+	use := func(pin byte, name string) {
+		if pin == NoPin {
+			return
+		}
+		if other, ok := gpios[pin]; ok {
+			problems = append(problems, fmt.Sprintf("pin %d is used by both %s and %s", pin, other, name))
+			return
+		}
+		gpios[pin] = name
+	}
+
+	leds := map[string]bool{
+		dashboard.NoGPSFix:                true,
+		dashboard.InvalidGPSData:          true,
+		dashboard.SpeedTooLow:             true,
+		dashboard.HeadingErrorOutOfBounds: true,
+		dashboard.CorrectionAtLimit:       true,
+		dashboard.WindDataStale:           true,
+		dashboard.MotorFault:              true,
+	}
+	for _, l := range m.Leds {
+		if !leds[l.Message] {
+			problems = append(problems, fmt.Sprintf("unknown dashboard message %s", l.Message))
+		}
+		use(l.Pin, "Led."+l.Message)
+	}
+
+	buttons := map[string]bool{
+		keypad.Minus10: true,
+		keypad.Minus1:  true,
+		keypad.Plus1:   true,
+		keypad.Plus10:  true,
+		keypad.Auto:    true,
+		keypad.Standby: true,
+	}
+	for _, b := range m.Buttons {
+		if !buttons[b.Button] {
+			problems = append(problems, fmt.Sprintf("unknown keypad button %s", b.Button))
+		}
+		use(b.Pin, "Button."+b.Button)
+	}
+	use(m.AlarmGpioPin, "AlarmGpioPin")
+	use(m.SwitchGpioPin, "SwitchGpioPin")
+	use(m.MotorDirPin, "MotorDirPin")
+	use(m.MotorSleepPin, "MotorSleepPin")
+	use(m.MotorMS1Pin, "MotorMS1Pin")
+	use(m.MotorMS2Pin, "MotorMS2Pin")
+	use(m.MotorMS3Pin, "MotorMS3Pin")
+	use(m.MotorIn2Pin, "MotorIn2Pin")
+	use(m.MotorSensePin, "MotorSensePin")
+	use(m.ClutchPin, "ClutchPin")
+	use(m.MotorStepPin, "MotorStepPin")
+	use(m.I2CSclPin, "I2CSclPin")
+	use(m.I2CSdaPin, "I2CSdaPin")
+
+	if m.AlarmGpioPWM == m.MotorStepPwm {
+		problems = append(problems, fmt.Sprintf("pwm %d is used by both AlarmGpioPWM and MotorStepPwm", m.AlarmGpioPWM))
+	}
+	if m.SinAddress == m.CosAddress {
+		problems = append(problems, fmt.Sprintf("i2c address %#x is used by both SinAddress and CosAddress", m.SinAddress))
+	}
+	if m.INA219Address == m.SinAddress || m.INA219Address == m.CosAddress {
+		problems = append(problems, fmt.Sprintf("i2c address %#x of INA219Address is used by a DAC", m.INA219Address))
+	}
+	return problems
+}
+
+// ResolvePins returns the pin mapping of the board profile of a configuration with its overrides applied
+func ResolvePins(c Configuration) (PinMap, error) {
+	profile, ok := Boards[c.Board]
+	if !ok {
+		return PinMap{}, ValidationError{fmt.Sprintf("unknown board %s - known boards are %v", c.Board, boardNames())}
+	}
+
+	m := profile
+	m.Leds = append([]MessagePin{}, profile.Leds...)
+	m.Buttons = append([]ButtonPin{}, profile.Buttons...)
+
+	var problems ValidationError
+	for _, override := range strings.Split(c.PinOverrides, ",") {
+		if override = strings.TrimSpace(override); override == "" {
+			continue
+		}
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 {
+			problems = append(problems, fmt.Sprintf("invalid pin override %s - expecting Name=value", override))
+			continue
+		}
+		if err := m.override(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	problems = append(problems, m.conflicts()...)
+	if len(problems) != 0 {
+		return m, problems
+	}
+	return m, nil
+}
+
+// resolvePins resolves the pin mapping of Conf - a problem is reported by LoadError
+func resolvePins() PinMap {
+	m, _ := ResolvePins(Conf)
+	return m
+}
+
+// Pins is the pin mapping resolved from Conf - shared by all the programs
+var Pins = resolvePins()
@@ -0,0 +1,150 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-18 21:38:44
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-27 22:08:45
+ */
+
+package conf
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/dashboard"
+	"github.com/ssoudan/edisonIsThePilot/keypad"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThatTheBoardProfilesHaveNoConflict(t *testing.T) {
+	for name, profile := range Boards {
+		assert.Empty(t, profile.conflicts(), name)
+	}
+}
+
+func TestThatTheDefaultBoardIsTheMiniBreakout(t *testing.T) {
+	assert.EqualValues(t, Boards[EdisonMiniBreakout], Pins)
+}
+
+func TestThatPinsCanBeOverridden(t *testing.T) {
+	c := Conf
+	c.Board = EdisonMiniBreakout
+	c.PinOverrides = "MotorDirPin=14, Led.NoGPSFix=0x0d ,Button.Plus10=none"
+
+	pins, err := ResolvePins(c)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 14, pins.MotorDirPin)
+	assert.Contains(t, pins.Leds, MessagePin{dashboard.NoGPSFix, 13})
+	assert.NotContains(t, pins.Leds, MessagePin{dashboard.NoGPSFix, 43})
+	assert.Len(t, pins.Buttons, 5)
+	assert.NotContains(t, pins.Buttons, ButtonPin{keypad.Plus10, 15})
+
+	assert.Len(t, Boards[EdisonMiniBreakout].Buttons, 6, "the profile is unchanged")
+}
+
+func TestThatInvalidPinMappingsAreReported(t *testing.T) {
+	c := Conf
+	c.Board = EdisonMiniBreakout
+	c.PinOverrides = "MotorDirPin=12,CosAddress=0x62,Led.Foo=3,Nope=1,MotorStepPwm=none,I2CBus"
+
+	_, err := ResolvePins(c)
+	if assert.Error(t, err) {
+		problems := err.(ValidationError)
+		assert.Len(t, problems, 6)
+		assert.Contains(t, problems, "pin 12 is used by both MotorDirPin and MotorSleepPin")
+		assert.Contains(t, problems, "i2c address 0x62 is used by both SinAddress and CosAddress")
+	}
+
+	c.Board = "beaglebone"
+	c.PinOverrides = ""
+	assert.Error(t, Validate(c), "unknown boards are rejected")
+}
+
+func TestThatTheGpioBackendCanBeSelected(t *testing.T) {
+	c := Conf
+	c.Board = EdisonMiniBreakout
+	c.PinOverrides = "GpioBackend=cdev,GpioChip=/dev/gpiochip1"
+
+	pins, err := ResolvePins(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "cdev", pins.GpioBackend)
+	assert.Equal(t, "/dev/gpiochip1", pins.GpioChip)
+
+	c.PinOverrides = "GpioBackend=mmap"
+	_, err = ResolvePins(c)
+	assert.Error(t, err)
+}
+
+func TestThatThePlatformIsChecked(t *testing.T) {
+	assert.Equal(t, EdisonPlatform, Boards[EdisonArduino].Platform)
+	assert.Equal(t, GenericPlatform, Boards[RaspberryPi].Platform)
+
+	c := Conf
+	c.Board = RaspberryPi
+	c.PinOverrides = "Platform=beaglebone"
+	_, err := ResolvePins(c)
+	assert.Error(t, err)
+}
+
+func TestThatThePwmLimitsCanBeOverridden(t *testing.T) {
+	c := Conf
+	c.Board = RaspberryPi
+
+	pins, err := ResolvePins(c)
+	assert.NoError(t, err)
+	min, max, err := pins.PwmLimits()
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, min, "the limits of the platform")
+	assert.EqualValues(t, 0, max, "the limits of the platform")
+
+	c.PinOverrides = "PwmMinPeriod=100ns,PwmMaxPeriod=1s"
+	pins, err = ResolvePins(c)
+	assert.NoError(t, err)
+	min, max, err = pins.PwmLimits()
+	assert.NoError(t, err)
+	assert.Equal(t, 100*time.Nanosecond, min)
+	assert.Equal(t, time.Second, max)
+
+	c.PinOverrides = "PwmMinPeriod=1s,PwmMaxPeriod=100ns"
+	_, err = ResolvePins(c)
+	assert.Error(t, err, "the shortest period is longer than the longest one")
+
+	c.PinOverrides = "PwmMaxPeriod=-1s"
+	_, err = ResolvePins(c)
+	assert.Error(t, err)
+}
+
+func TestThatTheMicrostepPinsAreOptional(t *testing.T) {
+	c := Conf
+	c.Board = EdisonMiniBreakout
+	c.PinOverrides = "MotorMS1Pin=14,MotorMS2Pin=none"
+
+	pins, err := ResolvePins(c)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 14, pins.MotorMS1Pin)
+	assert.EqualValues(t, NoPin, pins.MotorMS2Pin)
+	assert.EqualValues(t, NoPin, pins.MotorMS3Pin)
+
+	c.PinOverrides = ""
+	c.MotorMicrostepping = 32
+	assert.Error(t, Validate(c), "the A4988 has no 1/32 mode")
+	c.MotorDriver = "drv8825"
+	assert.NoError(t, Validate(c))
+}
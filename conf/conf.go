@@ -18,61 +18,25 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-22 13:18:01
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-22 15:13:00
+* @Last Modified time: 2015-12-03 19:27:55
  */
 
 package conf
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
+
 	"github.com/spf13/viper"
 
-	"github.com/ssoudan/edisonIsThePilot/dashboard"
+	"github.com/ssoudan/edisonIsThePilot/drivers/hbridge"
+	"github.com/ssoudan/edisonIsThePilot/drivers/motor"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 )
 
 var log = logger.Log("conf")
 
-// MessagePin is the mapping of pin to a message
-type MessagePin struct {
-	Message string
-	Pin     byte
-}
-
-// MessageToPin contains the mapping of the dashboard messages to the LED pin
-var MessageToPin = []MessagePin{
-	{dashboard.NoGPSFix, 43},                // J19 - pin 11
-	{dashboard.InvalidGPSData, 48},          // J19 - pin 6
-	{dashboard.SpeedTooLow, 40},             // J19 - pin 10
-	{dashboard.HeadingErrorOutOfBounds, 82}, // J19 - pin 13
-	{dashboard.CorrectionAtLimit, 83},       // J19 - pin 14
-}
-
-const (
-	// AlarmGpioPin is the pin where the alarm is connected
-	AlarmGpioPin = 183 // J18 - pin 8
-	// AlarmGpioPWM is the pwm where the alarm is connected
-	AlarmGpioPWM = 3
-
-	// SwitchGpioPin is the pin where the autopilot switch is connected
-	SwitchGpioPin = 46 // J19 - pin 5
-
-	// MotorDirPin is the direction pin for the motor
-	MotorDirPin = 165 // J18 - pin 2
-	// MotorSleepPin is the sleep pin for the motor
-	MotorSleepPin = 12 // J18 - pin 7
-	// MotorStepPin is the step pin for the motor
-	MotorStepPin = 182 // J17 - pin 1
-	// MotorStepPwm is the pwm where the step pin of the motor is connected
-	MotorStepPwm = 2
-
-	// I2CBus of the Sin/Cos interface
-	I2CBus = 6
-	// SinAddress is the i2c address of the Sine DAC (MCP4725)
-	SinAddress = 0x62
-	// CosAddress is the i2c address of the Cosine DAC (MCP4725)
-	CosAddress = 0x63
-)
-
 // Configuration is the type of the configuration loaded from the config file
 type Configuration struct {
 	Bounds                         float64 // error bound in degree
@@ -87,43 +51,179 @@ type Configuration struct {
 	NoInputMessageTimeoutInSeconds int64
 	MinimumSpeedInKnots            float64
 	TraceSize                      uint32
+	Response                       string  // response level of the pilot: economy, normal, performance or auto
+	ResponseAutoWindowInMinutes    int64   // duration of the heading history used by the auto response level
+	TackAngleInDegrees             float64 // angle the setpoint swings through during a tack or a gybe
+	TackTurnRateInDegreesPerSecond float64 // rate at which the setpoint swings during a tack or a gybe
+	TackTimeoutInSeconds           int64   // maximum duration of a tack or a gybe before the heading error alarm is restored
+	WindSerialPort                 string  // serial port of the wind instrument - empty when it comes with the GPS messages
+	WindSerialBaud                 int     // baud rate of the serial port of the wind instrument
+	WindDataTimeoutInSeconds       int64   // duration without wind data before falling back to heading hold
+	TrackDirectory                 string  // directory where the track is persisted - empty to keep it only in memory
+	TrackFileMaxSizeInBytes        int64   // size above which a new track file is started
+	TrackQueryMaxRangeInHours      int64   // widest time range of a track query
+	ControlTokens                  string  // comma-separated API tokens granting the control role
+	ReadOnlyTokens                 string  // comma-separated API tokens granting the read-only role
+	ControlPIN                     string  // PIN opening a session with the control role
+	ReadOnlyPIN                    string  // PIN opening a session with the read-only role
+	SessionTimeoutInMinutes        int64   // duration of the sessions opened with a PIN
+	Board                          string  // name of the board profile giving the pin mapping - see Boards
+	PinOverrides                   string  // comma-separated changes of the board profile: Name=value with Name a field of PinMap, Led.<message> or Button.<button>
+	MotorDriver                    string  // stepper driver chip: a4988 or drv8825
+	MotorStepsPerRevolution        uint32  // full steps of a revolution of the motor
+	MotorMicrostepping             uint32  // microsteps per full step - selected with the MS pins or jumpers
+	MotorEnableActiveLow           bool    // true when the torque is on with the enable pin of the driver low
+	Actuator                       string  // drive of the steering: stepper or hbridge (DC motor or linear actuator)
+	HBridgeMode                    string  // inputs of the H-bridge: pwm-dir or in1-in2
+	HBridgeMaxSpeedInRPM           float64 // speed of the DC motor at full duty cycle
+	HBridgeSoftStartInMilliseconds int64   // duration of the ramp of the duty cycle at the beginning of a move
+	StallTimeoutInMilliseconds     int64   // duration of an over-current on MotorSensePin (H-bridge) or above MotorStallCurrentInAmps before a move is stopped
+	ShuntResistanceInMilliOhms     float64 // shunt of the INA219 current sensor of the motor
+	MotorOvercurrentInAmps         float64 // current of the motor disabling it right away
+	MotorStallCurrentInAmps        float64 // current of a stalled motor
+	ClutchEngageInMilliseconds     int64   // delay between the engagement of the clutch and the first move
+	ClutchReleaseInMilliseconds    int64   // delay between the release of the clutch and the disabling of the motor
+	SysfsRoot                      string  // directory where the sysfs attributes of the gpio, pwm and pinmux are found - / but on a test bench
 }
 
-func setDefaultValues() {
-	viper.SetDefault("Bounds", 25.)
-	viper.SetDefault("SteeringReductionRatio", 380/25)
-	viper.SetDefault("P", 0.104659039843542)
-	viper.SetDefault("I", 8.06799673280568e-05)
-	viper.SetDefault("D", 27.8353089535829)
-	viper.SetDefault("N", 2.23108985822891)
-	viper.SetDefault("GpsSerialPort", "/dev/ttyMFD1")
-	viper.SetDefault("NoInputMessageTimeoutInSeconds", 10)
-	viper.SetDefault("MinimumSpeedInKnots", 3)
-	viper.SetDefault("TraceSize", 500)
-	viper.SetDefault("MinPIDOutputLimits", -380.)
-	viper.SetDefault("MaxPIDOutputLimits", 380.)
+func setDefaultValues(v *viper.Viper) {
+	v.SetDefault("Bounds", 25.)
+	v.SetDefault("SteeringReductionRatio", 380/25)
+	v.SetDefault("P", 0.104659039843542)
+	v.SetDefault("I", 8.06799673280568e-05)
+	v.SetDefault("D", 27.8353089535829)
+	v.SetDefault("N", 2.23108985822891)
+	v.SetDefault("GpsSerialPort", "/dev/ttyMFD1")
+	v.SetDefault("NoInputMessageTimeoutInSeconds", 10)
+	v.SetDefault("MinimumSpeedInKnots", 3)
+	v.SetDefault("TraceSize", 500)
+	v.SetDefault("MinPIDOutputLimits", -380.)
+	v.SetDefault("MaxPIDOutputLimits", 380.)
+	v.SetDefault("Response", "normal")
+	v.SetDefault("ResponseAutoWindowInMinutes", 5)
+	v.SetDefault("TackAngleInDegrees", 100.)
+	v.SetDefault("TackTurnRateInDegreesPerSecond", 5.)
+	v.SetDefault("TackTimeoutInSeconds", 60)
+	v.SetDefault("WindSerialPort", "")
+	v.SetDefault("WindSerialBaud", 4800)
+	v.SetDefault("WindDataTimeoutInSeconds", 5)
+	v.SetDefault("TrackDirectory", "/var/lib/edisonIsThePilot/track")
+	v.SetDefault("TrackFileMaxSizeInBytes", 10*1024*1024)
+	v.SetDefault("TrackQueryMaxRangeInHours", 7*24)
+	v.SetDefault("ControlTokens", "")
+	v.SetDefault("ReadOnlyTokens", "")
+	v.SetDefault("ControlPIN", "")
+	v.SetDefault("ReadOnlyPIN", "")
+	v.SetDefault("SessionTimeoutInMinutes", 12*60)
+	v.SetDefault("Board", EdisonMiniBreakout)
+	v.SetDefault("PinOverrides", "")
+	v.SetDefault("MotorDriver", motor.A4988Chip)
+	v.SetDefault("MotorStepsPerRevolution", 200)
+	v.SetDefault("MotorMicrostepping", 1)
+	v.SetDefault("MotorEnableActiveLow", true)
+	v.SetDefault("Actuator", StepperActuator)
+	v.SetDefault("HBridgeMode", hbridge.PwmDirMode)
+	v.SetDefault("HBridgeMaxSpeedInRPM", 60.)
+	v.SetDefault("HBridgeSoftStartInMilliseconds", 200)
+	v.SetDefault("StallTimeoutInMilliseconds", 300)
+	v.SetDefault("ShuntResistanceInMilliOhms", 100.)
+	v.SetDefault("MotorOvercurrentInAmps", 3.)
+	v.SetDefault("MotorStallCurrentInAmps", 2.)
+	v.SetDefault("ClutchEngageInMilliseconds", 200)
+	v.SetDefault("ClutchReleaseInMilliseconds", 100)
+	v.SetDefault("SysfsRoot", "/")
 }
 
-func loadConfiguration() Configuration {
-	var conf Configuration
+// FileError is reported when the configuration file is missing or can't be parsed - the Configuration then has the
+// default values
+type FileError struct {
+	Err error
+}
 
+func (e FileError) Error() string {
+	return fmt.Sprintf("unable to read the configuration file: %v", e.Err)
+}
+
+// read reads the configuration file of a viper instance and decodes the Configuration - a problem with the
+// Configuration is reported before a FileError
+func read(v *viper.Viper) (Configuration, error) {
+	fileErr := v.ReadInConfig()
+
+	conf, err := decode(v)
+	if err == nil && fileErr != nil {
+		err = FileError{Err: fileErr}
+	}
+	return conf, err
+}
+
+func loadConfiguration() Configuration {
 	viper.SetConfigType("properties")
 	viper.SetConfigName("edisonIsThePilot") // name of config file (without extension)
 	viper.AddConfigPath("/etc")             // path to look for the config file in
-	setDefaultValues()
-	err := viper.ReadInConfig() // Find and read the config file
-	if err != nil {             // Handle errors reading the config file
-		log.Warning("Unable to read the configuration file /etc/edisonIsThePilot.properties. Using default values.")
+	setDefaultValues(viper.GetViper())
 
-	}
-	err = viper.Unmarshal(&conf)
-	if err != nil { // Handle errors reading the config file
-		log.Panic("Unable to load configuration. Check /etc/edisonIsThePilot.properties file")
+	conf, err := read(viper.GetViper())
+	LoadError = err
+	if err != nil {
+		log.Error("Invalid configuration in %s: %v", File(), err)
 	}
 
 	log.Info("Configuration is: %#v", conf)
 	return conf
 }
 
+// UseDefaults accepts to run with the default values when the configuration file is missing or can't be parsed:
+// LoadError is cleared when it is a FileError
+func UseDefaults() {
+	if _, ok := LoadError.(FileError); ok {
+		log.Warning("Using the default values: %v", LoadError)
+		LoadError = nil
+	}
+}
+
+// decode extracts the Configuration from a viper instance - unknown parameters, values of the wrong type
+// and values out of range are all reported in a ValidationError
+func decode(v *viper.Viper) (Configuration, error) {
+	var conf Configuration
+	var problems ValidationError
+
+	known := make(map[string]bool)
+	t := reflect.TypeOf(conf)
+	for i := 0; i < t.NumField(); i++ {
+		known[strings.ToLower(t.Field(i).Name)] = true
+	}
+	for _, key := range v.AllKeys() {
+		if !known[key] {
+			problems = append(problems, fmt.Sprintf("unknown parameter %s", key))
+		}
+	}
+
+	if err := v.Unmarshal(&conf); err != nil {
+		return conf, append(problems, err.Error())
+	}
+
+	if err := Validate(conf); err != nil {
+		problems = append(problems, err.(ValidationError)...)
+	}
+
+	if len(problems) != 0 {
+		return conf, problems
+	}
+	return conf, nil
+}
+
+// Load reads and validates a configuration file - the missing parameters get their default value
+func Load(file string) (Configuration, error) {
+	v := viper.New()
+	v.SetConfigType("properties")
+	v.SetConfigFile(file)
+	setDefaultValues(v)
+
+	return read(v)
+}
+
+// LoadError is the error found when loading Conf - Conf can't be trusted when it is not nil
+var LoadError error
+
 // Conf contains the configuration parameters loaded at the initialization from the edisonIsThePilot.properties or defaults values
 var Conf = loadConfiguration()
@@ -24,6 +24,10 @@ under the License.
 package conf
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
+
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 
@@ -46,3 +50,36 @@ func TestUnMarshallingFromFile(t *testing.T) {
 	//check a default value
 	assert.EqualValues(t, 0.104659039843542, localConf.P)
 }
+
+func TestThatAMissingFileIsReported(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("properties")
+	v.SetConfigName("edisonIsThePilot")
+	v.AddConfigPath(filepath.Join(os.TempDir(), "missing"))
+	setDefaultValues(v)
+
+	c, err := read(v)
+	assert.IsType(t, FileError{}, err)
+	//check a default value
+	assert.EqualValues(t, 0.104659039843542, c.P)
+}
+
+func TestThatAnUnparsableFileIsReported(t *testing.T) {
+	file, cleanup := writeConfiguration(t, "Bounds : 30\nP : \\u12zz")
+	defer cleanup()
+
+	_, err := Load(file)
+	assert.IsType(t, FileError{}, err)
+}
+
+func TestThatUseDefaultsOnlyAcceptsFileErrors(t *testing.T) {
+	defer func(err error) { LoadError = err }(LoadError)
+
+	LoadError = FileError{Err: errors.New("not found")}
+	UseDefaults()
+	assert.NoError(t, LoadError)
+
+	LoadError = ValidationError{"Bounds must be in ]0:180] - got -5"}
+	UseDefaults()
+	assert.Error(t, LoadError)
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-15 19:42:57
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-03 19:27:55
+ */
+
+package conf
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/ssoudan/edisonIsThePilot/drivers/hbridge"
+	"github.com/ssoudan/edisonIsThePilot/drivers/motor"
+)
+
+// DefaultFile is the configuration file used when none has been found
+const DefaultFile = "/etc/edisonIsThePilot.properties"
+
+// LiveKeys are the parameters that can be changed without restarting
+var LiveKeys = []string{
+	"P",
+	"I",
+	"D",
+	"N",
+	"MinPIDOutputLimits",
+	"MaxPIDOutputLimits",
+	"Bounds",
+	"MinimumSpeedInKnots",
+}
+
+// ValidationError lists the problems found in a Configuration
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(e, "; ")
+}
+
+// Validate checks the values of a Configuration are within their ranges
+func Validate(c Configuration) error {
+	var problems ValidationError
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Bounds > 0 && c.Bounds <= 180, "Bounds must be in ]0:180] - got %v", c.Bounds)
+	check(c.SteeringReductionRatio > 0, "SteeringReductionRatio must be positive - got %v", c.SteeringReductionRatio)
+	check(c.MinPIDOutputLimits < c.MaxPIDOutputLimits, "MinPIDOutputLimits (%v) must be lower than MaxPIDOutputLimits (%v)", c.MinPIDOutputLimits, c.MaxPIDOutputLimits)
+	check(c.P >= 0, "P must not be negative - got %v", c.P)
+	check(c.I >= 0, "I must not be negative - got %v", c.I)
+	check(c.D >= 0, "D must not be negative - got %v", c.D)
+	check(c.N > 0, "N must be positive - got %v", c.N)
+	check(c.NoInputMessageTimeoutInSeconds > 0, "NoInputMessageTimeoutInSeconds must be positive - got %v", c.NoInputMessageTimeoutInSeconds)
+	check(c.MinimumSpeedInKnots >= 0, "MinimumSpeedInKnots must not be negative - got %v", c.MinimumSpeedInKnots)
+	check(c.ResponseAutoWindowInMinutes > 0, "ResponseAutoWindowInMinutes must be positive - got %v", c.ResponseAutoWindowInMinutes)
+	check(c.TackAngleInDegrees > 0 && c.TackAngleInDegrees < 180, "TackAngleInDegrees must be in ]0:180[ - got %v", c.TackAngleInDegrees)
+	check(c.TackTurnRateInDegreesPerSecond >= 0, "TackTurnRateInDegreesPerSecond must not be negative - got %v", c.TackTurnRateInDegreesPerSecond)
+	check(c.TackTimeoutInSeconds > 0, "TackTimeoutInSeconds must be positive - got %v", c.TackTimeoutInSeconds)
+	check(c.WindSerialBaud > 0, "WindSerialBaud must be positive - got %v", c.WindSerialBaud)
+	check(c.WindDataTimeoutInSeconds > 0, "WindDataTimeoutInSeconds must be positive - got %v", c.WindDataTimeoutInSeconds)
+	check(c.TrackFileMaxSizeInBytes > 0, "TrackFileMaxSizeInBytes must be positive - got %v", c.TrackFileMaxSizeInBytes)
+	check(c.TrackQueryMaxRangeInHours > 0, "TrackQueryMaxRangeInHours must be positive - got %v", c.TrackQueryMaxRangeInHours)
+	check(c.SessionTimeoutInMinutes > 0, "SessionTimeoutInMinutes must be positive - got %v", c.SessionTimeoutInMinutes)
+	check(c.MotorStepsPerRevolution > 0, "MotorStepsPerRevolution must be positive - got %v", c.MotorStepsPerRevolution)
+	if chip, ok := motor.Chips[c.MotorDriver]; !ok {
+		problems = append(problems, fmt.Sprintf("unknown MotorDriver %s - expecting %s or %s", c.MotorDriver, motor.A4988Chip, motor.DRV8825Chip))
+	} else {
+		check(chip.Supports(c.MotorMicrostepping), "MotorMicrostepping %v is not supported by the %s", c.MotorMicrostepping, c.MotorDriver)
+	}
+	check(c.Actuator == StepperActuator || c.Actuator == HBridgeActuator, "unknown Actuator %s - expecting %s or %s", c.Actuator, StepperActuator, HBridgeActuator)
+	check(c.HBridgeMode == hbridge.PwmDirMode || c.HBridgeMode == hbridge.In1In2Mode, "unknown HBridgeMode %s - expecting %s or %s", c.HBridgeMode, hbridge.PwmDirMode, hbridge.In1In2Mode)
+	check(c.HBridgeMaxSpeedInRPM > 0, "HBridgeMaxSpeedInRPM must be positive - got %v", c.HBridgeMaxSpeedInRPM)
+	check(c.HBridgeSoftStartInMilliseconds >= 0, "HBridgeSoftStartInMilliseconds must not be negative - got %v", c.HBridgeSoftStartInMilliseconds)
+	check(c.StallTimeoutInMilliseconds > 0, "StallTimeoutInMilliseconds must be positive - got %v", c.StallTimeoutInMilliseconds)
+	check(c.ShuntResistanceInMilliOhms > 0, "ShuntResistanceInMilliOhms must be positive - got %v", c.ShuntResistanceInMilliOhms)
+	check(c.MotorStallCurrentInAmps > 0, "MotorStallCurrentInAmps must be positive - got %v", c.MotorStallCurrentInAmps)
+	check(c.MotorStallCurrentInAmps < c.MotorOvercurrentInAmps, "MotorStallCurrentInAmps (%v) must be lower than MotorOvercurrentInAmps (%v)", c.MotorStallCurrentInAmps, c.MotorOvercurrentInAmps)
+	check(c.ClutchEngageInMilliseconds >= 0, "ClutchEngageInMilliseconds must not be negative - got %v", c.ClutchEngageInMilliseconds)
+	check(c.ClutchReleaseInMilliseconds >= 0, "ClutchReleaseInMilliseconds must not be negative - got %v", c.ClutchReleaseInMilliseconds)
+	check(filepath.IsAbs(c.SysfsRoot), "SysfsRoot must be an absolute path - got %q", c.SysfsRoot)
+
+	if pins, err := ResolvePins(c); err != nil {
+		problems = append(problems, err.(ValidationError)...)
+	} else if c.Actuator == HBridgeActuator && c.HBridgeMode == hbridge.In1In2Mode {
+		check(pins.MotorIn2Pin != NoPin, "MotorIn2Pin must be wired with the %s H-bridge", hbridge.In1In2Mode)
+	}
+
+	if len(problems) != 0 {
+		return problems
+	}
+	return nil
+}
+
+// File is the configuration file in use - DefaultFile when none has been found
+func File() string {
+	if file := viper.ConfigFileUsed(); file != "" {
+		return file
+	}
+	return DefaultFile
+}
+
+// formatValue formats the value of a parameter of the Configuration as in the properties file
+func formatValue(c Configuration, key string) (string, error) {
+	v := reflect.ValueOf(c).FieldByName(key)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.String:
+		return v.String(), nil
+	}
+	return "", fmt.Errorf("unknown configuration parameter: %s", key)
+}
+
+// Save writes the values of the keys in the properties file. The other lines - comments included - are kept and the
+// previous version of the file is kept as a .bak.
+func Save(path string, c Configuration, keys []string) error {
+	original, err := ioutil.ReadFile(path)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	content := string(original)
+
+	for _, key := range keys {
+		value, err := formatValue(c, key)
+		if err != nil {
+			return err
+		}
+
+		// the keys are case-insensitive like in viper
+		line := regexp.MustCompile(`(?im)^([ \t]*` + regexp.QuoteMeta(key) + `[ \t]*[:=][ \t]*).*$`)
+		if loc := line.FindStringSubmatchIndex(content); loc != nil {
+			// keep the key, the separator and the alignment - only the value changes
+			content = content[:loc[3]] + value + content[loc[1]:]
+		} else {
+			if content != "" && !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+			content += key + " : " + value + "\n"
+		}
+	}
+
+	if exists {
+		if err := ioutil.WriteFile(path+".bak", original, 0644); err != nil {
+			return err
+		}
+	}
+
+	// write then rename so a crash never leaves a truncated file
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(content), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-15 19:42:57
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-15 19:42:57
+ */
+
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThatDefaultConfigurationIsValid(t *testing.T) {
+	assert.NoError(t, Validate(Conf))
+}
+
+func TestThatValidationReportsAllTheProblems(t *testing.T) {
+	c := Conf
+	c.MinPIDOutputLimits, c.MaxPIDOutputLimits = 10, -10
+	c.Bounds = 0
+	c.N = -1
+
+	err := Validate(c)
+	if assert.Error(t, err) {
+		assert.Len(t, err.(ValidationError), 3)
+	}
+}
+
+func TestThatSaveKeepsTheRestOfTheFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conf")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "edisonIsThePilot.properties")
+	original := "# Proportional coefficient\nP\t\t: 0.1\n# Error bound\nBounds\t: 25"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(original), 0644))
+
+	c := Conf
+	c.P = 0.25
+	c.Bounds = 20
+	c.MinimumSpeedInKnots = 2.5
+	assert.NoError(t, Save(path, c, []string{"P", "Bounds", "MinimumSpeedInKnots"}))
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "# Proportional coefficient\nP\t\t: 0.25\n# Error bound\nBounds\t: 20\nMinimumSpeedInKnots : 2.5\n", string(content))
+
+	backup, err := ioutil.ReadFile(path + ".bak")
+	assert.NoError(t, err)
+	assert.EqualValues(t, original, string(backup), "the previous version is kept")
+}
+
+func TestThatSaveIgnoresTheCaseOfTheKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conf")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "edisonIsThePilot.properties")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("p : 0.1\nminimumspeedinknots = 3\n"), 0644))
+
+	c := Conf
+	c.P = 0.25
+	c.MinimumSpeedInKnots = 2.5
+	assert.NoError(t, Save(path, c, []string{"P", "MinimumSpeedInKnots"}))
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "p : 0.25\nminimumspeedinknots = 2.5\n", string(content), "the lines are updated in place")
+}
+
+func TestThatSaveRejectsUnknownKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conf")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	assert.Error(t, Save(filepath.Join(dir, "edisonIsThePilot.properties"), Conf, []string{"Nope"}))
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-16 22:05:19
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-16 22:05:19
+ */
+
+package conf
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"syscall"
+	"time"
+)
+
+// ReloadPollingPeriod is the period at which the configuration file is checked for changes
+const ReloadPollingPeriod = 2 * time.Second
+
+// Reloader is the component that reloads the configuration file when it changes or on SIGHUP
+// and notifies the subscribers of the new configuration
+type Reloader struct {
+	file        string
+	current     Configuration
+	modTime     time.Time
+	subscribers []func(Configuration) error
+
+	// channels
+	signalChan   chan os.Signal
+	shutdownChan chan interface{}
+	panicChan    chan interface{}
+}
+
+// NewReloader creates a new Reloader for a file from which the current configuration has been loaded
+func NewReloader(file string, current Configuration) *Reloader {
+	r := &Reloader{
+		file:         file,
+		current:      current,
+		signalChan:   make(chan os.Signal, 1),
+		shutdownChan: make(chan interface{}),
+	}
+	if info, err := os.Stat(file); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+// Subscribe registers a function called with every new valid configuration - it must not block
+func (r *Reloader) Subscribe(f func(Configuration) error) {
+	r.subscribers = append(r.subscribers, f)
+}
+
+// SetPanicChan sets the channel where panics are sent
+func (r *Reloader) SetPanicChan(c chan interface{}) {
+	r.panicChan = c
+}
+
+// Shutdown stops the Reloader
+func (r *Reloader) Shutdown() {
+	r.shutdownChan <- 1
+	<-r.shutdownChan
+}
+
+func (r *Reloader) shutdown() {
+	signal.Stop(r.signalChan)
+	close(r.shutdownChan)
+}
+
+// checkFile reloads the configuration when the modification time of the file has changed
+func (r *Reloader) checkFile() {
+	info, err := os.Stat(r.file)
+	if err != nil || info.ModTime().Equal(r.modTime) {
+		return
+	}
+	r.modTime = info.ModTime()
+	r.reload()
+}
+
+// restartKeys are the parameters that changed but are only applied at startup
+func restartKeys(previous, next Configuration) []string {
+	live := make(map[string]bool)
+	for _, key := range LiveKeys {
+		live[key] = true
+	}
+
+	keys := []string{}
+	p, n := reflect.ValueOf(previous), reflect.ValueOf(next)
+	for i := 0; i < p.NumField(); i++ {
+		name := p.Type().Field(i).Name
+		if !live[name] && p.Field(i).Interface() != n.Field(i).Interface() {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+// reload loads the configuration file and notifies the subscribers - an invalid configuration is ignored
+func (r *Reloader) reload() {
+	next, err := Load(r.file)
+	if err != nil {
+		log.Error("Ignoring the new configuration in %s: %v", r.file, err)
+		return
+	}
+
+	if keys := restartKeys(r.current, next); len(keys) != 0 {
+		log.Warning("Restart to apply the changes of %v", keys)
+	}
+
+	log.Notice("Configuration reloaded from %s", r.file)
+	r.current = next
+	for _, f := range r.subscribers {
+		if err := f(next); err != nil {
+			log.Error("Failed to apply the new configuration: %v", err)
+		}
+	}
+}
+
+// Start the event loop of the Reloader component
+func (r *Reloader) Start() {
+
+	signal.Notify(r.signalChan, syscall.SIGHUP)
+
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				r.panicChan <- e
+			}
+		}()
+
+		ticker := time.NewTicker(ReloadPollingPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.checkFile()
+			case <-r.signalChan:
+				log.Info("SIGHUP received")
+				r.reload()
+			case <-r.shutdownChan:
+				r.shutdown()
+				return
+			}
+		}
+	}()
+}
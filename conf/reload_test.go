@@ -0,0 +1,124 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-16 22:05:19
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-16 22:05:19
+ */
+
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfiguration(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "conf")
+	assert.NoError(t, err)
+
+	file := filepath.Join(dir, "edisonIsThePilot.properties")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(content), 0644))
+	return file, func() { os.RemoveAll(dir) }
+}
+
+func TestThatTheShippedConfigurationIsValid(t *testing.T) {
+	_, err := Load(filepath.Join("..", "edisonIsThePilot.properties"))
+	assert.NoError(t, err)
+}
+
+func TestThatLoadUsesTheDefaultValues(t *testing.T) {
+	file, cleanup := writeConfiguration(t, "Bounds : 30")
+	defer cleanup()
+
+	c, err := Load(file)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 30, c.Bounds)
+	assert.EqualValues(t, 0.104659039843542, c.P)
+}
+
+func TestThatLoadRejectsInvalidConfigurations(t *testing.T) {
+	file, cleanup := writeConfiguration(t, "Bounds : -5\nMinPIDOutputLimits : 10\nMaxPIDOutputLimits : -10\nBoundz : 5")
+	defer cleanup()
+
+	_, err := Load(file)
+	if assert.Error(t, err) {
+		problems := err.(ValidationError)
+		assert.Len(t, problems, 3)
+		assert.Contains(t, problems[0], "boundz", "unknown parameters are reported")
+	}
+}
+
+func TestThatLoadRejectsValuesOfTheWrongType(t *testing.T) {
+	file, cleanup := writeConfiguration(t, "Bounds : twenty")
+	defer cleanup()
+
+	_, err := Load(file)
+	if assert.Error(t, err) {
+		assert.Contains(t, strings.ToLower(err.Error()), "bounds")
+	}
+}
+
+func TestThatLoadReportsMissingFiles(t *testing.T) {
+	_, err := Load(filepath.Join(os.TempDir(), "missing", "edisonIsThePilot.properties"))
+	assert.Error(t, err)
+}
+
+func TestThatReloaderNotifiesTheSubscribers(t *testing.T) {
+	file, cleanup := writeConfiguration(t, "Bounds : 30")
+	defer cleanup()
+
+	current, err := Load(file)
+	assert.NoError(t, err)
+
+	reloader := NewReloader(file, current)
+	received := []Configuration{}
+	reloader.Subscribe(func(c Configuration) error {
+		received = append(received, c)
+		return nil
+	})
+
+	reloader.checkFile()
+	assert.Len(t, received, 0, "nothing changed")
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("Bounds : 20"), 0644))
+	assert.NoError(t, os.Chtimes(file, time.Now(), reloader.modTime.Add(time.Second)))
+	reloader.checkFile()
+	if assert.Len(t, received, 1, "the file changed") {
+		assert.EqualValues(t, 20, received[0].Bounds)
+	}
+
+	assert.NoError(t, ioutil.WriteFile(file, []byte("Bounds : -20"), 0644))
+	reloader.reload()
+	assert.Len(t, received, 1, "invalid configurations are ignored")
+	assert.EqualValues(t, 20, reloader.current.Bounds)
+}
+
+func TestThatChangesNeedingARestartAreFound(t *testing.T) {
+	next := Conf
+	next.P = Conf.P + 1
+	next.TraceSize = Conf.TraceSize + 1
+
+	assert.EqualValues(t, []string{"TraceSize"}, restartKeys(Conf, next))
+}
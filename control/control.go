@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-22 11:55:49
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 12:53:12
+* @Last Modified time: 2015-11-21 16:14:37
  */
 
 package control
@@ -32,6 +32,13 @@ import (
 
 var log = logger.Log("control")
 
+const (
+	// PollingPeriod is the period of the reading of the input when no event channel is set
+	PollingPeriod = 100 * time.Millisecond
+	// ConsistencyCheckPeriod is the period of the reading of the input when the events are received
+	ConsistencyCheckPeriod = 2 * time.Second
+)
+
 // Control is a component that monitors change on a types.Readable and Enable or Disable its target
 type Control struct {
 	controlHandler types.Readable
@@ -39,6 +46,7 @@ type Control struct {
 	stateEnable    bool
 
 	// channels
+	eventChan    <-chan bool
 	shutdownChan chan interface{}
 	panicChan    chan interface{}
 }
@@ -53,6 +61,12 @@ func (c *Control) SetPanicChan(p chan interface{}) {
 	c.panicChan = p
 }
 
+// SetEventChan sets the channel where the (debounced) values of the input are received -
+// the input is then only read from time to time to check nothing has been missed
+func (c *Control) SetEventChan(e <-chan bool) {
+	c.eventChan = e
+}
+
 func (c *Control) updateControlState() error {
 
 	control := c.controlHandler
@@ -62,6 +76,12 @@ func (c *Control) updateControlState() error {
 		return err
 	}
 
+	return c.applyControlState(state)
+}
+
+func (c *Control) applyControlState(state bool) error {
+	var err error
+
 	if state != c.stateEnable {
 		if state {
 			log.Warning("Enabling the target")
@@ -102,13 +122,27 @@ func (c Control) Start() {
 			}
 		}()
 
+		period := PollingPeriod
+		if c.eventChan != nil {
+			period = ConsistencyCheckPeriod
+		}
+
 		for {
 			select {
-			case <-time.After(100 * time.Millisecond):
+			case state := <-c.eventChan:
+				err := c.applyControlState(state)
+				if err != nil {
+					log.Panicf("Error while updating control: %v", err)
+				}
+			case <-time.After(period):
+				stateEnable := c.stateEnable
 				err := c.updateControlState()
 				if err != nil {
 					log.Panicf("Error while updating control: %v", err)
 				}
+				if c.eventChan != nil && c.stateEnable != stateEnable {
+					log.Warning("Missed a change of the input - now %v", c.stateEnable)
+				}
 			case <-c.shutdownChan:
 				c.shutdown()
 				return
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-22 11:55:49
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-09-23 11:27:23
+* @Last Modified time: 2015-11-21 16:14:37
  */
 package control
 
@@ -104,6 +104,45 @@ var _ = Describe("Control", func() {
 
 })
 
+var _ = Describe("Control with an event channel", func() {
+
+	var (
+		control   *Control
+		input     *testHandler
+		pilot     *testPilot
+		eventChan chan bool
+	)
+
+	BeforeEach(func() {
+		input = &testHandler{value: false, err: nil}
+		pilot = &testPilot{}
+		eventChan = make(chan bool, 1)
+		control = New(input, pilot)
+		control.SetEventChan(eventChan)
+
+		control.Start()
+	})
+
+	AfterEach(func() {
+		control.Shutdown()
+	})
+
+	It("reacts to the events without waiting for the next reading", func() {
+		eventChan <- true
+		Eventually(func() bool { return pilot.state }, PollingPeriod).Should(BeTrue())
+
+		eventChan <- false
+		Eventually(func() bool { return pilot.state }, PollingPeriod).Should(BeFalse())
+	})
+
+	It("only reads the input from time to time", func() {
+		input.value = true
+		Consistently(func() bool { return pilot.state }, 5*PollingPeriod).Should(BeFalse())
+		Eventually(func() bool { return pilot.state }, 2*ConsistencyCheckPeriod).Should(BeTrue())
+	})
+
+})
+
 type testPilot struct {
 	state bool
 }
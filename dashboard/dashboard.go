@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-20 16:30:19
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 13:13:43
+* @Last Modified time: 2015-12-01 21:12:40
  */
 
 package dashboard
@@ -35,6 +35,8 @@ const (
 	SpeedTooLow             = "SpeedTooLow"
 	HeadingErrorOutOfBounds = "HeadingErrorOutOfBounds"
 	CorrectionAtLimit       = "CorrectionAtLimit"
+	WindDataStale           = "WindDataStale"
+	MotorFault              = "MotorFault"
 )
 
 var log = logger.Log("dashboard")
@@ -0,0 +1,394 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-05 18:41:26
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-22 20:31:45
+ */
+
+package diagnostics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/drivers/board"
+	"github.com/ssoudan/edisonIsThePilot/drivers/gpio"
+	"github.com/ssoudan/edisonIsThePilot/drivers/i2c"
+	"github.com/ssoudan/edisonIsThePilot/drivers/mcp4725"
+	"github.com/ssoudan/edisonIsThePilot/drivers/pwm"
+	"github.com/ssoudan/edisonIsThePilot/drivers/sincos"
+)
+
+// durations of the steps of the checks - shortened by the tests
+var (
+	ledPeriod   = 500 * time.Millisecond // time each LED is on
+	alarmPeriod = 2 * time.Second        // time the alarm beeps
+	jogDuration = 250 * time.Millisecond // time the motor turns each way - a quarter of a revolution
+	dacPeriod   = 50 * time.Millisecond  // time each course of the DAC sweep is held
+)
+
+// minimumSentenceRate is the rate of the NMEA sentences (per second) below which the GPS fails
+const minimumSentenceRate = 1.
+
+// Device is a device of the i2c bus
+type Device struct {
+	Name    string
+	Address byte
+}
+
+// Devices returns the i2c devices of a pin mapping
+func Devices(pins conf.PinMap) []Device {
+	devices := []Device{{"sin DAC", pins.SinAddress}, {"cos DAC", pins.CosAddress}}
+	if pins.INA219Address != conf.NoPin {
+		devices = append(devices, Device{"current sensor", pins.INA219Address})
+	}
+	return devices
+}
+
+// pins returns the gpio of a pin mapping - the optional ones when they are wired
+func pins(m conf.PinMap) []byte {
+	list := []byte{}
+	for _, l := range m.Leds {
+		list = append(list, l.Pin)
+	}
+	for _, b := range m.Buttons {
+		list = append(list, b.Pin)
+	}
+	list = append(list, m.AlarmGpioPin, m.SwitchGpioPin, m.MotorDirPin, m.MotorSleepPin, m.MotorStepPin)
+	for _, pin := range []byte{m.MotorMS1Pin, m.MotorMS2Pin, m.MotorMS3Pin, m.MotorIn2Pin, m.MotorSensePin, m.ClutchPin} {
+		if pin != conf.NoPin {
+			list = append(list, pin)
+		}
+	}
+	return list
+}
+
+// output creates an output gpio - disabled. The gpio is exported if needed and release unexports it in that case only
+// so that the pilot can export it again afterward.
+func output(pin byte) (g gpio.Gpio, release func(), err error) {
+	if err := gpio.EnableGPIO(pin); err != nil {
+		return nil, nil, err
+	}
+
+	g = gpio.New(pin)
+	release = func() {}
+	if !g.IsExported() {
+		if err := g.Export(); err != nil {
+			return nil, nil, err
+		}
+		release = func() {
+			if err := g.Unexport(); err != nil {
+				log.Warning("Failed to unexport gpio%d: %v", pin, err)
+			}
+		}
+	}
+	if err := g.SetDirection(gpio.OutDirection); err != nil {
+		release()
+		return nil, nil, err
+	}
+	if err := g.Disable(); err != nil {
+		release()
+		return nil, nil, err
+	}
+	return g, release, nil
+}
+
+// GpioExport checks all the gpio of a pin mapping can be exported - the ones which were not are unexported afterward
+func GpioExport(m conf.PinMap) Check {
+	return Check{Name: "gpio export", Run: func() (string, error) {
+		list := pins(m)
+		for _, pin := range list {
+			if err := gpio.EnableGPIO(pin); err != nil {
+				return "", fmt.Errorf("gpio%d: %v", pin, err)
+			}
+
+			g := gpio.New(pin)
+			if g.IsExported() {
+				continue
+			}
+			if err := g.Export(); err != nil {
+				return "", fmt.Errorf("gpio%d: %v", pin, err)
+			}
+			if !g.IsExported() {
+				return "", fmt.Errorf("gpio%d is not exported", pin)
+			}
+			if err := g.Unexport(); err != nil {
+				return "", fmt.Errorf("gpio%d: %v", pin, err)
+			}
+		}
+		return fmt.Sprintf("%d gpio", len(list)), nil
+	}}
+}
+
+// blink lights a LED for ledPeriod
+func blink(l conf.MessagePin) error {
+	g, release, err := output(l.Pin)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := g.Enable(); err != nil {
+		return err
+	}
+	log.Info("[DIAGNOSTICS] %s LED is ON", l.Message)
+	time.Sleep(ledPeriod)
+	return g.Disable()
+}
+
+// Leds lights the LEDs of the dashboard one after the other - the gpio it exported are unexported afterward
+func Leds(m conf.PinMap, confirm Confirm) Check {
+	return Check{Name: "leds", Run: func() (string, error) {
+		for _, l := range m.Leds {
+			if err := blink(l); err != nil {
+				return "", fmt.Errorf("%s LED: %v", l.Message, err)
+			}
+		}
+		if err := confirmed(confirm, fmt.Sprintf("Did the %d LEDs light up one after the other?", len(m.Leds))); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d LEDs", len(m.Leds)), nil
+	}}
+}
+
+// Alarm sounds the alarm - the pwm is unexported afterward when it was not exported
+func Alarm(m conf.PinMap, confirm Confirm) Check {
+	return Check{Name: "alarm", Run: func() (string, error) {
+		p, err := pwm.New(m.AlarmGpioPWM, m.AlarmGpioPin)
+		if err != nil {
+			return "", err
+		}
+		if !p.IsExported() {
+			if err := p.Export(); err != nil {
+				return "", err
+			}
+			defer func() {
+				if err := p.Unexport(); err != nil {
+					log.Warning("Failed to unexport pwm%d: %v", m.AlarmGpioPWM, err)
+				}
+			}()
+		}
+		if err := p.Disable(); err != nil {
+			return "", err
+		}
+		if err := p.SetPeriodAndDutyCycle(200*time.Millisecond, 0.5); err != nil {
+			return "", err
+		}
+		if err := p.Enable(); err != nil {
+			return "", err
+		}
+		log.Info("[DIAGNOSTICS] alarm is ON")
+		time.Sleep(alarmPeriod)
+		if err := p.Disable(); err != nil {
+			return "", err
+		}
+		log.Info("[DIAGNOSTICS] alarm is OFF")
+
+		if err := confirmed(confirm, "Did the alarm beep?"); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("pwm%d", m.AlarmGpioPWM), nil
+	}}
+}
+
+// MotorJog turns the motor a quarter of a revolution both ways - it is only run when the operator confirms the steering is clear
+func MotorJog(newActuator func() (board.Actuator, error), confirm Confirm) Check {
+	return Check{Name: "motor jog", Run: func() (string, error) {
+		if confirm == nil {
+			return "", Skipped("needs an operator")
+		}
+		if !confirm("The motor is going to turn a quarter of a revolution both ways. Is the steering clear?") {
+			return "", Skipped("the steering is not clear")
+		}
+
+		actuator, err := newActuator()
+		if err != nil {
+			return "", err
+		}
+		defer actuator.Unexport()
+
+		if err := actuator.Enable(); err != nil {
+			return "", err
+		}
+		defer actuator.Disable()
+
+		// a quarter of a revolution in jogDuration
+		speed := uint32(float64(actuator.StepsPerRevolution()) / 4 / jogDuration.Seconds())
+		for _, clockwise := range []bool{true, false} {
+			if err := actuator.Move(clockwise, speed, jogDuration); err != nil {
+				return "", err
+			}
+		}
+
+		if err := confirmed(confirm, "Did the motor turn a quarter of a revolution clockwise and back?"); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d steps per revolution", actuator.StepsPerRevolution()), nil
+	}}
+}
+
+// I2CProbe checks the devices answer on the i2c bus
+func I2CProbe(open func() (i2c.Bus, error), devices []Device) Check {
+	return Check{Name: "i2c probe", Run: func() (string, error) {
+		bus, err := open()
+		if err != nil {
+			return "", err
+		}
+		defer bus.Close()
+
+		missing := []string{}
+		for _, d := range devices {
+			if _, err := bus.ReadRegister(d.Address, 0); err != nil {
+				missing = append(missing, fmt.Sprintf("%s (%#x): %v", d.Name, d.Address, err))
+			}
+		}
+		if len(missing) != 0 {
+			return "", fmt.Errorf("no answer from %s", strings.Join(missing, ", "))
+		}
+		return fmt.Sprintf("%d devices", len(devices)), nil
+	}}
+}
+
+// DacSweep sweeps the course given to the autopilot through the Sin/Cos DACs over a full turn
+func DacSweep(open func() (i2c.Bus, error), sinAddress, cosAddress byte, confirm Confirm) Check {
+	return Check{Name: "dac sweep", Run: func() (string, error) {
+		bus, err := open()
+		if err != nil {
+			return "", err
+		}
+		defer bus.Close()
+
+		sin := mcp4725.New(bus, sinAddress)
+		cos := mcp4725.New(bus, cosAddress)
+		courses := 0
+		for course := uint16(0); course <= 360; course += 10 {
+			s, c := sincos.ToSinCos(course % 360)
+			if err := sin.SetValue(s); err != nil {
+				return "", fmt.Errorf("sin DAC: %v", err)
+			}
+			if err := cos.SetValue(c); err != nil {
+				return "", fmt.Errorf("cos DAC: %v", err)
+			}
+			courses++
+			time.Sleep(dacPeriod)
+		}
+
+		if err := confirmed(confirm, "Did the course shown by the autopilot sweep a full turn?"); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%d courses", courses), nil
+	}}
+}
+
+// sentenceFields checks the prefix and the checksum of an NMEA sentence and returns its fields - the sentences the
+// pilot does not use are checked too
+func sentenceFields(line string) ([]string, error) {
+	if !strings.HasPrefix(line, "$") {
+		return nil, fmt.Errorf("no $ prefix")
+	}
+	star := strings.LastIndex(line, "*")
+	if star < 0 || len(line) != star+3 {
+		return nil, fmt.Errorf("no checksum")
+	}
+
+	checksum, err := strconv.ParseUint(line[star+1:], 16, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum %s", line[star+1:])
+	}
+	var sum byte
+	for i := 1; i < star; i++ {
+		sum ^= line[i]
+	}
+	if byte(checksum) != sum {
+		return nil, fmt.Errorf("checksum %02X instead of %02X", checksum, sum)
+	}
+	return strings.Split(line[1:star], ","), nil
+}
+
+// GPS reads the NMEA sentences for a period - the GPS fails when there are less than one sentence per second or no fix
+func GPS(open func() (io.ReadCloser, error), period time.Duration) Check {
+	return Check{Name: "gps", Run: func() (string, error) {
+		port, err := open()
+		if err != nil {
+			return "", err
+		}
+
+		lines := make(chan string)
+		go func() {
+			defer close(lines)
+			reader := bufio.NewReader(port)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				lines <- strings.TrimSpace(line)
+			}
+		}()
+
+		sentences, invalid, fix, satellites := 0, 0, -1, "0"
+		timeout := time.After(period)
+	read:
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					break read
+				}
+				fields, err := sentenceFields(line)
+				if err != nil {
+					invalid++
+					continue
+				}
+				sentences++
+				// fix quality and number of satellites of the GGA sentences of any talker
+				if strings.HasSuffix(fields[0], "GGA") && len(fields) > 7 {
+					if quality, err := strconv.Atoi(fields[6]); err == nil {
+						fix, satellites = quality, fields[7]
+					}
+				}
+			case <-timeout:
+				break read
+			}
+		}
+		// unblocks the reader
+		port.Close()
+		go func() {
+			for range lines {
+			}
+		}()
+
+		rate := float64(sentences) / period.Seconds()
+		details := fmt.Sprintf("%.1f sentences/s, %d invalid, fix quality %d, %s satellites", rate, invalid, fix, satellites)
+		switch {
+		case rate < minimumSentenceRate:
+			return "", fmt.Errorf("not enough sentences: %s", details)
+		case fix < 0:
+			return "", fmt.Errorf("no GGA sentence: %s", details)
+		case fix == 0:
+			return "", fmt.Errorf("no fix: %s", details)
+		}
+		return details, nil
+	}}
+}
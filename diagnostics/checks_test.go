@@ -0,0 +1,209 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-05 18:41:26
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-22 20:31:45
+ */
+
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/drivers/board"
+	"github.com/ssoudan/edisonIsThePilot/drivers/i2c"
+	"github.com/ssoudan/edisonIsThePilot/drivers/sysfs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeActuator struct {
+	enabled  bool
+	moves    []bool
+	unexport bool
+}
+
+func (a *fakeActuator) Enable() error  { a.enabled = true; return nil }
+func (a *fakeActuator) Disable() error { a.enabled = false; return nil }
+func (a *fakeActuator) Unexport()      { a.unexport = true }
+
+func (a *fakeActuator) StepsPerRevolution() uint32 { return 200 }
+
+func (a *fakeActuator) Move(clockwise bool, stepsBySecond uint32, duration time.Duration) error {
+	a.moves = append(a.moves, clockwise)
+	return nil
+}
+
+// sentence adds the checksum to a NMEA sentence
+func sentence(body string) string {
+	checksum := byte(0)
+	for i := 0; i < len(body); i++ {
+		checksum ^= body[i]
+	}
+	return fmt.Sprintf("$%s*%02X\r\n", body, checksum)
+}
+
+var _ = Describe("checks", func() {
+
+	var (
+		fake *sysfs.Fake
+		pins conf.PinMap
+		yes  Confirm
+	)
+
+	BeforeEach(func() {
+		fake = sysfs.NewFake()
+		sysfs.Set(fake)
+		pins = conf.Boards[conf.EdisonMiniBreakout]
+		yes = func(string) bool { return true }
+
+		ledPeriod = time.Millisecond
+		alarmPeriod = time.Millisecond
+		jogDuration = time.Millisecond
+		dacPeriod = 0
+	})
+
+	AfterEach(func() {
+		sysfs.SetRoot("/")
+	})
+
+	It("exports the gpio and leaves them as they were", func() {
+		details, err := GpioExport(pins).Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(details).To(Equal(fmt.Sprintf("%d gpio", len(pins.Leds)+len(pins.Buttons)+5)))
+		Expect(fake.Exists("/sys/class/gpio/gpio43")).To(BeFalse())
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("lights the LEDs and leaves them off", func() {
+		// already used by someone else
+		first := pins.Leds[0].Pin
+		Expect(fake.WriteFile("/sys/class/gpio/export", fmt.Sprintf("%d", first))).To(Succeed())
+
+		_, err := Leds(pins, yes).Run()
+		Expect(err).NotTo(HaveOccurred())
+		v, err := fake.ReadFile(fmt.Sprintf("/sys/class/gpio/gpio%d/value", first))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(Equal("0\n"))
+		for _, l := range pins.Leds[1:] {
+			Expect(fake.Exists(fmt.Sprintf("/sys/class/gpio/gpio%d", l.Pin))).To(BeFalse(), "released for the pilot")
+		}
+		Expect(fake.Violations()).To(BeEmpty())
+
+		_, err = Leds(pins, func(string) bool { return false }).Run()
+		Expect(err).To(HaveOccurred(), "not confirmed")
+	})
+
+	It("sounds the alarm and stops it", func() {
+		_, err := Alarm(pins, yes).Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.Exists("/sys/class/pwm/pwmchip0/pwm3")).To(BeFalse(), "released for the pilot")
+		Expect(fake.Violations()).To(BeEmpty())
+
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/export", "3")).To(Succeed())
+		_, err = Alarm(pins, yes).Run()
+		Expect(err).NotTo(HaveOccurred())
+		v, err := fake.ReadFile("/sys/class/pwm/pwmchip0/pwm3/enable")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v).To(Equal("0\n"), "stopped and left exported")
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("only jogs the motor when the operator says the steering is clear", func() {
+		actuator := &fakeActuator{}
+		newActuator := func() (board.Actuator, error) { return actuator, nil }
+
+		result := run(MotorJog(newActuator, nil))
+		Expect(result.Status).To(Equal(StatusSkip))
+		result = run(MotorJog(newActuator, func(string) bool { return false }))
+		Expect(result.Status).To(Equal(StatusSkip))
+		Expect(actuator.moves).To(BeEmpty())
+
+		result = run(MotorJog(newActuator, yes))
+		Expect(result.Status).To(Equal(StatusPass))
+		Expect(actuator.moves).To(Equal([]bool{true, false}))
+		Expect(actuator.enabled).To(BeFalse())
+		Expect(actuator.unexport).To(BeTrue())
+	})
+
+	It("fails the motor jog when the actuator can't be created", func() {
+		newActuator := func() (board.Actuator, error) { return nil, errors.New("pwmchip0 only has 4 pwm - no pwm5") }
+
+		result := run(MotorJog(newActuator, yes))
+		Expect(result.Status).To(Equal(StatusFail))
+		Expect(result.Details).To(ContainSubstring("no pwm5"))
+	})
+
+	It("probes the i2c devices", func() {
+		bus := i2c.NewMock()
+		open := func() (i2c.Bus, error) { return bus, nil }
+
+		_, err := I2CProbe(open, Devices(pins)).Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bus.Transfers()).To(HaveLen(2))
+
+		bus.Err = errors.New("nack")
+		_, err = I2CProbe(open, Devices(pins)).Run()
+		Expect(err).To(MatchError(ContainSubstring("sin DAC (0x62)")))
+	})
+
+	It("sweeps the DACs over a full turn", func() {
+		bus := i2c.NewMock()
+		details, err := DacSweep(func() (i2c.Bus, error) { return bus, nil }, 0x62, 0x63, yes).Run()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(details).To(Equal("37 courses"))
+		Expect(bus.Transfers()).To(HaveLen(2 * 37))
+	})
+
+	Describe("gps", func() {
+
+		open := func(sentences ...string) func() (io.ReadCloser, error) {
+			return func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader(strings.Join(sentences, ""))), nil
+			}
+		}
+		gga := sentence("GPGGA,123519,4807.038,N,01131.000,E,1,08,0.9,545.4,M,46.9,M,,")
+		noFix := sentence("GPGGA,123519,,,,,0,00,,,M,,M,,")
+		rmc := sentence("GPRMC,123519,A,4807.038,N,01131.000,E,022.4,084.4,230394,003.1,W")
+
+		It("passes with enough sentences and a fix", func() {
+			badChecksum := strings.Replace(gga, "*", "0*", 1)
+			details, err := GPS(open(gga, rmc, gga, rmc, "GPGGA,garbage\r\n", badChecksum), time.Second).Run()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(details).To(Equal("4.0 sentences/s, 2 invalid, fix quality 1, 08 satellites"))
+		})
+
+		It("fails without a fix", func() {
+			_, err := GPS(open(noFix, rmc, noFix, rmc), time.Second).Run()
+			Expect(err).To(MatchError(ContainSubstring("no fix")))
+		})
+
+		It("fails when the GPS is too slow", func() {
+			_, err := GPS(open(gga), 2*time.Second).Run()
+			Expect(err).To(MatchError(ContainSubstring("not enough sentences")))
+		})
+	})
+})
@@ -0,0 +1,189 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-05 18:41:26
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-05 18:41:26
+ */
+
+// Package diagnostics runs a test plan of the hardware of the pilot and reports the outcome of each check.
+package diagnostics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+var log = logger.Log("diagnostics")
+
+// Status is the outcome of a check
+type Status string
+
+// Outcomes of a check
+const (
+	StatusPass Status = "pass"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Check is a step of the test plan - Run returns the details of the outcome
+type Check struct {
+	Name string
+	Run  func() (string, error)
+}
+
+// skipped is the error of a check which has not been run
+type skipped string
+
+func (s skipped) Error() string {
+	return string(s)
+}
+
+// Skipped is returned by the checks which can't be run - e.g. without an operator to confirm what they see
+func Skipped(reason string) error {
+	return skipped(reason)
+}
+
+// Confirm asks a yes/no question to the operator - nil when there is nobody to answer
+type Confirm func(question string) bool
+
+// NewConfirm creates a Confirm asking the questions on out and reading the answers from in
+func NewConfirm(in io.Reader, out io.Writer) Confirm {
+	reader := bufio.NewReader(in)
+	return func(question string) bool {
+		for {
+			fmt.Fprintf(out, "%s [y/n] ", question)
+			answer, err := reader.ReadString('\n')
+			if err != nil {
+				return false
+			}
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "y", "yes":
+				return true
+			case "n", "no":
+				return false
+			}
+		}
+	}
+}
+
+// confirmed returns an error when the operator does not confirm - nothing is asked when there is nobody to answer
+func confirmed(confirm Confirm, question string) error {
+	if confirm != nil && !confirm(question) {
+		return fmt.Errorf("not confirmed by the operator: %s", question)
+	}
+	return nil
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Name                   string `json:"name"`
+	Status                 Status `json:"status"`
+	Details                string `json:"details,omitempty"`
+	DurationInMilliseconds int64  `json:"durationInMilliseconds"`
+}
+
+// Report is the outcome of a test plan
+type Report struct {
+	Version string         `json:"version"`
+	Board   string         `json:"board"`
+	Date    types.JSONTime `json:"date"`
+	Passed  bool           `json:"passed"` // true when no check failed
+	Results []Result       `json:"results"`
+}
+
+// run runs a check - a panic is a failure
+func run(check Check) (result Result) {
+	start := time.Now()
+	result.Name = check.Name
+
+	defer func() {
+		if r := recover(); r != nil {
+			result.Status = StatusFail
+			result.Details = fmt.Sprintf("panic: %v", r)
+		}
+		result.DurationInMilliseconds = int64(time.Since(start) / time.Millisecond)
+	}()
+
+	details, err := check.Run()
+	switch err.(type) {
+	case nil:
+		result.Status = StatusPass
+		result.Details = details
+	case skipped:
+		result.Status = StatusSkip
+		result.Details = err.Error()
+	default:
+		result.Status = StatusFail
+		result.Details = err.Error()
+	}
+	return
+}
+
+// Run runs the checks in order
+func Run(version, board string, checks ...Check) Report {
+	report := Report{Version: version, Board: board, Date: types.JSONTime(time.Now()), Passed: true, Results: []Result{}}
+
+	for _, check := range checks {
+		log.Info("[DIAGNOSTICS] %s...", check.Name)
+		result := run(check)
+		log.Info("[DIAGNOSTICS] %s: %s %s", result.Name, result.Status, result.Details)
+
+		report.Results = append(report.Results, result)
+		if result.Status == StatusFail {
+			report.Passed = false
+		}
+	}
+	return report
+}
+
+// Count returns the number of checks with a given status
+func (r Report) Count(status Status) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteJSON writes the report in JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	return encoder.Encode(r)
+}
+
+// WriteText writes the report as a table
+func (r Report) WriteText(w io.Writer) error {
+	t := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(t, "Diagnostics of the %s board - version %s - %s\n\n", r.Board, r.Version, time.Time(r.Date).Format(time.RFC3339))
+	for _, result := range r.Results {
+		fmt.Fprintf(t, "%s\t%s\t%s\n", strings.ToUpper(string(result.Status)), result.Name, result.Details)
+	}
+	fmt.Fprintf(t, "\n%d checks: %d passed, %d failed, %d skipped\n", len(r.Results), r.Count(StatusPass), r.Count(StatusFail), r.Count(StatusSkip))
+	return t.Flush()
+}
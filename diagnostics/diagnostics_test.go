@@ -0,0 +1,104 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-05 18:41:26
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-05 18:41:26
+ */
+
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("report", func() {
+
+	checks := []Check{
+		{Name: "passing", Run: func() (string, error) { return "all good", nil }},
+		{Name: "failing", Run: func() (string, error) { return "", errors.New("broken") }},
+		{Name: "skipped", Run: func() (string, error) { return "", Skipped("nobody there") }},
+		{Name: "panicking", Run: func() (string, error) { panic("boom") }},
+	}
+
+	It("runs all the checks", func() {
+		report := Run("1.0", "edison-mini-breakout", checks...)
+
+		Expect(report.Passed).To(BeFalse())
+		Expect(report.Results).To(HaveLen(4))
+		Expect(report.Results[0].Status).To(Equal(StatusPass))
+		Expect(report.Results[0].Details).To(Equal("all good"))
+		Expect(report.Results[1].Status).To(Equal(StatusFail))
+		Expect(report.Results[1].Details).To(Equal("broken"))
+		Expect(report.Results[2].Status).To(Equal(StatusSkip))
+		Expect(report.Results[3].Status).To(Equal(StatusFail))
+		Expect(report.Results[3].Details).To(Equal("panic: boom"))
+	})
+
+	It("passes when no check fails", func() {
+		report := Run("1.0", "edison-mini-breakout", checks[0], checks[2])
+		Expect(report.Passed).To(BeTrue())
+	})
+
+	It("is written in JSON", func() {
+		var b bytes.Buffer
+		Expect(Run("1.0", "raspberry-pi", checks[0]).WriteJSON(&b)).To(Succeed())
+
+		var decoded map[string]interface{}
+		Expect(json.Unmarshal(b.Bytes(), &decoded)).To(Succeed())
+		Expect(decoded["version"]).To(Equal("1.0"))
+		Expect(decoded["board"]).To(Equal("raspberry-pi"))
+		Expect(decoded["passed"]).To(Equal(true))
+		Expect(decoded["results"]).To(HaveLen(1))
+		Expect(decoded["results"].([]interface{})[0]).To(HaveKeyWithValue("status", "pass"))
+	})
+
+	It("is written as text", func() {
+		var b bytes.Buffer
+		Expect(Run("1.0", "raspberry-pi", checks[:3]...).WriteText(&b)).To(Succeed())
+
+		Expect(b.String()).To(MatchRegexp(`PASS\s+passing\s+all good`))
+		Expect(b.String()).To(MatchRegexp(`FAIL\s+failing\s+broken`))
+		Expect(b.String()).To(ContainSubstring("3 checks: 1 passed, 1 failed, 1 skipped"))
+	})
+})
+
+var _ = Describe("confirm", func() {
+
+	It("asks until it gets an answer", func() {
+		var out bytes.Buffer
+		confirm := NewConfirm(strings.NewReader("maybe\nY\nno\n"), &out)
+
+		Expect(confirm("Is it on?")).To(BeTrue())
+		Expect(strings.Count(out.String(), "Is it on? [y/n] ")).To(Equal(2))
+		Expect(confirm("Is it off?")).To(BeFalse())
+		Expect(confirm("Anybody there?")).To(BeFalse(), "no more answer")
+	})
+
+	It("fails the checks which are not confirmed", func() {
+		Expect(confirmed(nil, "Is it on?")).To(Succeed())
+		Expect(confirmed(func(string) bool { return true }, "Is it on?")).To(Succeed())
+		Expect(confirmed(func(string) bool { return false }, "Is it on?")).To(MatchError(ContainSubstring("Is it on?")))
+	})
+})
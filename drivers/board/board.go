@@ -0,0 +1,165 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-23 21:27:14
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-03 19:27:55
+ */
+
+// Package board selects the drivers matching the board a program runs on.
+package board
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/drivers/gpio"
+	"github.com/ssoudan/edisonIsThePilot/drivers/hbridge"
+	"github.com/ssoudan/edisonIsThePilot/drivers/i2c"
+	"github.com/ssoudan/edisonIsThePilot/drivers/motor"
+	"github.com/ssoudan/edisonIsThePilot/drivers/pwm"
+	"github.com/ssoudan/edisonIsThePilot/drivers/sysfs"
+	"github.com/ssoudan/edisonIsThePilot/steering"
+)
+
+// Setup selects the gpio backend, the pinmux (of the gpio, pwm and i2c), the pwm controller and the sysfs root of a configuration
+func Setup(c conf.Configuration, pins conf.PinMap) error {
+	if err := gpio.SelectBackend(pins.GpioBackend, pins.GpioChip); err != nil {
+		return err
+	}
+
+	var chip pwm.Chip
+	switch pins.Platform {
+	case conf.EdisonPlatform:
+		if err := gpio.SelectPinmux(gpio.EdisonPinmux); err != nil {
+			return err
+		}
+		chip = pwm.Edison(pins.PwmChip)
+		i2c.SetPinmux(func(bus byte) error {
+			if bus != pins.I2CBus {
+				return nil // the pins of the other buses are not known: used as they are
+			}
+			if err := gpio.EnableI2C(pins.I2CSclPin); err != nil {
+				return err
+			}
+			if err := gpio.EnableI2C(pins.I2CSdaPin); err != nil {
+				return err
+			}
+			return gpio.EnableFastI2C(bus)
+		})
+	case conf.GenericPlatform:
+		if err := gpio.SelectPinmux(gpio.NoPinmux); err != nil {
+			return err
+		}
+		chip = pwm.Generic(pins.PwmChip)
+		i2c.SetPinmux(func(bus byte) error { return nil })
+	default:
+		return fmt.Errorf("unknown platform: %s", pins.Platform)
+	}
+
+	// sysfs does not tell the limits of the periods: they come from the platform unless they are overridden
+	min, max, err := pins.PwmLimits()
+	if err != nil {
+		return err
+	}
+	if min != 0 {
+		chip.MinPeriod = min
+	}
+	if max != 0 {
+		chip.MaxPeriod = max
+	}
+	pwm.SetChip(chip)
+
+	sysfs.SetRoot(c.SysfsRoot)
+	return nil
+}
+
+// MotorConfig returns the wiring and the settings of the stepper motor driver of a configuration
+func MotorConfig(c conf.Configuration, pins conf.PinMap) motor.Config {
+	return motor.Config{
+		Chip:               motor.Chips[c.MotorDriver],
+		StepsPerRevolution: c.MotorStepsPerRevolution,
+		Microsteps:         c.MotorMicrostepping,
+		EnableActiveLow:    c.MotorEnableActiveLow,
+		StepPin:            pins.MotorStepPin,
+		StepPwm:            pins.MotorStepPwm,
+		DirPin:             pins.MotorDirPin,
+		EnablePin:          pins.MotorSleepPin,
+		MicrostepPins:      [3]byte{pins.MotorMS1Pin, pins.MotorMS2Pin, pins.MotorMS3Pin},
+	}
+}
+
+// HBridgeConfig returns the wiring and the settings of the H-bridge of a configuration
+func HBridgeConfig(c conf.Configuration, pins conf.PinMap) hbridge.Config {
+	return hbridge.Config{
+		Mode:            c.HBridgeMode,
+		MaxSpeed:        c.HBridgeMaxSpeedInRPM / 60,
+		SoftStart:       time.Duration(c.HBridgeSoftStartInMilliseconds) * time.Millisecond,
+		StallTimeout:    time.Duration(c.StallTimeoutInMilliseconds) * time.Millisecond,
+		PwmPeriod:       hbridge.DefaultPwmPeriod,
+		EnableActiveLow: c.MotorEnableActiveLow,
+		PwmPin:          pins.MotorStepPin,
+		Pwm:             pins.MotorStepPwm,
+		DirPin:          pins.MotorDirPin,
+		In2Pin:          pins.MotorIn2Pin,
+		EnablePin:       pins.MotorSleepPin,
+		CurrentSensePin: pins.MotorSensePin,
+	}
+}
+
+// Actuator is the drive of the steering
+type Actuator interface {
+	steering.Actionner
+	Unexport()
+}
+
+// NewActuator creates the drive of the steering of a configuration: a stepper motor or a DC motor through an H-bridge
+func NewActuator(c conf.Configuration, pins conf.PinMap) (Actuator, error) {
+	if c.Actuator == conf.HBridgeActuator {
+		h, err := hbridge.New(HBridgeConfig(c, pins))
+		if err != nil {
+			return nil, err
+		}
+		return h, nil
+	}
+
+	m, err := motor.New(MotorConfig(c, pins))
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewClutch creates the output of the clutch of the drive unit - released
+func NewClutch(pins conf.PinMap) (gpio.Gpio, error) {
+	if err := gpio.EnableGPIO(pins.ClutchPin); err != nil {
+		return nil, err
+	}
+
+	g := gpio.New(pins.ClutchPin)
+	if !g.IsExported() {
+		if err := g.Export(); err != nil {
+			return nil, err
+		}
+	}
+	if err := g.SetDirection(gpio.OutDirection); err != nil {
+		return nil, err
+	}
+	return g, g.Disable()
+}
@@ -0,0 +1,379 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-19 22:51:08
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-21 16:14:37
+ */
+
+package gpio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Linux GPIO character device uAPI (v2) - see include/uapi/linux/gpio.h
+const (
+	gpioMaxNameSize     = 32
+	gpioV2LinesMax      = 64
+	gpioV2LineNumAttrs  = 10
+	gpioV2LineEventSize = 48
+
+	gpioV2LineFlagActiveLow     = 1 << 1
+	gpioV2LineFlagInput         = 1 << 2
+	gpioV2LineFlagOutput        = 1 << 3
+	gpioV2LineFlagEdgeRising    = 1 << 4
+	gpioV2LineFlagEdgeFalling   = 1 << 5
+	gpioV2LineFlagBiasPullUp    = 1 << 8
+	gpioV2LineFlagBiasPullDown  = 1 << 9
+	gpioV2LineFlagBiasDisabled  = 1 << 10
+	gpioV2LineEventRisingEdge   = 1
+	gpioV2LineEventFallingEdge  = 2
+	gpioV2LineAttrIDOutputValue = 2
+)
+
+// the structures only have naturally aligned fields so their layout is the same on 32 and 64 bits
+type gpioV2LineAttribute struct {
+	id      uint32
+	padding uint32
+	value   uint64 // flags, values or debounce period depending on id
+}
+
+type gpioV2LineConfigAttribute struct {
+	attr gpioV2LineAttribute
+	mask uint64
+}
+
+type gpioV2LineConfig struct {
+	flags    uint64
+	numAttrs uint32
+	padding  [5]uint32
+	attrs    [gpioV2LineNumAttrs]gpioV2LineConfigAttribute
+}
+
+type gpioV2LineRequest struct {
+	offsets         [gpioV2LinesMax]uint32
+	consumer        [gpioMaxNameSize]byte
+	config          gpioV2LineConfig
+	numLines        uint32
+	eventBufferSize uint32
+	padding         [5]uint32
+	fd              int32
+}
+
+type gpioV2LineValues struct {
+	bits uint64
+	mask uint64
+}
+
+// _IOWR(0xB4, nr, size)
+func iowr(nr, size uintptr) uintptr {
+	return 3<<30 | size<<16 | 0xB4<<8 | nr
+}
+
+var (
+	gpioV2GetLineIoctl       = iowr(0x07, unsafe.Sizeof(gpioV2LineRequest{}))
+	gpioV2LineSetConfigIoctl = iowr(0x0D, unsafe.Sizeof(gpioV2LineConfig{}))
+	gpioV2LineGetValuesIoctl = iowr(0x0E, unsafe.Sizeof(gpioV2LineValues{}))
+	gpioV2LineSetValuesIoctl = iowr(0x0F, unsafe.Sizeof(gpioV2LineValues{}))
+)
+
+func ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// consumer is the name of the user of the lines shown by the kernel
+const consumer = "edisonIsThePilot"
+
+// cdevGpio is a general purpose I/O driven with the /dev/gpiochipN character device
+type cdevGpio struct {
+	chip string
+	line uint32
+
+	direction string
+	activeLow bool
+	bias      string
+	edge      string
+	value     bool // output value when the line is requested
+
+	fd int // file descriptor of the line request - -1 when not requested
+}
+
+type lineKey struct {
+	chip string
+	line uint32
+}
+
+// a line can only be requested once: the Gpio of a line is shared until it is released, like the sysfs
+// attributes of a gpio are shared by all the users of the pin
+var lines = struct {
+	sync.Mutex
+	gpios map[lineKey]*cdevGpio
+}{gpios: make(map[lineKey]*cdevGpio)}
+
+// NewCdev returns the Gpio for a line of a GPIO chip driven through its character device (e.g. /dev/gpiochip0).
+//
+// The Gpio of a line that is already requested is returned as is - IsExported() is true.
+func NewCdev(chip string, line uint8) Gpio {
+	lines.Lock()
+	defer lines.Unlock()
+
+	key := lineKey{chip: chip, line: uint32(line)}
+	if p, ok := lines.gpios[key]; ok {
+		return p
+	}
+	p := &cdevGpio{chip: chip, line: uint32(line), direction: InDirection, bias: BiasAsIs, edge: EdgeNone, fd: -1}
+	lines.gpios[key] = p
+	return p
+}
+
+// Cdev is the Backend creating the Gpio of the lines of a GPIO chip
+func Cdev(chip string) Backend {
+	return func(pin uint8) Gpio {
+		return NewCdev(chip, pin)
+	}
+}
+
+// flags are the uAPI flags of the line
+func (p *cdevGpio) flags() uint64 {
+	var flags uint64
+
+	if p.direction == OutDirection {
+		flags |= gpioV2LineFlagOutput
+	} else {
+		flags |= gpioV2LineFlagInput
+		switch p.edge {
+		case EdgeRising:
+			flags |= gpioV2LineFlagEdgeRising
+		case EdgeFalling:
+			flags |= gpioV2LineFlagEdgeFalling
+		case EdgeBoth:
+			flags |= gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling
+		}
+	}
+
+	if p.activeLow {
+		flags |= gpioV2LineFlagActiveLow
+	}
+
+	switch p.bias {
+	case BiasPullUp:
+		flags |= gpioV2LineFlagBiasPullUp
+	case BiasPullDown:
+		flags |= gpioV2LineFlagBiasPullDown
+	case BiasDisabled:
+		flags |= gpioV2LineFlagBiasDisabled
+	}
+	return flags
+}
+
+// config is the configuration of the line
+func (p *cdevGpio) config() gpioV2LineConfig {
+	config := gpioV2LineConfig{flags: p.flags()}
+	if p.direction == OutDirection {
+		var value uint64
+		if p.value {
+			value = 1
+		}
+		config.numAttrs = 1
+		config.attrs[0] = gpioV2LineConfigAttribute{
+			attr: gpioV2LineAttribute{id: gpioV2LineAttrIDOutputValue, value: value},
+			mask: 1,
+		}
+	}
+	return config
+}
+
+// IsExported returns true when the line has been requested
+func (p *cdevGpio) IsExported() bool {
+	return p.fd >= 0
+}
+
+// Export requests the line
+func (p *cdevGpio) Export() error {
+	if p.fd >= 0 {
+		return nil
+	}
+
+	chip, err := syscall.Open(p.chip, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", p.chip, err)
+	}
+	defer syscall.Close(chip)
+
+	request := gpioV2LineRequest{numLines: 1, config: p.config()}
+	request.offsets[0] = p.line
+	copy(request.consumer[:gpioMaxNameSize-1], consumer)
+
+	if err := ioctl(chip, gpioV2GetLineIoctl, unsafe.Pointer(&request)); err != nil {
+		return fmt.Errorf("failed to request line %d of %s: %v", p.line, p.chip, err)
+	}
+	p.fd = int(request.fd)
+	return nil
+}
+
+// Unexport releases the line - the next NewCdev for the line returns a new Gpio
+func (p *cdevGpio) Unexport() error {
+	lines.Lock()
+	key := lineKey{chip: p.chip, line: p.line}
+	if lines.gpios[key] == p {
+		delete(lines.gpios, key)
+	}
+	lines.Unlock()
+
+	if p.fd < 0 {
+		return nil
+	}
+	err := syscall.Close(p.fd)
+	p.fd = -1
+	return err
+}
+
+// reconfigure applies the configuration to the requested line
+func (p *cdevGpio) reconfigure() error {
+	if p.fd < 0 {
+		return nil
+	}
+	config := p.config()
+	return ioctl(p.fd, gpioV2LineSetConfigIoctl, unsafe.Pointer(&config))
+}
+
+// SetDirection defines whether this particular GPIO is used for input or output (use constants InDirection and OutDirection).
+func (p *cdevGpio) SetDirection(dir string) error {
+	if err := checkDirection(dir); err != nil {
+		return err
+	}
+	p.direction = dir
+	return p.reconfigure()
+}
+
+// SetActiveLevel set the ActiveLow or ActiveHigh level.
+func (p *cdevGpio) SetActiveLevel(level string) error {
+	if err := checkActiveLevel(level); err != nil {
+		return err
+	}
+	p.activeLow = level == ActiveLow
+	return p.reconfigure()
+}
+
+// SetBias sets the pull-up/pull-down resistors of the line.
+func (p *cdevGpio) SetBias(bias string) error {
+	switch bias {
+	case BiasAsIs, BiasPullUp, BiasPullDown, BiasDisabled:
+	default:
+		return fmt.Errorf("Incorrect bias: %s", bias)
+	}
+	p.bias = bias
+	return p.reconfigure()
+}
+
+// SetEdge selects the edges reported by WaitForEdge - the line must be an input.
+func (p *cdevGpio) SetEdge(edge string) error {
+	if err := checkEdge(edge); err != nil {
+		return err
+	}
+	if edge != EdgeNone && p.direction != InDirection {
+		return fmt.Errorf("edges are only reported for inputs")
+	}
+	p.edge = edge
+	return p.reconfigure()
+}
+
+// parseEdge decodes a struct gpio_v2_line_event
+func parseEdge(event []byte) (Edge, error) {
+	if len(event) < gpioV2LineEventSize {
+		return Edge{}, fmt.Errorf("short line event: %d bytes", len(event))
+	}
+
+	timestamp := binary.LittleEndian.Uint64(event[0:8])
+	switch binary.LittleEndian.Uint32(event[8:12]) {
+	case gpioV2LineEventRisingEdge:
+		return Edge{Rising: true, Timestamp: time.Duration(timestamp)}, nil
+	case gpioV2LineEventFallingEdge:
+		return Edge{Rising: false, Timestamp: time.Duration(timestamp)}, nil
+	}
+	return Edge{}, fmt.Errorf("unknown line event: %v", event[8:12])
+}
+
+// WaitForEdge waits for the next edge - false when the timeout expires first.
+func (p *cdevGpio) WaitForEdge(timeout time.Duration) (Edge, bool, error) {
+	if p.fd < 0 {
+		return Edge{}, false, fmt.Errorf("line %d of %s is not requested", p.line, p.chip)
+	}
+	if p.edge == EdgeNone {
+		return Edge{}, false, fmt.Errorf("no edge selected on line %d of %s", p.line, p.chip)
+	}
+
+	ready, err := waitFor(p.fd, timeout, false)
+	if err != nil || !ready {
+		return Edge{}, false, err
+	}
+
+	event := make([]byte, gpioV2LineEventSize)
+	if _, err := syscall.Read(p.fd, event); err != nil {
+		return Edge{}, false, err
+	}
+	edge, err := parseEdge(event)
+	return edge, err == nil, err
+}
+
+// Value returns the value of the GPIO
+func (p *cdevGpio) Value() (bool, error) {
+	if p.fd < 0 {
+		return false, fmt.Errorf("line %d of %s is not requested", p.line, p.chip)
+	}
+	values := gpioV2LineValues{mask: 1}
+	if err := ioctl(p.fd, gpioV2LineGetValuesIoctl, unsafe.Pointer(&values)); err != nil {
+		return false, err
+	}
+	return values.bits&1 == 1, nil
+}
+
+func (p *cdevGpio) setValue(value bool) error {
+	if p.fd < 0 {
+		return fmt.Errorf("line %d of %s is not requested", p.line, p.chip)
+	}
+	values := gpioV2LineValues{mask: 1}
+	if value {
+		values.bits = 1
+	}
+	if err := ioctl(p.fd, gpioV2LineSetValuesIoctl, unsafe.Pointer(&values)); err != nil {
+		return err
+	}
+	p.value = value
+	return nil
+}
+
+// Enable this gpio
+func (p *cdevGpio) Enable() error {
+	return p.setValue(true)
+}
+
+// Disable this gpio
+func (p *cdevGpio) Disable() error {
+	return p.setValue(false)
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-21 16:14:37
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-21 16:14:37
+ */
+
+package gpio
+
+import (
+	"time"
+)
+
+const (
+	// DefaultDebouncePeriod is how long an input has to be stable before a change is reported
+	DefaultDebouncePeriod = 20 * time.Millisecond
+	// waitPeriod is the longest wait for an edge before checking for a shutdown
+	waitPeriod = 100 * time.Millisecond
+)
+
+// Watcher reports the debounced values of an input using its edge events:
+// a value is sent once the input has been stable for the debounce period.
+type Watcher struct {
+	gpio   Gpio
+	period time.Duration
+
+	// channels
+	valueChan    chan bool
+	shutdownChan chan interface{}
+	panicChan    chan interface{}
+}
+
+// NewWatcher creates a Watcher of an input - the edges must have been selected with SetEdge
+func NewWatcher(g Gpio, period time.Duration) *Watcher {
+	return &Watcher{
+		gpio:         g,
+		period:       period,
+		valueChan:    make(chan bool, 1),
+		shutdownChan: make(chan interface{}),
+	}
+}
+
+// Values returns the channel where the debounced values are sent - only the latest one is kept
+func (w *Watcher) Values() <-chan bool {
+	return w.valueChan
+}
+
+// SetPanicChan sets the channel where panics will be sent
+func (w *Watcher) SetPanicChan(p chan interface{}) {
+	w.panicChan = p
+}
+
+// Shutdown stops the Watcher
+func (w *Watcher) Shutdown() {
+	w.shutdownChan <- 1
+	<-w.shutdownChan
+}
+
+// send replaces the value not read yet, if any
+func (w *Watcher) send(value bool) {
+	select {
+	case <-w.valueChan:
+	default:
+	}
+	w.valueChan <- value
+}
+
+// Start the event loop of the Watcher
+func (w *Watcher) Start() {
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				w.panicChan <- r
+			}
+		}()
+
+		value, err := w.gpio.Value()
+		if err != nil {
+			panic(err)
+		}
+		w.send(value)
+
+		var settled time.Time // when the last edges are over - zero when the input is stable
+		for {
+			select {
+			case <-w.shutdownChan:
+				close(w.shutdownChan)
+				return
+			default:
+			}
+
+			timeout := waitPeriod
+			if !settled.IsZero() {
+				if remaining := settled.Sub(time.Now()); remaining < timeout {
+					timeout = remaining
+				}
+			}
+
+			if timeout > 0 {
+				_, ok, err := w.gpio.WaitForEdge(timeout)
+				if err != nil {
+					panic(err)
+				}
+				if ok {
+					settled = time.Now().Add(w.period)
+					continue
+				}
+			}
+
+			if !settled.IsZero() && !time.Now().Before(settled) {
+				settled = time.Time{}
+
+				current, err := w.gpio.Value()
+				if err != nil {
+					panic(err)
+				}
+				if current != value {
+					value = current
+					w.send(value)
+				}
+			}
+		}
+	}()
+
+}
@@ -18,15 +18,15 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-18 14:10:18
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-22 14:59:12
+* @Last Modified time: 2015-11-21 16:14:37
  */
 
 package gpio
 
 import (
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"time"
 )
 
 const (
@@ -44,98 +44,115 @@ const (
 )
 
 const (
-	high = "1\n"
-	low  = "0\n"
+	// BiasAsIs keeps the bias of the line as it is
+	BiasAsIs = "as-is"
+	// BiasPullUp enables the pull-up resistor
+	BiasPullUp = "pull-up"
+	// BiasPullDown enables the pull-down resistor
+	BiasPullDown = "pull-down"
+	// BiasDisabled disables the pull-up and pull-down resistors
+	BiasDisabled = "disabled"
 )
 
 const (
-	sysfsGpioValue     = "/sys/class/gpio/gpio%d/value"
-	sysfsGpioDirection = "/sys/class/gpio/gpio%d/direction"
-	sysfsGpioDir       = "/sys/class/gpio/gpio%d/"
-	sysfsGpioExport    = "/sys/class/gpio/export"
-	sysfsGpioUnexport  = "/sys/class/gpio/unexport"
-	sysfsGpioActiveLow = "/sys/class/gpio/gpio%d/active_low"
+	// EdgeNone disables the edge events
+	EdgeNone = "none"
+	// EdgeRising reports the rising edges
+	EdgeRising = "rising"
+	// EdgeFalling reports the falling edges
+	EdgeFalling = "falling"
+	// EdgeBoth reports both edges
+	EdgeBoth = "both"
 )
 
-// Gpio is a general purpose I/O of the Edison
-type Gpio struct {
-	pin uint8
-}
+// Names of the backends
+const (
+	SysfsBackend = "sysfs"
+	CdevBackend  = "cdev"
+)
 
-// New returns a new PWM for a given pin - See Edison Breakout documentation to figure out which one you want.
-func New(pin uint8) Gpio {
-	return Gpio{pin: pin}
-}
+// ErrNotSupported is returned when a feature is not available with a backend
+var ErrNotSupported = errors.New("not supported by this gpio backend")
 
-func writeTo(filename string, content string) error {
-	return ioutil.WriteFile(filename, []byte(content), 0644)
+// Edge is a change of the value of an input
+type Edge struct {
+	Rising    bool          // false for a falling edge
+	Timestamp time.Duration // time of the event on the monotonic clock of the kernel
 }
 
-func readfrom(filename string) (string, error) {
-	dat, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return "", err
-	}
-	return string(dat), nil
+// Gpio is a general purpose I/O
+type Gpio interface {
+	// IsExported returns true when the gpio is ready to be used
+	IsExported() bool
+	// Export makes the gpio usable
+	Export() error
+	// Unexport releases the gpio
+	Unexport() error
+	// SetDirection defines whether this particular GPIO is used for input or output (use constants InDirection and OutDirection)
+	SetDirection(dir string) error
+	// SetActiveLevel set the ActiveLow or ActiveHigh level
+	SetActiveLevel(level string) error
+	// SetBias sets the pull-up/pull-down resistors of an input (use constants BiasAsIs, BiasPullUp, BiasPullDown and BiasDisabled)
+	SetBias(bias string) error
+	// SetEdge selects the edges reported by WaitForEdge (use constants EdgeNone, EdgeRising, EdgeFalling and EdgeBoth)
+	SetEdge(edge string) error
+	// WaitForEdge waits for the next edge - false when the timeout expires first
+	WaitForEdge(timeout time.Duration) (Edge, bool, error)
+	// Value returns the value of the GPIO
+	Value() (bool, error)
+	// Enable this gpio
+	Enable() error
+	// Disable this gpio
+	Disable() error
 }
 
-// IsExported returns true with the gpio is already exported and usable from sysfs.
-func (p Gpio) IsExported() bool {
-	if _, err := os.Stat(fmt.Sprintf(sysfsGpioDir, p.pin)); os.IsNotExist(err) {
-		return false
-	}
-	return true
+// Backend creates the Gpio of a pin
+type Backend func(pin uint8) Gpio
+
+var backend Backend = NewSysfs
+
+// SetBackend selects the Backend used by New
+func SetBackend(b Backend) {
+	backend = b
 }
 
-// Export the gpio to be usable from sysfs.
-func (p Gpio) Export() error {
-	return writeTo(sysfsGpioExport, fmt.Sprintf("%d", p.pin))
+// SelectBackend selects the Backend used by New from its name - chip is the character device of the cdev backend
+func SelectBackend(name, chip string) error {
+	switch name {
+	case SysfsBackend:
+		SetBackend(NewSysfs)
+	case CdevBackend:
+		SetBackend(Cdev(chip))
+	default:
+		return fmt.Errorf("unknown gpio backend: %s", name)
+	}
+	return nil
 }
 
-// Unexport the gpio from sysfs.
-func (p Gpio) Unexport() error {
-	return writeTo(sysfsGpioUnexport, fmt.Sprintf("%d", p.pin))
+// New returns a new Gpio for a given pin with the selected Backend - sysfs by default.
+// See Edison Breakout documentation to figure out which one you want.
+func New(pin uint8) Gpio {
+	return backend(pin)
 }
 
-// SetDirection defines whether this particular GPIO is used for input or output (use constants InDirection and OutDirection).
-func (p Gpio) SetDirection(dir string) error {
+func checkDirection(dir string) error {
 	if dir != InDirection && dir != OutDirection {
 		return fmt.Errorf("Incorrect direction: %s", dir)
 	}
-	return writeTo(fmt.Sprintf(sysfsGpioDirection, p.pin), dir)
+	return nil
 }
 
-// SetActiveLevel set the ActiveLow or ActiveHigh level for IN direction.
-func (p Gpio) SetActiveLevel(level string) error {
-	if level != ActiveHigh && level != ActiveLow {
-		return fmt.Errorf("Incorrect active level: %s", level)
+func checkEdge(edge string) error {
+	switch edge {
+	case EdgeNone, EdgeRising, EdgeFalling, EdgeBoth:
+		return nil
 	}
-	return writeTo(fmt.Sprintf(sysfsGpioActiveLow, p.pin), level)
+	return fmt.Errorf("Incorrect edge: %s", edge)
 }
 
-// Value returns the value of the GPIO
-func (p Gpio) Value() (bool, error) {
-	val, err := readfrom(fmt.Sprintf(sysfsGpioValue, p.pin))
-	if err != nil {
-		return false, err
-	}
-	switch val {
-	case high:
-		return true, nil
-	case low:
-		return false, nil
-	default:
-		return false, fmt.Errorf("invalid value: [%v]", val)
+func checkActiveLevel(level string) error {
+	if level != ActiveHigh && level != ActiveLow {
+		return fmt.Errorf("Incorrect active level: %s", level)
 	}
-
-}
-
-// Enable this gpio
-func (p Gpio) Enable() error {
-	return writeTo(fmt.Sprintf(sysfsGpioValue, p.pin), high)
-}
-
-// Disable this gpio
-func (p Gpio) Disable() error {
-	return writeTo(fmt.Sprintf(sysfsGpioValue, p.pin), low)
+	return nil
 }
@@ -0,0 +1,272 @@
+package gpio
+
+import (
+	"encoding/binary"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/ssoudan/edisonIsThePilot/drivers/sysfs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestGpio(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Gpio Suite")
+}
+
+var _ = Describe("gpio backend selection", func() {
+
+	AfterEach(func() {
+		SetBackend(NewSysfs)
+	})
+
+	It("uses sysfs by default", func() {
+		_, ok := New(12).(*sysfsGpio)
+		Expect(ok).To(BeTrue())
+	})
+
+	It("selects the character device backend", func() {
+		Expect(SelectBackend(CdevBackend, "/dev/gpiochip0")).To(Succeed())
+		g, ok := New(12).(*cdevGpio)
+		Expect(ok).To(BeTrue())
+		Expect(g.chip).To(Equal("/dev/gpiochip0"))
+		Expect(g.line).To(BeEquivalentTo(12))
+		Expect(g.IsExported()).To(BeFalse())
+	})
+
+	It("rejects unknown backends", func() {
+		Expect(SelectBackend("mmap", "")).NotTo(Succeed())
+	})
+
+})
+
+var _ = Describe("gpio sysfs backend", func() {
+
+	var fake *sysfs.Fake
+
+	BeforeEach(func() {
+		fake = sysfs.NewFake()
+		sysfs.Set(fake)
+	})
+
+	AfterEach(func() {
+		sysfs.SetRoot("/")
+	})
+
+	It("drives an output", func() {
+		g := NewSysfs(165)
+		Expect(g.IsExported()).To(BeFalse())
+		Expect(EnableGPIO(165)).To(Succeed())
+		Expect(g.Export()).To(Succeed())
+		Expect(g.IsExported()).To(BeTrue())
+		Expect(g.SetDirection(OutDirection)).To(Succeed())
+
+		Expect(g.Enable()).To(Succeed())
+		Expect(g.Value()).To(BeTrue())
+		Expect(g.Disable()).To(Succeed())
+		Expect(g.Value()).To(BeFalse())
+
+		Expect(g.Unexport()).To(Succeed())
+		Expect(g.IsExported()).To(BeFalse())
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("reads an input", func() {
+		g := NewSysfs(46)
+		Expect(g.Export()).To(Succeed())
+		Expect(g.SetDirection(InDirection)).To(Succeed())
+		Expect(g.SetActiveLevel(ActiveHigh)).To(Succeed())
+
+		Expect(fake.SetInput(46, true)).To(Succeed())
+		Expect(g.Value()).To(BeTrue())
+		Expect(g.SetEdge(EdgeBoth)).To(Equal(ErrNotSupported))
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("can't be enabled before being exported", func() {
+		Expect(NewSysfs(12).Enable()).NotTo(Succeed())
+		Expect(fake.Violations()).To(HaveLen(1))
+	})
+
+})
+
+var _ = Describe("gpio character device uAPI", func() {
+
+	AfterEach(func() {
+		// forget the lines created by the test
+		lines.gpios = make(map[lineKey]*cdevGpio)
+	})
+
+	It("has the layout of the kernel structures", func() {
+		Expect(unsafe.Sizeof(gpioV2LineAttribute{})).To(BeEquivalentTo(16))
+		Expect(unsafe.Sizeof(gpioV2LineConfigAttribute{})).To(BeEquivalentTo(24))
+		Expect(unsafe.Sizeof(gpioV2LineConfig{})).To(BeEquivalentTo(272))
+		Expect(unsafe.Sizeof(gpioV2LineRequest{})).To(BeEquivalentTo(592))
+		Expect(unsafe.Sizeof(gpioV2LineValues{})).To(BeEquivalentTo(16))
+	})
+
+	It("has the ioctl numbers of the kernel", func() {
+		Expect(gpioV2GetLineIoctl).To(Equal(uintptr(0xC250B407)))
+		Expect(gpioV2LineSetConfigIoctl).To(Equal(uintptr(0xC110B40D)))
+		Expect(gpioV2LineGetValuesIoctl).To(Equal(uintptr(0xC010B40E)))
+		Expect(gpioV2LineSetValuesIoctl).To(Equal(uintptr(0xC010B40F)))
+	})
+
+	It("configures an active low input with pull-up and both edges", func() {
+		g := NewCdev("/dev/gpiochip0", 3).(*cdevGpio)
+		Expect(g.SetActiveLevel(ActiveLow)).To(Succeed())
+		Expect(g.SetBias(BiasPullUp)).To(Succeed())
+		Expect(g.SetEdge(EdgeBoth)).To(Succeed())
+
+		config := g.config()
+		Expect(config.flags).To(BeEquivalentTo(gpioV2LineFlagInput | gpioV2LineFlagActiveLow | gpioV2LineFlagBiasPullUp |
+			gpioV2LineFlagEdgeRising | gpioV2LineFlagEdgeFalling))
+		Expect(config.numAttrs).To(BeEquivalentTo(0))
+	})
+
+	It("configures an output with its value", func() {
+		g := NewCdev("/dev/gpiochip0", 3).(*cdevGpio)
+		Expect(g.SetDirection(OutDirection)).To(Succeed())
+		g.value = true
+
+		config := g.config()
+		Expect(config.flags).To(BeEquivalentTo(gpioV2LineFlagOutput))
+		Expect(config.numAttrs).To(BeEquivalentTo(1))
+		Expect(config.attrs[0].attr.id).To(BeEquivalentTo(gpioV2LineAttrIDOutputValue))
+		Expect(config.attrs[0].attr.value).To(BeEquivalentTo(1))
+		Expect(config.attrs[0].mask).To(BeEquivalentTo(1))
+	})
+
+	It("only reports edges of inputs", func() {
+		g := NewCdev("/dev/gpiochip0", 3)
+		Expect(g.SetDirection(OutDirection)).To(Succeed())
+		Expect(g.SetEdge(EdgeRising)).NotTo(Succeed())
+		Expect(g.SetBias("floating")).NotTo(Succeed())
+	})
+
+	It("returns the requested line when it is created again", func() {
+		g := NewCdev("/dev/gpiochip0", 5).(*cdevGpio)
+		Expect(g.IsExported()).To(BeFalse())
+
+		// pretend the line has been requested
+		fd, err := syscall.Open("/dev/null", syscall.O_RDONLY, 0)
+		Expect(err).NotTo(HaveOccurred())
+		g.fd = fd
+
+		again := Cdev("/dev/gpiochip0")(5)
+		Expect(again).To(BeIdenticalTo(g))
+		Expect(again.IsExported()).To(BeTrue())
+		Expect(NewCdev("/dev/gpiochip1", 5)).NotTo(BeIdenticalTo(g))
+		Expect(NewCdev("/dev/gpiochip0", 6)).NotTo(BeIdenticalTo(g))
+
+		Expect(again.Unexport()).To(Succeed())
+		released := NewCdev("/dev/gpiochip0", 5)
+		Expect(released).NotTo(BeIdenticalTo(g))
+		Expect(released.IsExported()).To(BeFalse())
+	})
+
+	It("decodes the line events", func() {
+		event := make([]byte, gpioV2LineEventSize)
+		binary.LittleEndian.PutUint64(event[0:8], 1500)
+		binary.LittleEndian.PutUint32(event[8:12], gpioV2LineEventFallingEdge)
+
+		edge, err := parseEdge(event)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(edge).To(Equal(Edge{Rising: false, Timestamp: 1500 * time.Nanosecond}))
+
+		binary.LittleEndian.PutUint32(event[8:12], gpioV2LineEventRisingEdge)
+		edge, err = parseEdge(event)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(edge.Rising).To(BeTrue())
+
+		_, err = parseEdge(event[:12])
+		Expect(err).To(HaveOccurred())
+	})
+
+})
+
+var _ = Describe("Watcher", func() {
+
+	var (
+		input   *fakeInput
+		watcher *Watcher
+	)
+
+	BeforeEach(func() {
+		input = &fakeInput{edges: make(chan bool, 16)}
+		watcher = NewWatcher(input, 50*time.Millisecond)
+		watcher.Start()
+	})
+
+	AfterEach(func() {
+		watcher.Shutdown()
+	})
+
+	It("reports the initial value", func() {
+		Eventually(watcher.Values()).Should(Receive(BeFalse()))
+	})
+
+	It("reports the value once the input is stable", func() {
+		Eventually(watcher.Values()).Should(Receive(BeFalse()))
+
+		for _, v := range []bool{true, false, true, false, true} {
+			input.set(v)
+		}
+		Consistently(watcher.Values(), 30*time.Millisecond).ShouldNot(Receive())
+		Eventually(watcher.Values()).Should(Receive(BeTrue()))
+	})
+
+	It("ignores the glitches", func() {
+		Eventually(watcher.Values()).Should(Receive(BeFalse()))
+
+		input.set(true)
+		input.set(false)
+		Consistently(watcher.Values(), 200*time.Millisecond).ShouldNot(Receive())
+	})
+
+})
+
+// fakeInput is an input whose changes are reported as edges
+type fakeInput struct {
+	sync.Mutex
+	value bool
+	edges chan bool
+}
+
+func (f *fakeInput) set(value bool) {
+	f.Lock()
+	f.value = value
+	f.Unlock()
+	f.edges <- value
+}
+
+func (f *fakeInput) IsExported() bool                  { return true }
+func (f *fakeInput) Export() error                     { return nil }
+func (f *fakeInput) Unexport() error                   { return nil }
+func (f *fakeInput) SetDirection(dir string) error     { return nil }
+func (f *fakeInput) SetActiveLevel(level string) error { return nil }
+func (f *fakeInput) SetBias(bias string) error         { return nil }
+func (f *fakeInput) SetEdge(edge string) error         { return nil }
+func (f *fakeInput) Enable() error                     { return ErrNotSupported }
+func (f *fakeInput) Disable() error                    { return ErrNotSupported }
+
+func (f *fakeInput) WaitForEdge(timeout time.Duration) (Edge, bool, error) {
+	select {
+	case v := <-f.edges:
+		return Edge{Rising: v}, true, nil
+	case <-time.After(timeout):
+		return Edge{}, false, nil
+	}
+}
+
+func (f *fakeInput) Value() (bool, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.value, nil
+}
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-19 12:30:26
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 13:13:17
+* @Last Modified time: 2015-11-23 21:27:14
  */
 
 package gpio
@@ -32,33 +32,105 @@ const (
 	sysfsI2CSpeed   = "/sys/class/i2c-adapter/i2c-%d/device/i2c_dw_sysnode/mode"
 )
 
-// EnablePWM enables PWM on a mux-ed pin
+// Speeds of an i2c bus
+const (
+	I2CStd  = "std"
+	I2CFast = "fast"
+	I2CHigh = "high"
+)
+
+// Names of the pinmux
+const (
+	EdisonPinmux = "edison"
+	NoPinmux     = "none"
+)
+
+// Pinmux selects the function of the multiplexed pins of a board
+type Pinmux interface {
+	// GPIO gives a pin to the gpio
+	GPIO(pin byte) error
+	// PWM gives a pin to its pwm
+	PWM(pin byte) error
+	// I2C gives a pin to its i2c bus
+	I2C(pin byte) error
+	// I2CSpeed sets the speed of an i2c bus (use constants I2CStd, I2CFast and I2CHigh)
+	I2CSpeed(bus byte, speed string) error
+}
+
+// edison is the pinmux of the Intel Edison - done through debugfs
 // See http://www.emutexlabs.com/project/215-intel-edison-gpio-pin-multiplexing-guide
-func EnablePWM(pin byte) error {
+type edison struct{}
+
+func (edison) GPIO(pin byte) error {
+	return writeTo(fmt.Sprintf(sysfsPinmuxMode, pin), "mode0")
+}
+
+func (edison) PWM(pin byte) error {
+	return writeTo(fmt.Sprintf(sysfsPinmuxMode, pin), "mode1")
+}
+
+func (edison) I2C(pin byte) error {
 	return writeTo(fmt.Sprintf(sysfsPinmuxMode, pin), "mode1")
 }
 
+func (edison) I2CSpeed(bus byte, speed string) error {
+	return writeTo(fmt.Sprintf(sysfsI2CSpeed, bus), speed)
+}
+
+// fixed is the pinmux of the boards where the function of the pins is fixed (e.g. by the device tree)
+type fixed struct{}
+
+func (fixed) GPIO(pin byte) error                   { return nil }
+func (fixed) PWM(pin byte) error                    { return nil }
+func (fixed) I2C(pin byte) error                    { return nil }
+func (fixed) I2CSpeed(bus byte, speed string) error { return nil }
+
+var pinmux Pinmux = edison{}
+
+// SetPinmux selects the Pinmux of the board
+func SetPinmux(p Pinmux) {
+	pinmux = p
+}
+
+// SelectPinmux selects the Pinmux of the board from its name
+func SelectPinmux(name string) error {
+	switch name {
+	case EdisonPinmux:
+		SetPinmux(edison{})
+	case NoPinmux:
+		SetPinmux(fixed{})
+	default:
+		return fmt.Errorf("unknown pinmux: %s", name)
+	}
+	return nil
+}
+
+// EnablePWM enables PWM on a mux-ed pin
+func EnablePWM(pin byte) error {
+	return pinmux.PWM(pin)
+}
+
 // EnableGPIO enables GPIO mode on a mux-ed pin
 func EnableGPIO(pin byte) error {
-	return writeTo(fmt.Sprintf(sysfsPinmuxMode, pin), "mode0")
+	return pinmux.GPIO(pin)
 }
 
 // EnableI2C enables i2c mode on a mux-ed pin
 func EnableI2C(pin byte) error {
-	return writeTo(fmt.Sprintf(sysfsPinmuxMode, pin), "mode1")
+	return pinmux.I2C(pin)
 }
 
 // EnableFastI2C enables i2c fast mode
 func EnableFastI2C(bus byte) error {
-	return writeTo(fmt.Sprintf(sysfsI2CSpeed, bus), "fast")
+	return pinmux.I2CSpeed(bus, I2CFast)
 }
 
 // EnableStdI2C enables i2c fast mode
 func EnableStdI2C(bus byte) error {
-	return writeTo(fmt.Sprintf(sysfsI2CSpeed, bus), "std")
+	return pinmux.I2CSpeed(bus, I2CStd)
 }
 
 // EnableHighI2C enables i2c fast mode
 func EnableHighI2C(bus byte) error {
-	return writeTo(fmt.Sprintf(sysfsI2CSpeed, bus), "high")
+	return pinmux.I2CSpeed(bus, I2CHigh)
 }
@@ -0,0 +1,58 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-21 16:14:37
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-21 16:14:37
+ */
+
+package gpio
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// waitFor waits until fd is readable - or has an exceptional condition, which is how sysfs reports
+// a change of value - false when the timeout expires first or the wait is interrupted.
+func waitFor(fd int, timeout time.Duration, exceptional bool) (bool, error) {
+	var fds syscall.FdSet
+	bits := int(unsafe.Sizeof(fds.Bits[0])) * 8
+	fds.Bits[fd/bits] |= 1 << uint(fd%bits)
+	tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+
+	var n int
+	var err error
+	if exceptional {
+		n, err = syscall.Select(fd+1, nil, nil, &fds, &tv)
+	} else {
+		n, err = syscall.Select(fd+1, &fds, nil, nil, &tv)
+	}
+	if err == syscall.EINTR {
+		return false, nil
+	}
+	return n > 0, err
+}
+
+// monotonicNow is the time on the monotonic clock of the kernel - the clock of the gpio character device events
+func monotonicNow() time.Duration {
+	var ts syscall.Timespec
+	syscall.Syscall(syscall.SYS_CLOCK_GETTIME, 1 /* CLOCK_MONOTONIC */, uintptr(unsafe.Pointer(&ts)), 0)
+	return time.Duration(ts.Nano())
+}
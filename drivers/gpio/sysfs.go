@@ -0,0 +1,202 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-19 22:51:08
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-22 15:03:51
+ */
+
+package gpio
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/drivers/sysfs"
+)
+
+const (
+	high = "1\n"
+	low  = "0\n"
+)
+
+const (
+	sysfsGpioValue     = "/sys/class/gpio/gpio%d/value"
+	sysfsGpioDirection = "/sys/class/gpio/gpio%d/direction"
+	sysfsGpioDir       = "/sys/class/gpio/gpio%d/"
+	sysfsGpioExport    = "/sys/class/gpio/export"
+	sysfsGpioUnexport  = "/sys/class/gpio/unexport"
+	sysfsGpioActiveLow = "/sys/class/gpio/gpio%d/active_low"
+	sysfsGpioEdge      = "/sys/class/gpio/gpio%d/edge"
+)
+
+// sysfsGpio is a general purpose I/O driven with the (deprecated) sysfs interface
+type sysfsGpio struct {
+	pin uint8
+
+	edge    string
+	valueFd int // value file polled by WaitForEdge - -1 when not opened
+}
+
+// NewSysfs returns a new Gpio driven through /sys/class/gpio for a given pin
+func NewSysfs(pin uint8) Gpio {
+	return &sysfsGpio{pin: pin, edge: EdgeNone, valueFd: -1}
+}
+
+func writeTo(filename string, content string) error {
+	return sysfs.WriteFile(filename, content)
+}
+
+// IsExported returns true with the gpio is already exported and usable from sysfs.
+func (p *sysfsGpio) IsExported() bool {
+	return sysfs.Exists(fmt.Sprintf(sysfsGpioDir, p.pin))
+}
+
+// Export the gpio to be usable from sysfs.
+func (p *sysfsGpio) Export() error {
+	return writeTo(sysfsGpioExport, fmt.Sprintf("%d", p.pin))
+}
+
+// Unexport the gpio from sysfs.
+func (p *sysfsGpio) Unexport() error {
+	p.closeValue()
+	return writeTo(sysfsGpioUnexport, fmt.Sprintf("%d", p.pin))
+}
+
+// SetDirection defines whether this particular GPIO is used for input or output (use constants InDirection and OutDirection).
+func (p *sysfsGpio) SetDirection(dir string) error {
+	if err := checkDirection(dir); err != nil {
+		return err
+	}
+	return writeTo(fmt.Sprintf(sysfsGpioDirection, p.pin), dir)
+}
+
+// SetActiveLevel set the ActiveLow or ActiveHigh level for IN direction.
+func (p *sysfsGpio) SetActiveLevel(level string) error {
+	if err := checkActiveLevel(level); err != nil {
+		return err
+	}
+	return writeTo(fmt.Sprintf(sysfsGpioActiveLow, p.pin), level)
+}
+
+// SetBias is not supported by sysfs
+func (p *sysfsGpio) SetBias(bias string) error {
+	return ErrNotSupported
+}
+
+// SetEdge selects the edges reported by WaitForEdge - the gpio must be an input.
+// Edges are only supported when the attributes are files that can be polled.
+func (p *sysfsGpio) SetEdge(edge string) error {
+	if err := checkEdge(edge); err != nil {
+		return err
+	}
+	if _, ok := sysfs.Path(fmt.Sprintf(sysfsGpioValue, p.pin)); !ok {
+		return ErrNotSupported
+	}
+	if err := writeTo(fmt.Sprintf(sysfsGpioEdge, p.pin), edge); err != nil {
+		return err
+	}
+	p.edge = edge
+	return nil
+}
+
+func (p *sysfsGpio) closeValue() {
+	if p.valueFd >= 0 {
+		syscall.Close(p.valueFd)
+		p.valueFd = -1
+	}
+}
+
+// readValue reads the value file kept open for WaitForEdge
+func (p *sysfsGpio) readValue() (bool, error) {
+	buf := make([]byte, len(high))
+	n, err := syscall.Pread(p.valueFd, buf, 0)
+	if err != nil {
+		return false, err
+	}
+	return parseValue(string(buf[:n]))
+}
+
+// WaitForEdge waits for the next edge - false when the timeout expires first.
+// sysfs does not tell which edge happened: it is deduced from the value read right after.
+func (p *sysfsGpio) WaitForEdge(timeout time.Duration) (Edge, bool, error) {
+	if p.edge == EdgeNone {
+		return Edge{}, false, fmt.Errorf("no edge selected on gpio%d", p.pin)
+	}
+
+	if p.valueFd < 0 {
+		name, ok := sysfs.Path(fmt.Sprintf(sysfsGpioValue, p.pin))
+		if !ok {
+			return Edge{}, false, ErrNotSupported
+		}
+		fd, err := syscall.Open(name, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			return Edge{}, false, err
+		}
+		p.valueFd = fd
+
+		// the value has to be read once before an edge can be waited for
+		if _, err := p.readValue(); err != nil {
+			p.closeValue()
+			return Edge{}, false, err
+		}
+	}
+
+	ready, err := waitFor(p.valueFd, timeout, true)
+	if err != nil || !ready {
+		return Edge{}, false, err
+	}
+	timestamp := monotonicNow()
+
+	value, err := p.readValue()
+	if err != nil {
+		return Edge{}, false, err
+	}
+	return Edge{Rising: value, Timestamp: timestamp}, true, nil
+}
+
+// Value returns the value of the GPIO
+func (p *sysfsGpio) Value() (bool, error) {
+	val, err := sysfs.ReadFile(fmt.Sprintf(sysfsGpioValue, p.pin))
+	if err != nil {
+		return false, err
+	}
+	return parseValue(val)
+}
+
+func parseValue(val string) (bool, error) {
+	switch val {
+	case high:
+		return true, nil
+	case low:
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid value: [%v]", val)
+	}
+}
+
+// Enable this gpio
+func (p *sysfsGpio) Enable() error {
+	return writeTo(fmt.Sprintf(sysfsGpioValue, p.pin), high)
+}
+
+// Disable this gpio
+func (p *sysfsGpio) Disable() error {
+	return writeTo(fmt.Sprintf(sysfsGpioValue, p.pin), low)
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-29 17:44:12
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-20 17:52:08
+ */
+
+// Package hbridge drives a brushed DC motor (or a linear actuator) through an H-bridge.
+package hbridge
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/drivers/gpio"
+	"github.com/ssoudan/edisonIsThePilot/drivers/pwm"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+var log = logger.Log("hbridge")
+
+// Modes of the H-bridge
+const (
+	// PwmDirMode is for the bridges with a PWM (speed) and a DIR input
+	PwmDirMode = "pwm-dir"
+	// In1In2Mode is for the bridges with IN1/IN2 inputs (direction) and a PWM on the enable input (speed) - e.g. L298N
+	In1In2Mode = "in1-in2"
+)
+
+const (
+	// NoPin is the pin of the inputs which are not wired
+	NoPin = 0xff
+	// StepsPerRevolution is the number of the virtual steps of a revolution of the motor - one per degree
+	StepsPerRevolution = 360
+	// DefaultPwmPeriod is the period of the PWM: 20kHz is not audible
+	DefaultPwmPeriod = 50 * time.Microsecond
+	// tick is the period of the update of the duty cycle and of the current checks during a move
+	tick = 10 * time.Millisecond
+)
+
+// Config is the wiring and the settings of an H-bridge and its motor
+type Config struct {
+	Mode            string
+	MaxSpeed        float64       // speed of the motor at full duty cycle, in revolutions per second
+	SoftStart       time.Duration // duration of the ramp of the duty cycle from 0 to its target
+	StallTimeout    time.Duration // duration of an over-current before the move is stopped
+	PwmPeriod       time.Duration
+	EnableActiveLow bool // true when the bridge is enabled with the enable pin low
+
+	PwmPin          byte
+	Pwm             byte
+	DirPin          byte // DIR or IN1
+	In2Pin          byte // IN2 - for In1In2Mode
+	EnablePin       byte // NoPin when the bridge is always enabled
+	CurrentSensePin byte // over-current input (e.g. a comparator), high when the motor stalls - NoPin when not wired
+}
+
+// HBridge is a driver for a DC motor driven through an H-bridge
+type HBridge struct {
+	config Config
+
+	dirGPIO          gpio.Gpio
+	in2GPIO          gpio.Gpio
+	enableGPIO       gpio.Gpio
+	currentSenseGPIO gpio.Gpio
+	pwm              pwm.Pwm
+}
+
+func newGpio(pin byte, direction string) (gpio.Gpio, error) {
+	if err := gpio.EnableGPIO(pin); err != nil {
+		return nil, err
+	}
+
+	g := gpio.New(pin)
+	if !g.IsExported() {
+		if err := g.Export(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := g.SetDirection(direction); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// New creates a new HBridge driven with GPIOs and a PWM
+func New(config Config) (*HBridge, error) {
+	if config.Mode != PwmDirMode && config.Mode != In1In2Mode {
+		return nil, fmt.Errorf("unknown h-bridge mode %s", config.Mode)
+	}
+	if config.MaxSpeed <= 0 {
+		return nil, fmt.Errorf("the maximum speed of the motor must be positive - got %v", config.MaxSpeed)
+	}
+
+	h := &HBridge{config: config}
+
+	var err error
+	if h.dirGPIO, err = newGpio(config.DirPin, gpio.OutDirection); err != nil {
+		return nil, err
+	}
+	if err = h.dirGPIO.Disable(); err != nil {
+		return nil, err
+	}
+
+	if config.Mode == In1In2Mode {
+		if h.in2GPIO, err = newGpio(config.In2Pin, gpio.OutDirection); err != nil {
+			return nil, err
+		}
+		if err = h.in2GPIO.Disable(); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.EnablePin != NoPin {
+		if h.enableGPIO, err = newGpio(config.EnablePin, gpio.OutDirection); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.CurrentSensePin != NoPin {
+		if h.currentSenseGPIO, err = newGpio(config.CurrentSensePin, gpio.InDirection); err != nil {
+			return nil, err
+		}
+		if err = h.currentSenseGPIO.SetActiveLevel(gpio.ActiveHigh); err != nil {
+			return nil, err
+		}
+	}
+
+	if h.pwm, err = pwm.New(config.Pwm, config.PwmPin); err != nil {
+		return nil, err
+	}
+	if !h.pwm.IsExported() {
+		if err = h.pwm.Export(); err != nil {
+			return nil, err
+		}
+	}
+	if err = h.pwm.Disable(); err != nil {
+		return nil, err
+	}
+
+	if err = h.Disable(); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// StepsPerRevolution returns the number of virtual steps of a revolution
+func (h HBridge) StepsPerRevolution() uint32 {
+	return StepsPerRevolution
+}
+
+// Enable enables the bridge
+func (h HBridge) Enable() error {
+	if h.enableGPIO == nil {
+		return nil
+	}
+	if h.config.EnableActiveLow {
+		return h.enableGPIO.Disable()
+	}
+	return h.enableGPIO.Enable()
+}
+
+// Disable disables the bridge - the motor is free
+func (h HBridge) Disable() error {
+	if h.enableGPIO == nil {
+		return nil
+	}
+	if h.config.EnableActiveLow {
+		return h.enableGPIO.Enable()
+	}
+	return h.enableGPIO.Disable()
+}
+
+// dutyCycle returns the duty cycle giving a speed (in steps per second) and the duration of the move at that duty cycle -
+// longer than requested when the motor is not fast enough
+func dutyCycle(stepsBySecond uint32, duration time.Duration, maxSpeed float64) (float64, time.Duration) {
+	duty := float64(stepsBySecond) / (maxSpeed * StepsPerRevolution)
+	if duty > 1 {
+		return 1, time.Duration(float64(duration) * duty)
+	}
+	return duty, duration
+}
+
+// softStart returns the duration of a move with a linear ramp of the duty cycle covering the same distance
+// as a move of a given duration at full duty cycle
+func softStart(duration, ramp time.Duration) time.Duration {
+	if ramp <= 0 {
+		return duration
+	}
+	if duration >= ramp/2 {
+		return duration + ramp/2
+	}
+	// the ramp is not over when the distance is covered
+	return time.Duration(math.Sqrt(2 * float64(duration) * float64(ramp)))
+}
+
+// setDirection sets the direction inputs of the bridge
+func (h HBridge) setDirection(clockwise bool) error {
+	if h.config.Mode == In1In2Mode {
+		if clockwise {
+			if err := h.in2GPIO.Disable(); err != nil {
+				return err
+			}
+			return h.dirGPIO.Enable()
+		}
+		if err := h.dirGPIO.Disable(); err != nil {
+			return err
+		}
+		return h.in2GPIO.Enable()
+	}
+
+	if clockwise {
+		return h.dirGPIO.Disable()
+	}
+	return h.dirGPIO.Enable()
+}
+
+// stop stops driving the motor - the IN1/IN2 bridges are left with both inputs low
+func (h HBridge) stop() error {
+	if err := h.pwm.Disable(); err != nil {
+		return err
+	}
+	if h.config.Mode == In1In2Mode {
+		if err := h.dirGPIO.Disable(); err != nil {
+			return err
+		}
+		return h.in2GPIO.Disable()
+	}
+	return nil
+}
+
+// stalled returns true when the current is above the stall threshold
+func (h HBridge) stalled() (bool, error) {
+	if h.currentSenseGPIO == nil {
+		return false, nil
+	}
+	return h.currentSenseGPIO.Value()
+}
+
+// Move makes the motor rotate in the given direction at the specified speed (in virtual steps per second) for a given duration -
+// the duty cycle is ramped up and the move is stopped with types.ErrStalled when the motor stalls -- make sure to Enable() the bridge first
+func (h HBridge) Move(clockwise bool, stepsBySecond uint32, duration time.Duration) (err error) {
+	if stepsBySecond == 0 || duration == 0 {
+		return nil
+	}
+
+	duty, duration := dutyCycle(stepsBySecond, duration, h.config.MaxSpeed)
+	total := softStart(duration, h.config.SoftStart)
+
+	defer func() {
+		if stopErr := h.stop(); err == nil {
+			err = stopErr
+		}
+	}()
+
+	if err := h.setDirection(clockwise); err != nil {
+		return err
+	}
+
+	// the period is only set while the pwm is disabled: SetPeriodAndDutyCycle goes through a transient duty cycle
+	if err := h.pwm.SetPeriodAndDutyCycle(h.config.PwmPeriod, 0); err != nil {
+		return err
+	}
+	if err := h.pwm.Enable(); err != nil {
+		return err
+	}
+
+	var stalledSince time.Time
+	current := 0.
+	start := time.Now()
+	for elapsed := time.Duration(0); elapsed < total; elapsed = time.Since(start) {
+
+		ramp := 1.
+		if elapsed < h.config.SoftStart {
+			ramp = float64(elapsed) / float64(h.config.SoftStart)
+		}
+		if duty*ramp != current {
+			current = duty * ramp
+			if err := h.pwm.SetDutyCycle(float32(current)); err != nil {
+				return err
+			}
+		}
+
+		stalled, err := h.stalled()
+		if err != nil {
+			return err
+		}
+		switch {
+		case !stalled:
+			stalledSince = time.Time{}
+		case stalledSince.IsZero():
+			stalledSince = time.Now()
+		case time.Since(stalledSince) >= h.config.StallTimeout:
+			log.Error("Motor stalled after %v of %v", elapsed, total)
+			return types.ErrStalled
+		}
+
+		if remaining := total - elapsed; remaining < tick {
+			time.Sleep(remaining)
+		} else {
+			time.Sleep(tick)
+		}
+	}
+
+	return nil
+}
+
+// Unexport unexports the GPIO and the PWM used to drive the bridge
+func (h HBridge) Unexport() {
+	h.dirGPIO.Unexport()
+	if h.in2GPIO != nil {
+		h.in2GPIO.Unexport()
+	}
+	if h.enableGPIO != nil {
+		h.enableGPIO.Unexport()
+	}
+	if h.currentSenseGPIO != nil {
+		h.currentSenseGPIO.Unexport()
+	}
+	h.pwm.Unexport()
+}
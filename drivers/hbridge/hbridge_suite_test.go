@@ -0,0 +1,156 @@
+package hbridge
+
+import (
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/drivers/sysfs"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestHBridge(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "HBridge Suite")
+}
+
+var _ = Describe("HBridge", func() {
+
+	var (
+		fake   *sysfs.Fake
+		config Config
+	)
+
+	BeforeEach(func() {
+		fake = sysfs.NewFake()
+		sysfs.Set(fake)
+
+		config = Config{
+			Mode:            PwmDirMode,
+			MaxSpeed:        1,
+			SoftStart:       20 * time.Millisecond,
+			StallTimeout:    30 * time.Millisecond,
+			PwmPeriod:       DefaultPwmPeriod,
+			EnableActiveLow: false,
+			PwmPin:          182,
+			Pwm:             2,
+			DirPin:          165,
+			In2Pin:          NoPin,
+			EnablePin:       12,
+			CurrentSensePin: NoPin,
+		}
+	})
+
+	AfterEach(func() {
+		sysfs.SetRoot("/")
+	})
+
+	read := func(name string) string {
+		v, err := fake.ReadFile(name)
+		Expect(err).NotTo(HaveOccurred())
+		return v
+	}
+	value := func(pin string) string {
+		return read("/sys/class/gpio/gpio" + pin + "/value")
+	}
+
+	It("clips the duty cycle and makes the move longer", func() {
+		duty, duration := dutyCycle(180, time.Second, 1)
+		Expect(duty).To(BeNumerically("~", 0.5))
+		Expect(duration).To(Equal(time.Second))
+
+		duty, duration = dutyCycle(720, time.Second, 1)
+		Expect(duty).To(BeNumerically("~", 1))
+		Expect(duration).To(Equal(2 * time.Second))
+	})
+
+	It("makes up for the soft start", func() {
+		Expect(softStart(time.Second, 0)).To(Equal(time.Second))
+		Expect(softStart(time.Second, 200*time.Millisecond)).To(Equal(1100 * time.Millisecond))
+		// half of the ramp covers 1/8 of the distance of the full ramp
+		Expect(softStart(25*time.Millisecond, 200*time.Millisecond)).To(Equal(100 * time.Millisecond))
+	})
+
+	It("drives the direction and stops the pwm after a move", func() {
+		h, err := New(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(h.StepsPerRevolution()).To(BeEquivalentTo(StepsPerRevolution))
+		Expect(value("12")).To(Equal("0\n"), "disabled")
+		Expect(h.Enable()).To(Succeed())
+		Expect(value("12")).To(Equal("1\n"))
+
+		Expect(h.Move(false, 360, 30*time.Millisecond)).To(Succeed())
+		Expect(value("165")).To(Equal("1\n"))
+		Expect(read("/sys/class/pwm/pwmchip0/pwm2/enable")).To(Equal("0\n"))
+		Expect(read("/sys/class/pwm/pwmchip0/pwm2/duty_cycle")).To(Equal("50000\n"))
+
+		Expect(h.Move(true, 360, 10*time.Millisecond)).To(Succeed())
+		Expect(value("165")).To(Equal("0\n"))
+		h.Unexport()
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("ramps the duty cycle up without dropping it", func() {
+		recorder := &dutyCycles{Fake: fake}
+		sysfs.Set(recorder)
+
+		h, err := New(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(h.Enable()).To(Succeed())
+		Expect(h.Move(false, 360, 30*time.Millisecond)).To(Succeed())
+
+		Expect(len(recorder.written)).To(BeNumerically(">", 1))
+		for i := 1; i < len(recorder.written); i++ {
+			Expect(recorder.written[i]).To(BeNumerically(">=", recorder.written[i-1]))
+		}
+		Expect(recorder.written[len(recorder.written)-1]).To(BeEquivalentTo(50000))
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("lets an in1-in2 bridge coast after a move", func() {
+		config.Mode = In1In2Mode
+		config.In2Pin = 166
+		h, err := New(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(h.Enable()).To(Succeed())
+		Expect(h.Move(true, 360, 10*time.Millisecond)).To(Succeed())
+		Expect(value("165")).To(Equal("0\n"))
+		Expect(value("166")).To(Equal("0\n"))
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("stops when the motor stalls", func() {
+		config.CurrentSensePin = 13
+		h, err := New(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.SetInput(13, true)).To(Succeed())
+		Expect(h.Enable()).To(Succeed())
+
+		start := time.Now()
+		Expect(h.Move(true, 360, time.Second)).To(Equal(types.ErrStalled))
+		Expect(time.Since(start)).To(BeNumerically("<", 500*time.Millisecond))
+		Expect(read("/sys/class/pwm/pwmchip0/pwm2/enable")).To(Equal("0\n"))
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+})
+
+// dutyCycles records the duty cycles written while the pwm is enabled
+type dutyCycles struct {
+	*sysfs.Fake
+	written []int64
+}
+
+func (d *dutyCycles) WriteFile(name, content string) error {
+	if path.Base(name) == "duty_cycle" {
+		if enable, _ := d.Fake.ReadFile(path.Dir(name) + "/enable"); enable == "1\n" {
+			n, _ := strconv.ParseInt(content, 10, 64)
+			d.written = append(d.written, n)
+		}
+	}
+	return d.Fake.WriteFile(name, content)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-25 20:36:09
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-25 20:36:09
+ */
+
+// Package i2c gives access to the i2c buses - the devices (DACs, compass, IMU...) are driven through a Bus.
+package i2c
+
+// Bus is an i2c bus where devices are accessed by address
+type Bus interface {
+	// ReadRegister reads a register of a device
+	ReadRegister(address, reg byte) (byte, error)
+	// WriteRegister writes a register of a device
+	WriteRegister(address, reg, value byte) error
+	// ReadBlock reads length bytes from a device starting at a register
+	ReadBlock(address, reg byte, length int) ([]byte, error)
+	// WriteBlock writes bytes to a device starting at a register (or after a command)
+	WriteBlock(address, reg byte, data []byte) error
+	// Close releases the bus
+	Close() error
+}
+
+// Opener opens a bus from its number
+type Opener func(bus byte) (Bus, error)
+
+var opener Opener = OpenLinux
+
+// SetOpener selects how the buses are opened by Open - OpenLinux by default
+func SetOpener(o Opener) {
+	opener = o
+}
+
+var pinmux = func(bus byte) error { return nil }
+
+// SetPinmux sets the function giving the pins of a bus to the i2c controller before it is opened -
+// it depends on the board, nothing is done by default
+func SetPinmux(p func(bus byte) error) {
+	pinmux = p
+}
+
+// Open sets the pinmux of a bus and opens it
+func Open(bus byte) (Bus, error) {
+	if err := pinmux(bus); err != nil {
+		return nil, err
+	}
+	return opener(bus)
+}
@@ -0,0 +1,97 @@
+package i2c
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestI2c(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "I2c Suite")
+}
+
+var _ = Describe("Open", func() {
+
+	AfterEach(func() {
+		SetOpener(OpenLinux)
+		SetPinmux(func(bus byte) error { return nil })
+	})
+
+	It("sets the pinmux of the bus before opening it", func() {
+		mock := NewMock()
+		muxed := []byte{}
+		SetPinmux(func(bus byte) error {
+			muxed = append(muxed, bus)
+			return nil
+		})
+		SetOpener(mock.Open)
+
+		bus, err := Open(1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bus).To(Equal(mock))
+		Expect(muxed).To(Equal([]byte{1}))
+	})
+
+	It("doesn't open the bus when the pinmux fails", func() {
+		SetPinmux(func(bus byte) error { return errors.New("no pinmux") })
+		SetOpener(func(bus byte) (Bus, error) {
+			Fail("opened")
+			return nil, nil
+		})
+
+		_, err := Open(6)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reports the buses that don't exist", func() {
+		_, err := OpenLinux(255)
+		Expect(err).To(HaveOccurred())
+	})
+
+})
+
+var _ = Describe("Mock", func() {
+
+	var mock *Mock
+
+	BeforeEach(func() {
+		mock = NewMock()
+	})
+
+	It("records the transfers", func() {
+		Expect(mock.WriteBlock(0x62, 0x40, []byte{0x80, 0x00})).To(Succeed())
+		Expect(mock.WriteRegister(0x1e, 0x02, 0x00)).To(Succeed())
+
+		Expect(mock.Transfers()).To(Equal([]Transfer{
+			{Address: 0x62, Register: 0x40, Data: []byte{0x80, 0x00}},
+			{Address: 0x1e, Register: 0x02, Data: []byte{0x00}},
+		}))
+	})
+
+	It("models the registers of the devices", func() {
+		mock.SetRegisters(0x1e, 0x03, 0x01, 0x02, 0x03)
+
+		Expect(mock.ReadBlock(0x1e, 0x03, 4)).To(Equal([]byte{0x01, 0x02, 0x03, 0x00}))
+		Expect(mock.ReadRegister(0x1e, 0x04)).To(Equal(byte(0x02)))
+		Expect(mock.ReadRegister(0x68, 0x04)).To(Equal(byte(0x00)))
+
+		Expect(mock.WriteRegister(0x68, 0x6b, 0x01)).To(Succeed())
+		Expect(mock.ReadRegister(0x68, 0x6b)).To(Equal(byte(0x01)))
+
+		Expect(mock.Transfers()).To(HaveLen(5))
+		Expect(mock.Transfers()[0]).To(Equal(Transfer{Read: true, Address: 0x1e, Register: 0x03, Data: []byte{0x01, 0x02, 0x03, 0x00}}))
+	})
+
+	It("fails the transfers on demand", func() {
+		mock.Err = errors.New("nack")
+		Expect(mock.WriteRegister(0x62, 0x40, 0x00)).NotTo(Succeed())
+		_, err := mock.ReadRegister(0x62, 0x40)
+		Expect(err).To(HaveOccurred())
+		Expect(mock.Transfers()).To(BeEmpty())
+	})
+
+})
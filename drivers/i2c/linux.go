@@ -0,0 +1,130 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-25 20:36:09
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-25 20:36:09
+ */
+
+package i2c
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+)
+
+// i2cSlave is the ioctl setting the address of the device of the following reads and writes - see include/uapi/linux/i2c-dev.h
+const i2cSlave = 0x0703
+
+// linuxBus is a bus driven through /dev/i2c-N
+type linuxBus struct {
+	sync.Mutex
+	name    string
+	fd      int
+	address int // address of the device currently selected - -1 for none
+}
+
+// OpenLinux opens /dev/i2c-N
+func OpenLinux(bus byte) (Bus, error) {
+	name := fmt.Sprintf("/dev/i2c-%d", bus)
+	fd, err := syscall.Open(name, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", name, err)
+	}
+	return &linuxBus{name: name, fd: fd, address: -1}, nil
+}
+
+func (b *linuxBus) selectDevice(address byte) error {
+	if b.address == int(address) {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(b.fd), i2cSlave, uintptr(address))
+	if errno != 0 {
+		b.address = -1
+		return fmt.Errorf("failed to select device %#x on %s: %v", address, b.name, errno)
+	}
+	b.address = int(address)
+	return nil
+}
+
+func (b *linuxBus) write(data []byte) error {
+	n, err := syscall.Write(b.fd, data)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return fmt.Errorf("short write on %s: %d/%d bytes", b.name, n, len(data))
+	}
+	return nil
+}
+
+// ReadRegister reads a register of a device
+func (b *linuxBus) ReadRegister(address, reg byte) (byte, error) {
+	data, err := b.ReadBlock(address, reg, 1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// WriteRegister writes a register of a device
+func (b *linuxBus) WriteRegister(address, reg, value byte) error {
+	return b.WriteBlock(address, reg, []byte{value})
+}
+
+// ReadBlock reads length bytes from a device starting at a register
+func (b *linuxBus) ReadBlock(address, reg byte, length int) ([]byte, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if err := b.selectDevice(address); err != nil {
+		return nil, err
+	}
+	if err := b.write([]byte{reg}); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+	n, err := syscall.Read(b.fd, data)
+	if err != nil {
+		return nil, err
+	}
+	if n != length {
+		return nil, fmt.Errorf("short read on %s: %d/%d bytes", b.name, n, length)
+	}
+	return data, nil
+}
+
+// WriteBlock writes bytes to a device starting at a register (or after a command)
+func (b *linuxBus) WriteBlock(address, reg byte, data []byte) error {
+	b.Lock()
+	defer b.Unlock()
+
+	if err := b.selectDevice(address); err != nil {
+		return err
+	}
+	return b.write(append([]byte{reg}, data...))
+}
+
+// Close releases the bus
+func (b *linuxBus) Close() error {
+	b.Lock()
+	defer b.Unlock()
+	return syscall.Close(b.fd)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-25 20:36:09
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-25 20:36:09
+ */
+
+package i2c
+
+import (
+	"sync"
+)
+
+// Transfer is a read or a write recorded by a Mock
+type Transfer struct {
+	Read     bool
+	Address  byte
+	Register byte
+	Data     []byte
+}
+
+// Mock is a Bus recording the transfers - the devices are modelled as registers keeping what is written
+type Mock struct {
+	mu        sync.Mutex
+	transfers []Transfer
+	registers map[uint16]byte
+
+	Err error // returned by the transfers when set
+}
+
+// NewMock creates a Mock where all the registers are 0
+func NewMock() *Mock {
+	return &Mock{registers: make(map[uint16]byte)}
+}
+
+// Open returns the Mock whatever the bus - to be used with SetOpener
+func (m *Mock) Open(bus byte) (Bus, error) {
+	return m, nil
+}
+
+// SetRegisters sets the registers of a device starting at reg, as the device would
+func (m *Mock) SetRegisters(address, reg byte, values ...byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, v := range values {
+		m.registers[uint16(address)<<8|uint16(reg+byte(i))] = v
+	}
+}
+
+// Transfers returns the transfers since the creation of the Mock
+func (m *Mock) Transfers() []Transfer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Transfer{}, m.transfers...)
+}
+
+// ReadRegister reads a register of a device
+func (m *Mock) ReadRegister(address, reg byte) (byte, error) {
+	data, err := m.ReadBlock(address, reg, 1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+// WriteRegister writes a register of a device
+func (m *Mock) WriteRegister(address, reg, value byte) error {
+	return m.WriteBlock(address, reg, []byte{value})
+}
+
+// ReadBlock reads length bytes from a device starting at a register
+func (m *Mock) ReadBlock(address, reg byte, length int) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return nil, m.Err
+	}
+
+	data := make([]byte, length)
+	for i := range data {
+		data[i] = m.registers[uint16(address)<<8|uint16(reg+byte(i))]
+	}
+	m.transfers = append(m.transfers, Transfer{Read: true, Address: address, Register: reg, Data: append([]byte{}, data...)})
+	return data, nil
+}
+
+// WriteBlock writes bytes to a device starting at a register
+func (m *Mock) WriteBlock(address, reg byte, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+
+	for i, v := range data {
+		m.registers[uint16(address)<<8|uint16(reg+byte(i))] = v
+	}
+	m.transfers = append(m.transfers, Transfer{Address: address, Register: reg, Data: append([]byte{}, data...)})
+	return nil
+}
+
+// Close does nothing
+func (m *Mock) Close() error {
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-01 21:12:40
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-01 21:12:40
+ */
+
+// Package ina219 is a driver for the INA219 i2c current sensor.
+package ina219
+
+import (
+	"encoding/binary"
+
+	"github.com/ssoudan/edisonIsThePilot/drivers/i2c"
+)
+
+const (
+	// shuntVoltageRegister is the register of the voltage across the shunt
+	shuntVoltageRegister = 0x01
+	// shuntVoltageLSB is the resolution of the shunt voltage register (in V)
+	shuntVoltageLSB = 10e-6
+)
+
+// INA219 is a driver for the INA219 current sensor - used with its power-on configuration (continuous conversions, ±320mV)
+type INA219 struct {
+	address byte
+	i2c     i2c.Bus
+	shunt   float64 // resistance of the shunt (in Ohm)
+}
+
+// New creates a new INA219 driver for the sensor at an address of an i2c bus measuring the current through a shunt (in Ohm)
+func New(bus i2c.Bus, address byte, shunt float64) *INA219 {
+	return &INA219{address: address, i2c: bus, shunt: shunt}
+}
+
+// ShuntVoltage returns the voltage across the shunt (in V)
+func (s INA219) ShuntVoltage() (float64, error) {
+	b, err := s.i2c.ReadBlock(s.address, shuntVoltageRegister, 2)
+	if err != nil {
+		return 0, err
+	}
+	return fromBytes(b) * shuntVoltageLSB, nil
+}
+
+// Current returns the current through the shunt (in A)
+func (s INA219) Current() (float64, error) {
+	v, err := s.ShuntVoltage()
+	if err != nil {
+		return 0, err
+	}
+	return v / s.shunt, nil
+}
+
+func fromBytes(b []byte) float64 {
+	return float64(int16(binary.BigEndian.Uint16(b)))
+}
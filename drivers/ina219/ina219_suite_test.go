@@ -0,0 +1,52 @@
+package ina219
+
+import (
+	"errors"
+
+	"github.com/ssoudan/edisonIsThePilot/drivers/i2c"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestIna219(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Ina219 Suite")
+}
+
+var _ = Describe("ina219 driver", func() {
+
+	var bus *i2c.Mock
+
+	BeforeEach(func() {
+		bus = i2c.NewMock()
+	})
+
+	It("converts the shunt voltage into a current", func() {
+		bus.SetRegisters(0x40, shuntVoltageRegister, 0x0f, 0xa0) // 40mV
+		sensor := New(bus, 0x40, 0.1)
+
+		current, err := sensor.Current()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current).To(BeNumerically("~", 0.4, 1e-9))
+		Expect(bus.Transfers()).To(Equal([]i2c.Transfer{{Read: true, Address: 0x40, Register: shuntVoltageRegister, Data: []byte{0x0f, 0xa0}}}))
+	})
+
+	It("reads the negative currents", func() {
+		bus.SetRegisters(0x40, shuntVoltageRegister, 0xf0, 0x60) // -40mV
+		sensor := New(bus, 0x40, 0.1)
+
+		current, err := sensor.Current()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current).To(BeNumerically("~", -0.4, 1e-9))
+	})
+
+	It("returns the errors of the bus", func() {
+		bus.Err = errors.New("nack")
+		_, err := New(bus, 0x40, 0.1).Current()
+		Expect(err).To(MatchError("nack"))
+	})
+
+})
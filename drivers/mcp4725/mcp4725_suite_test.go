@@ -1,6 +1,8 @@
 package mcp4725
 
 import (
+	"github.com/ssoudan/edisonIsThePilot/drivers/i2c"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -40,3 +42,15 @@ var _ = Describe("mcp4725 conversion", func() {
 	})
 
 })
+
+var _ = Describe("mcp4725 driver", func() {
+
+	It("writes the output with the write DAC command", func() {
+		bus := i2c.NewMock()
+		dac := New(bus, 0x62)
+
+		Expect(dac.SetValue(0x800)).To(Succeed())
+		Expect(bus.Transfers()).To(Equal([]i2c.Transfer{{Address: 0x62, Register: writeDacCommand, Data: []byte{0x80, 0x00}}}))
+	})
+
+})
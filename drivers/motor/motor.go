@@ -18,94 +18,203 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-21 18:58:22
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 13:09:34
+* @Last Modified time: 2015-11-27 22:08:45
  */
 
 package motor
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/ssoudan/edisonIsThePilot/drivers/gpio"
 	"github.com/ssoudan/edisonIsThePilot/drivers/pwm"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
 )
 
 var log = logger.Log("motor")
 
-// Motor is a driver for a stepper motor
+// NoPin is the pin of the inputs of the driver which are not wired (e.g. set with jumpers)
+const NoPin = 0xff
+
+// Driver is a stepper motor driver
+type Driver interface {
+	types.Enablable // Enable/Disable the torque
+	// Move makes the motor rotate in the given direction at the specified speed (in microsteps per second) for a given duration
+	Move(clockwise bool, stepsBySecond uint32, duration time.Duration) error
+	// StepsPerRevolution returns the number of (micro)steps of a revolution of the motor
+	StepsPerRevolution() uint32
+}
+
+// Chip is a step/dir stepper driver chip and its microstep modes
+type Chip struct {
+	Name  string
+	modes map[uint32][3]bool // levels of MS1, MS2 and MS3 for each number of microsteps per full step
+}
+
+// Names of the chips
+const (
+	A4988Chip   = "a4988"
+	DRV8825Chip = "drv8825"
+)
+
+// Chips are the supported driver chips - the Big EasyDriver is an A4988
+var Chips = map[string]Chip{
+	A4988Chip: {Name: A4988Chip, modes: map[uint32][3]bool{
+		1:  {false, false, false},
+		2:  {true, false, false},
+		4:  {false, true, false},
+		8:  {true, true, false},
+		16: {true, true, true},
+	}},
+	DRV8825Chip: {Name: DRV8825Chip, modes: map[uint32][3]bool{ // MODE0, MODE1, MODE2
+		1:  {false, false, false},
+		2:  {true, false, false},
+		4:  {false, true, false},
+		8:  {true, true, false},
+		16: {false, false, true},
+		32: {true, false, true},
+	}},
+}
+
+// Supports returns true when the chip can divide a full step in microsteps
+func (c Chip) Supports(microsteps uint32) bool {
+	_, ok := c.modes[microsteps]
+	return ok
+}
+
+// Config is the wiring and the settings of a driver and its motor
+type Config struct {
+	Chip               Chip
+	StepsPerRevolution uint32 // full steps of a revolution of the motor
+	Microsteps         uint32 // microsteps per full step
+	EnableActiveLow    bool   // true when the torque is on with the enable pin low (~ENABLE) - false for a SLEEP-like pin
+
+	StepPin       byte
+	StepPwm       byte
+	DirPin        byte
+	EnablePin     byte
+	MicrostepPins [3]byte // MS1, MS2 and MS3 - NoPin when not wired
+}
+
+// Motor is a driver for a stepper motor driven by a step/dir chip
 type Motor struct {
-	dirGPIO gpio.Gpio
+	config Config
 
-	sleepGPIO gpio.Gpio
-	stepPwm   *pwm.Pwm
+	dirGPIO        gpio.Gpio
+	enableGPIO     gpio.Gpio
+	microstepGPIOs []gpio.Gpio
+	stepPwm        pwm.Pwm
 }
 
-func check(err error) {
-	if err != nil {
-		log.Fatal(err)
+func output(pin byte) (gpio.Gpio, error) {
+	if err := gpio.EnableGPIO(pin); err != nil {
+		return nil, err
+	}
+
+	g := gpio.New(pin)
+	if !g.IsExported() {
+		if err := g.Export(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := g.SetDirection(gpio.OutDirection); err != nil {
+		return nil, err
 	}
+	return g, nil
 }
 
 // New creates a new Motor for a stepper motor drived with GPIOs
-func New(stepPin, stepPwmID, dirPin, sleepPin byte) *Motor {
+func New(config Config) (*Motor, error) {
+	levels, ok := config.Chip.modes[config.Microsteps]
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't support %d microsteps", config.Chip.Name, config.Microsteps)
+	}
 
 	// Create the dir GPIO
-	err := gpio.EnableGPIO(dirPin)
-	check(err)
-
-	dirGPIO := gpio.New(dirPin)
-	if !dirGPIO.IsExported() {
-		err = dirGPIO.Export()
-		check(err)
+	dirGPIO, err := output(config.DirPin)
+	if err != nil {
+		return nil, err
 	}
 
-	err = dirGPIO.SetDirection(gpio.OutDirection)
-	check(err)
-
 	// Test Disabled and Enabled state for each pin
-	err = dirGPIO.Disable()
-	check(err)
-
-	// Create the sleep GPIO
-	err = gpio.EnableGPIO(sleepPin)
-	check(err)
-
-	sleepGPIO := gpio.New(sleepPin)
-	if !sleepGPIO.IsExported() {
-		err = sleepGPIO.Export()
-		check(err)
+	if err = dirGPIO.Disable(); err != nil {
+		return nil, err
 	}
 
-	err = sleepGPIO.SetDirection(gpio.OutDirection)
-	check(err)
+	// Create the enable GPIO
+	enableGPIO, err := output(config.EnablePin)
+	if err != nil {
+		return nil, err
+	}
 
-	// Test Disabled and Enabled state for each pin
-	err = sleepGPIO.Enable()
-	check(err)
+	// Select the microstep mode - the pins which are not wired are expected to be set accordingly
+	microstepGPIOs := []gpio.Gpio{}
+	for i, pin := range config.MicrostepPins {
+		if pin == NoPin {
+			continue
+		}
+		ms, err := output(pin)
+		if err != nil {
+			return nil, err
+		}
+		microstepGPIOs = append(microstepGPIOs, ms)
+		if levels[i] {
+			err = ms.Enable()
+		} else {
+			err = ms.Disable()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Create the Step pwm
-	stepPwm, err := pwm.New(stepPwmID, stepPin)
-	check(err)
+	stepPwm, err := pwm.New(config.StepPwm, config.StepPin)
+	if err != nil {
+		return nil, err
+	}
 	if !stepPwm.IsExported() {
-		err = stepPwm.Export()
-		check(err)
+		if err = stepPwm.Export(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = stepPwm.Disable(); err != nil {
+		return nil, err
 	}
 
-	err = stepPwm.Disable()
-	check(err)
+	m := &Motor{config: config, dirGPIO: dirGPIO, enableGPIO: enableGPIO, microstepGPIOs: microstepGPIOs, stepPwm: stepPwm}
+
+	// no torque until the motor is Enable(d)
+	if err = m.Disable(); err != nil {
+		return nil, err
+	}
 
-	return &Motor{dirGPIO: dirGPIO, sleepGPIO: sleepGPIO, stepPwm: stepPwm}
+	return m, nil
+}
+
+// StepsPerRevolution returns the number of microsteps of a revolution of the motor
+func (m Motor) StepsPerRevolution() uint32 {
+	return m.config.StepsPerRevolution * m.config.Microsteps
 }
 
 // Enable enables the torque
 func (m Motor) Enable() error {
-	return m.sleepGPIO.Disable()
+	if m.config.EnableActiveLow {
+		return m.enableGPIO.Disable()
+	}
+	return m.enableGPIO.Enable()
 }
 
 // Disable disables the torque
 func (m Motor) Disable() error {
-	return m.sleepGPIO.Enable()
+	if m.config.EnableActiveLow {
+		return m.enableGPIO.Enable()
+	}
+	return m.enableGPIO.Disable()
 }
 
 // Move makes the motor rotate in the given direction at the specified speed for a given duration -- make sure to Enable() the motor first
@@ -130,14 +239,15 @@ func (m Motor) Move(clockwise bool, stepsBySecond uint32, duration time.Duration
 	}
 
 	period := time.Duration(1. / float64(stepsBySecond) * float64(time.Second))
-	if period < pwm.MinPeriod {
+	minPeriod, maxPeriod := m.stepPwm.Limits()
+	if period < minPeriod {
 		originalPeriod := period
-		period = pwm.MinPeriod
+		period = minPeriod
 		log.Warning("period out of bounds: changed from %d to %d", originalPeriod, period)
 	}
-	if period > pwm.MaxPeriod {
+	if period > maxPeriod {
 		originalPeriod := period
-		period = pwm.MaxPeriod
+		period = maxPeriod
 		log.Warning("period out of bounds: changed from %d to %d", originalPeriod, period)
 	}
 
@@ -172,6 +282,9 @@ func (m Motor) Move(clockwise bool, stepsBySecond uint32, duration time.Duration
 // Unexport unexports the GPIO used by to drive the motor
 func (m Motor) Unexport() {
 	m.dirGPIO.Unexport()
-	m.sleepGPIO.Unexport()
+	m.enableGPIO.Unexport()
+	for _, ms := range m.microstepGPIOs {
+		ms.Unexport()
+	}
 	m.stepPwm.Unexport()
 }
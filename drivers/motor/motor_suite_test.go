@@ -0,0 +1,84 @@
+package motor
+
+import (
+	"github.com/ssoudan/edisonIsThePilot/drivers/sysfs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestMotor(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Motor Suite")
+}
+
+var _ = Describe("Motor", func() {
+
+	var (
+		fake   *sysfs.Fake
+		config Config
+	)
+
+	BeforeEach(func() {
+		fake = sysfs.NewFake()
+		sysfs.Set(fake)
+
+		config = Config{
+			Chip:               Chips[A4988Chip],
+			StepsPerRevolution: 200,
+			Microsteps:         8,
+			EnableActiveLow:    true,
+			StepPin:            182,
+			StepPwm:            2,
+			DirPin:             165,
+			EnablePin:          12,
+			MicrostepPins:      [3]byte{14, 15, NoPin},
+		}
+	})
+
+	AfterEach(func() {
+		sysfs.SetRoot("/")
+	})
+
+	value := func(pin string) string {
+		v, err := fake.ReadFile("/sys/class/gpio/gpio" + pin + "/value")
+		Expect(err).NotTo(HaveOccurred())
+		return v
+	}
+
+	It("selects the microstep mode", func() {
+		m, err := New(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.StepsPerRevolution()).To(BeEquivalentTo(1600))
+		Expect(value("14")).To(Equal("1\n"))
+		Expect(value("15")).To(Equal("1\n"))
+		Expect(fake.Exists("/sys/class/gpio/gpio255")).To(BeFalse())
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("follows the polarity of the enable pin", func() {
+		m, err := New(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value("12")).To(Equal("1\n"), "disabled")
+		Expect(m.Enable()).To(Succeed())
+		Expect(value("12")).To(Equal("0\n"))
+
+		m.Unexport()
+		config.EnableActiveLow = false
+		m, err = New(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(value("12")).To(Equal("0\n"), "disabled")
+		Expect(m.Enable()).To(Succeed())
+		Expect(value("12")).To(Equal("1\n"))
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("knows the modes of the chips", func() {
+		Expect(Chips[A4988Chip].Supports(16)).To(BeTrue())
+		Expect(Chips[A4988Chip].Supports(32)).To(BeFalse())
+		Expect(Chips[DRV8825Chip].Supports(32)).To(BeTrue())
+	})
+
+})
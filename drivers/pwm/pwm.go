@@ -18,97 +18,203 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-18 14:10:18
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 13:09:54
+* @Last Modified time: 2015-12-18 19:33:26
  */
 
 package pwm
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ssoudan/edisonIsThePilot/drivers/gpio"
+	"github.com/ssoudan/edisonIsThePilot/drivers/sysfs"
 )
 
 const (
-	maxPeriodNanoSec = 218453000
-	// MaxPeriod is the maximum period a PWM can have
-	MaxPeriod        = maxPeriodNanoSec * time.Nanosecond
-	minPeriodNanoSec = 104
-	// MinPeriod is the minimum period a PWM can have
-	MinPeriod = minPeriodNanoSec * time.Nanosecond
+	// NormalPolarity is the polarity of a pwm high during the duty cycle
+	NormalPolarity = "normal"
+	// InversedPolarity is the polarity of a pwm low during the duty cycle
+	InversedPolarity = "inversed"
 )
 
-// Pwm is a pulse width modulation output driver
-type Pwm struct {
-	pwmID  uint8
-	pwmPin byte
-	gpio   gpio.Gpio
+const (
+	sysfsPwmCount    = "/sys/class/pwm/pwmchip%d/npwm"
+	sysfsPwmExport   = "/sys/class/pwm/pwmchip%d/export"
+	sysfsPwmUnexport = "/sys/class/pwm/pwmchip%d/unexport"
+	sysfsPwmDir      = "/sys/class/pwm/pwmchip%d/pwm%d/"
+	sysfsPwmPeriod   = "/sys/class/pwm/pwmchip%d/pwm%d/period"
+	sysfsPwmDuty     = "/sys/class/pwm/pwmchip%d/pwm%d/duty_cycle"
+	sysfsPwmEnable   = "/sys/class/pwm/pwmchip%d/pwm%d/enable"
+	sysfsPwmPolarity = "/sys/class/pwm/pwmchip%d/pwm%d/polarity"
+)
+
+// Pwm is a pulse width modulation output
+type Pwm interface {
+	// IsExported returns true when the pwm is ready to be used
+	IsExported() bool
+	// Export makes the pwm usable
+	Export() error
+	// Unexport releases the pwm
+	Unexport() error
+	// Limits returns the shortest and the longest periods of the pwm
+	Limits() (min, max time.Duration)
+	// SetPolarity sets the polarity of the disabled pwm (use constants NormalPolarity and InversedPolarity)
+	SetPolarity(polarity string) error
+	// SetPeriodAndDutyCycle configures the pwm for a given period and duty cycle ratio
+	SetPeriodAndDutyCycle(period time.Duration, dutyCycle float32) error
+	// SetDutyCycle changes the duty cycle ratio keeping the period - without going through a transient state
+	SetDutyCycle(dutyCycle float32) error
+	// Enable this pwm
+	Enable() error
+	// Disable this pwm
+	Disable() error
 }
 
-// New returns a new PWM for a given pwmID - See Edison Breakout documentation to figure out which one you want.
-func New(pwmID uint8, pwmPin byte) (*Pwm, error) {
-	var err error
-	var g = gpio.New(pwmPin)
-	if !g.IsExported() {
-		err = g.Export()
-		if err != nil {
-			return nil, err
-		}
-	}
+// Chip is a pwm controller - /sys/class/pwm/pwmchipN.
+//
+// The sysfs interface only tells the number of pwm of a controller (npwm): the limits of the periods are
+// the ones of the hardware, known from the datasheet.
+type Chip struct {
+	Number      uint8
+	MinPeriod   time.Duration // shortest period of its pwm
+	MaxPeriod   time.Duration // longest period of its pwm
+	Multiplexed bool          // true when the pins of the pwm are gpio driven low while the pwm is disabled (see gpio.Pinmux)
+}
+
+// Edison is the pwm controller of the Intel Edison
+func Edison(number uint8) Chip {
+	return Chip{Number: number, MinPeriod: 104 * time.Nanosecond, MaxPeriod: 218453000 * time.Nanosecond, Multiplexed: true}
+}
+
+// Generic is a pwm controller whose limits are only the ones of the sysfs interface
+func Generic(number uint8) Chip {
+	return Chip{Number: number, MinPeriod: time.Nanosecond, MaxPeriod: math.MaxUint32 * time.Nanosecond}
+}
+
+var chip = Edison(0)
 
-	err = g.SetDirection(gpio.OutDirection)
+// SetChip selects the controller of the pwm created by New - Edison(0) by default
+func SetChip(c Chip) {
+	chip = c
+}
+
+// Count returns the number of pwm of the controller
+func (c Chip) Count() (int, error) {
+	count, err := sysfs.ReadFile(fmt.Sprintf(sysfsPwmCount, c.Number))
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("no pwmchip%d: %v", c.Number, err)
 	}
+	return strconv.Atoi(strings.TrimSpace(count))
+}
 
-	err = g.Disable()
+// sysfsPwm is a pwm driven through /sys/class/pwm
+type sysfsPwm struct {
+	chip    Chip
+	channel uint8
+	pin     byte
+}
+
+// New returns a new Pwm for a channel of the selected controller and the pin where it is output -
+// See Edison Breakout documentation to figure out which one you want.
+func New(channel uint8, pin byte) (Pwm, error) {
+	count, err := chip.Count()
 	if err != nil {
 		return nil, err
 	}
+	if int(channel) >= count {
+		return nil, fmt.Errorf("pwmchip%d only has %d pwm - no pwm%d", chip.Number, count, channel)
+	}
+
+	if chip.Multiplexed {
+		// the pin is a gpio while the pwm is disabled
+		var g = gpio.New(pin)
+		if !g.IsExported() {
+			if err := g.Export(); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := g.SetDirection(gpio.OutDirection); err != nil {
+			return nil, err
+		}
+
+		if err := g.Disable(); err != nil {
+			return nil, err
+		}
+	}
 
-	return &Pwm{pwmID: pwmID, pwmPin: pwmPin, gpio: g}, nil
+	return &sysfsPwm{chip: chip, channel: channel, pin: pin}, nil
 }
 
-func writeTo(filename string, content string) error {
-	return ioutil.WriteFile(filename, []byte(content), 0644)
+func (p *sysfsPwm) attribute(format string) string {
+	return fmt.Sprintf(format, p.chip.Number, p.channel)
 }
 
 // IsExported returns true with the pwm is already exported and usable from sysfs.
-func (p Pwm) IsExported() bool {
-	if _, err := os.Stat(fmt.Sprintf("/sys/class/pwm/pwmchip0/pwm%d/", p.pwmID)); os.IsNotExist(err) {
-		return false
-	}
-	return true
+func (p *sysfsPwm) IsExported() bool {
+	return sysfs.Exists(p.attribute(sysfsPwmDir))
 }
 
 // Export the pwm to be usable from sysfs.
-func (p Pwm) Export() error {
-
-	return writeTo("/sys/class/pwm/pwmchip0/export", fmt.Sprintf("%d", p.pwmID))
+func (p *sysfsPwm) Export() error {
+	return sysfs.WriteFile(fmt.Sprintf(sysfsPwmExport, p.chip.Number), fmt.Sprintf("%d", p.channel))
 }
 
 // Unexport the pwm from sysfs.
-func (p Pwm) Unexport() error {
-	return writeTo("/sys/class/pwm/pwmchip0/unexport", fmt.Sprintf("%d", p.pwmID))
+func (p *sysfsPwm) Unexport() error {
+	return sysfs.WriteFile(fmt.Sprintf(sysfsPwmUnexport, p.chip.Number), fmt.Sprintf("%d", p.channel))
+}
+
+// Limits returns the shortest and the longest periods of the pwm
+func (p *sysfsPwm) Limits() (time.Duration, time.Duration) {
+	return p.chip.MinPeriod, p.chip.MaxPeriod
+}
+
+// SetPolarity sets the polarity of the pwm - it must be disabled.
+// The polarity is read back as some controllers only support the normal one.
+func (p *sysfsPwm) SetPolarity(polarity string) error {
+	if polarity != NormalPolarity && polarity != InversedPolarity {
+		return fmt.Errorf("Incorrect polarity: %s", polarity)
+	}
+	if err := sysfs.WriteFile(p.attribute(sysfsPwmPolarity), polarity); err != nil {
+		return err
+	}
+
+	current, err := sysfs.ReadFile(p.attribute(sysfsPwmPolarity))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(current) != polarity {
+		return fmt.Errorf("pwm%d of pwmchip%d does not support the %s polarity", p.channel, p.chip.Number, polarity)
+	}
+	return nil
 }
 
 // SetPeriodAndDutyCycle configures the pwm for a given period and duty cycle ratio.
 // Note this might go through a transient state if the pwm is Enabled
-func (p *Pwm) SetPeriodAndDutyCycle(period time.Duration, dutyCycle float32) error {
-	if period < MinPeriod || period > MaxPeriod {
-		return fmt.Errorf("must be in 104:218453000 ns range")
+func (p *sysfsPwm) SetPeriodAndDutyCycle(period time.Duration, dutyCycle float32) error {
+	if period < p.chip.MinPeriod || period > p.chip.MaxPeriod {
+		return fmt.Errorf("must be in %d:%d ns range", p.chip.MinPeriod.Nanoseconds(), p.chip.MaxPeriod.Nanoseconds())
 	}
 
 	if dutyCycle < 0 || dutyCycle > 1 {
 		return fmt.Errorf("must be in 0:1 range")
 	}
 
-	if err := p.setDutyCycleNanoSec(1); err != nil {
+	// the duty cycle can't be longer than the period: it is shortened first unless there is no period yet
+	current, err := p.periodNanoSecond()
+	if err != nil {
 		return err
 	}
+	if current > 0 {
+		if err := p.setDutyCycleNanoSec(1); err != nil {
+			return err
+		}
+	}
 	if err := p.setPeriodNanoSecond(period.Nanoseconds()); err != nil {
 		return err
 	}
@@ -122,35 +228,56 @@ func (p *Pwm) SetPeriodAndDutyCycle(period time.Duration, dutyCycle float32) err
 	return nil
 }
 
-func (p Pwm) setDutyCycleNanoSec(dutyCycle int64) error {
-	return writeTo(fmt.Sprintf("/sys/class/pwm/pwmchip0/pwm%d/duty_cycle", p.pwmID), fmt.Sprintf("%d", dutyCycle))
+// SetDutyCycle changes the duty cycle ratio of the pwm keeping its period - it must have been set before
+func (p *sysfsPwm) SetDutyCycle(dutyCycle float32) error {
+	if dutyCycle < 0 || dutyCycle > 1 {
+		return fmt.Errorf("must be in 0:1 range")
+	}
+
+	period, err := p.periodNanoSecond()
+	if err != nil {
+		return err
+	}
+	if period == 0 {
+		return fmt.Errorf("pwm%d of pwmchip%d has no period", p.channel, p.chip.Number)
+	}
+	return p.setDutyCycleNanoSec((int64)(float32(period) * dutyCycle))
 }
 
-func (p Pwm) setPeriodNanoSecond(period int64) error {
+func (p *sysfsPwm) setDutyCycleNanoSec(dutyCycle int64) error {
+	return sysfs.WriteFile(p.attribute(sysfsPwmDuty), fmt.Sprintf("%d", dutyCycle))
+}
 
-	if period > maxPeriodNanoSec || period < minPeriodNanoSec {
-		return fmt.Errorf("must be in 104:218453000 range")
+func (p *sysfsPwm) periodNanoSecond() (int64, error) {
+	period, err := sysfs.ReadFile(p.attribute(sysfsPwmPeriod))
+	if err != nil {
+		return 0, err
 	}
+	return strconv.ParseInt(strings.TrimSpace(period), 10, 64)
+}
 
-	return writeTo(fmt.Sprintf("/sys/class/pwm/pwmchip0/pwm%d/period", p.pwmID), fmt.Sprintf("%d", period))
+func (p *sysfsPwm) setPeriodNanoSecond(period int64) error {
+	return sysfs.WriteFile(p.attribute(sysfsPwmPeriod), fmt.Sprintf("%d", period))
 }
 
 // Enable this pwm
-func (p Pwm) Enable() error {
-	err := gpio.EnablePWM(p.pwmPin)
-	if err != nil {
-		return err
+func (p *sysfsPwm) Enable() error {
+	if p.chip.Multiplexed {
+		if err := gpio.EnablePWM(p.pin); err != nil {
+			return err
+		}
 	}
 
-	return writeTo(fmt.Sprintf("/sys/class/pwm/pwmchip0/pwm%d/enable", p.pwmID), "1")
+	return sysfs.WriteFile(p.attribute(sysfsPwmEnable), "1")
 }
 
 // Disable this pwm
-func (p Pwm) Disable() error {
-	err := gpio.EnableGPIO(p.pwmPin)
-	if err != nil {
-		return err
+func (p *sysfsPwm) Disable() error {
+	if p.chip.Multiplexed {
+		if err := gpio.EnableGPIO(p.pin); err != nil {
+			return err
+		}
 	}
 
-	return writeTo(fmt.Sprintf("/sys/class/pwm/pwmchip0/pwm%d/enable", p.pwmID), "0")
+	return sysfs.WriteFile(p.attribute(sysfsPwmEnable), "0")
 }
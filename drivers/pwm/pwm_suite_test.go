@@ -0,0 +1,135 @@
+package pwm
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/drivers/sysfs"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestPwm(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Pwm Suite")
+}
+
+// normalOnly is a controller ignoring the changes of polarity
+type normalOnly struct {
+	*sysfs.Fake
+}
+
+func (f normalOnly) WriteFile(name, content string) error {
+	if strings.HasSuffix(name, "/polarity") {
+		return nil
+	}
+	return f.Fake.WriteFile(name, content)
+}
+
+var _ = Describe("Pwm", func() {
+
+	var fake *sysfs.Fake
+
+	BeforeEach(func() {
+		fake = sysfs.NewFake()
+		sysfs.Set(fake)
+	})
+
+	AfterEach(func() {
+		sysfs.SetRoot("/")
+		SetChip(Edison(0))
+	})
+
+	It("only writes legal sequences", func() {
+		p, err := New(3, 183)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.IsExported()).To(BeFalse())
+		Expect(p.Export()).To(Succeed())
+		Expect(p.IsExported()).To(BeTrue())
+
+		Expect(p.SetPeriodAndDutyCycle(time.Millisecond, 0.5)).To(Succeed())
+		Expect(fake.ReadFile("/sys/class/pwm/pwmchip0/pwm3/duty_cycle")).To(Equal("500000\n"))
+		Expect(p.Enable()).To(Succeed())
+		Expect(fake.ReadFile("/sys/kernel/debug/gpio_debug/gpio183/current_pinmux")).To(Equal("mode1\n"))
+
+		// a shorter period than the current duty cycle
+		Expect(p.SetPeriodAndDutyCycle(100*time.Microsecond, 0.25)).To(Succeed())
+		Expect(fake.ReadFile("/sys/class/pwm/pwmchip0/pwm3/period")).To(Equal("100000\n"))
+		Expect(fake.ReadFile("/sys/class/pwm/pwmchip0/pwm3/duty_cycle")).To(Equal("25000\n"))
+
+		Expect(p.SetDutyCycle(0.75)).To(Succeed())
+		Expect(fake.ReadFile("/sys/class/pwm/pwmchip0/pwm3/period")).To(Equal("100000\n"))
+		Expect(fake.ReadFile("/sys/class/pwm/pwmchip0/pwm3/duty_cycle")).To(Equal("75000\n"))
+		Expect(p.SetDutyCycle(1.5)).NotTo(Succeed())
+
+		Expect(p.Disable()).To(Succeed())
+		Expect(fake.ReadFile("/sys/class/pwm/pwmchip0/pwm3/enable")).To(Equal("0\n"))
+		Expect(p.Unexport()).To(Succeed())
+
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("needs a period before a duty cycle", func() {
+		p, err := New(3, 183)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Export()).To(Succeed())
+		Expect(p.SetDutyCycle(0.5)).NotTo(Succeed())
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("can't be enabled before being exported", func() {
+		p, err := New(3, 183)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Enable()).NotTo(Succeed())
+	})
+
+	It("only has the pwm of its controller", func() {
+		_, err := New(4, 183)
+		Expect(err).To(HaveOccurred())
+
+		SetChip(Generic(1))
+		_, err = New(0, 183)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("leaves the pin alone on a generic controller", func() {
+		SetChip(Generic(0))
+		p, err := New(1, 13)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Export()).To(Succeed())
+
+		min, max := p.Limits()
+		Expect(min).To(Equal(time.Nanosecond))
+		Expect(max).To(BeNumerically(">", time.Second))
+		Expect(p.SetPeriodAndDutyCycle(2*time.Second, 0.5)).To(Succeed())
+
+		Expect(p.SetPolarity(InversedPolarity)).To(Succeed())
+		Expect(p.Enable()).To(Succeed())
+		Expect(p.SetPolarity(NormalPolarity)).NotTo(Succeed(), "the pwm is enabled")
+
+		Expect(fake.Files()).NotTo(ContainElement(ContainSubstring("gpio")))
+		Expect(fake.Violations()).To(HaveLen(1))
+	})
+
+	It("reads the polarity back", func() {
+		sysfs.Set(normalOnly{fake})
+		SetChip(Generic(0))
+		p, err := New(1, 13)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Export()).To(Succeed())
+
+		Expect(p.SetPolarity(NormalPolarity)).To(Succeed())
+		Expect(p.SetPolarity(InversedPolarity)).NotTo(Succeed(), "the controller kept the normal polarity")
+	})
+
+	It("respects the limits of the Edison", func() {
+		p, err := New(2, 182)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Export()).To(Succeed())
+		Expect(p.SetPeriodAndDutyCycle(time.Second, 0.5)).NotTo(Succeed())
+	})
+
+})
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-10-12 19:20:55
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 12:12:32
+* @Last Modified time: 2015-11-25 20:36:09
  */
 
 package sincos
@@ -26,6 +26,7 @@ package sincos
 import (
 	"math"
 
+	"github.com/ssoudan/edisonIsThePilot/drivers/i2c"
 	"github.com/ssoudan/edisonIsThePilot/drivers/mcp4725"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 )
@@ -52,17 +53,12 @@ type SinCos struct {
 // New creates a new SinCos interface (through 2 MCP4725 on an i2c bus)
 func New(bus, sinAddr, cosAddr byte) *SinCos {
 
-	sin, err := mcp4725.New(bus, sinAddr)
+	i2cBus, err := i2c.Open(bus)
 	if err != nil {
 		log.Panic(err)
 	}
 
-	cos, err := mcp4725.New(bus, cosAddr)
-	if err != nil {
-		log.Panic(err)
-	}
-
-	return &SinCos{sin: sin, cos: cos}
+	return &SinCos{sin: mcp4725.New(i2cBus, sinAddr), cos: mcp4725.New(i2cBus, cosAddr)}
 }
 
 // UpdateCourse sets the Sin/Cos outputs to the values that correspond to the provided course (in degree)
@@ -0,0 +1,377 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-22 15:03:51
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-23 21:27:14
+ */
+
+package sysfs
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	gpioExport   = "/sys/class/gpio/export"
+	gpioUnexport = "/sys/class/gpio/unexport"
+	pwmChip      = "/sys/class/pwm/pwmchip%d"
+
+	// FakePwmCount is the number of PWM of each pwmchip of the fake - as on the Edison
+	FakePwmCount = 4
+)
+
+var (
+	gpioAttribute    = regexp.MustCompile(`^/sys/class/gpio/gpio(\d+)/(\w+)$`)
+	pwmChipAttribute = regexp.MustCompile(`^/sys/class/pwm/pwmchip(\d+)/(export|unexport|npwm)$`)
+	pwmAttribute     = regexp.MustCompile(`^/sys/class/pwm/pwmchip\d+/pwm(\d+)/(\w+)$`)
+	pinmuxAttribute  = regexp.MustCompile(`^/sys/kernel/debug/gpio_debug/gpio(\d+)/current_pinmux$`)
+	i2cModeAttribute = regexp.MustCompile(`^/sys/class/i2c-adapter/i2c-(\d+)/device/i2c_dw_sysnode/mode$`)
+)
+
+// Violation is an access to the fake the kernel would have refused
+type Violation struct {
+	Path   string
+	Reason string
+	Err    syscall.Errno
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("%s: %s (%v)", v.Path, v.Reason, v.Err)
+}
+
+// Fake is an in-memory FS modelling the gpio, pwm, pinmux and i2c mode attributes of the kernel.
+// The illegal accesses are refused, like the kernel does, and recorded as Violations.
+type Fake struct {
+	mu         sync.Mutex
+	files      map[string]string // attribute values without the trailing new line
+	violations []error
+}
+
+// NewFake creates a Fake with nothing exported and FakePwmCount pwm on each of the pwmChips - on pwmchip0 when none is given
+func NewFake(pwmChips ...int) *Fake {
+	if len(pwmChips) == 0 {
+		pwmChips = []int{0}
+	}
+
+	f := &Fake{files: make(map[string]string)}
+	for _, chip := range pwmChips {
+		f.files[fmt.Sprintf(pwmChip, chip)+"/npwm"] = strconv.Itoa(FakePwmCount)
+	}
+	return f
+}
+
+// Violations returns the illegal accesses since the creation of the Fake
+func (f *Fake) Violations() []error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]error{}, f.violations...)
+}
+
+func (f *Fake) violation(name string, err syscall.Errno, format string, args ...interface{}) error {
+	v := Violation{Path: name, Reason: fmt.Sprintf(format, args...), Err: err}
+	f.violations = append(f.violations, v)
+	return v
+}
+
+// ReadFile returns the content of an attribute
+func (f *Fake) ReadFile(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name = path.Clean(name)
+	value, ok := f.files[name]
+	if !ok {
+		return "", &os.PathError{Op: "open", Path: name, Err: syscall.ENOENT}
+	}
+
+	if m := gpioAttribute.FindStringSubmatch(name); m != nil && m[2] == "value" {
+		// the level is physical - the value is inverted for an active low gpio
+		value = level(value != f.files[path.Dir(name)+"/active_low"])
+	}
+	return value + "\n", nil
+}
+
+// Exists returns true when an attribute or a directory exists
+func (f *Fake) Exists(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.exists(path.Clean(name))
+}
+
+func (f *Fake) exists(name string) bool {
+	if _, ok := f.files[name]; ok {
+		return true
+	}
+	for file := range f.files {
+		if strings.HasPrefix(file, name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Files returns the names of the existing attributes
+func (f *Fake) Files() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	names := []string{}
+	for name := range f.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetInput changes the level of an exported input gpio - as the hardware would
+func (f *Fake) SetInput(pin int, high bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dir := fmt.Sprintf("/sys/class/gpio/gpio%d", pin)
+	direction, ok := f.files[dir+"/direction"]
+	if !ok {
+		return fmt.Errorf("gpio%d is not exported", pin)
+	}
+	if direction != "in" {
+		return fmt.Errorf("gpio%d is not an input", pin)
+	}
+	f.files[dir+"/value"] = level(high)
+	return nil
+}
+
+func level(high bool) string {
+	if high {
+		return "1"
+	}
+	return "0"
+}
+
+func (f *Fake) remove(dir string) {
+	for file := range f.files {
+		if strings.HasPrefix(file, dir+"/") {
+			delete(f.files, file)
+		}
+	}
+}
+
+// WriteFile writes content to an attribute
+func (f *Fake) WriteFile(name, content string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name = path.Clean(name)
+	value := strings.TrimSpace(content)
+
+	switch name {
+	case gpioExport, gpioUnexport:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return f.violation(name, syscall.EINVAL, "invalid number %q", value)
+		}
+		return f.exportGpio(name, n)
+	}
+
+	if m := pwmChipAttribute.FindStringSubmatch(name); m != nil {
+		if !f.exists(path.Dir(name)) {
+			return f.violation(name, syscall.ENOENT, "no %s", path.Base(path.Dir(name)))
+		}
+		if m[2] == "npwm" {
+			return f.violation(name, syscall.EACCES, "read-only attribute")
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return f.violation(name, syscall.EINVAL, "invalid number %q", value)
+		}
+		return f.exportPwm(name, path.Dir(name), m[2], n)
+	}
+
+	if m := gpioAttribute.FindStringSubmatch(name); m != nil {
+		return f.writeGpio(name, path.Dir(name), m[2], value)
+	}
+	if m := pwmAttribute.FindStringSubmatch(name); m != nil {
+		return f.writePwm(name, path.Dir(name), m[2], value)
+	}
+	if pinmuxAttribute.MatchString(name) {
+		if matched, _ := regexp.MatchString(`^mode[0-3]$`, value); !matched {
+			return f.violation(name, syscall.EINVAL, "invalid pinmux mode %q", value)
+		}
+		f.files[name] = value
+		return nil
+	}
+	if i2cModeAttribute.MatchString(name) {
+		if value != "std" && value != "fast" && value != "high" {
+			return f.violation(name, syscall.EINVAL, "invalid i2c mode %q", value)
+		}
+		f.files[name] = value
+		return nil
+	}
+	return f.violation(name, syscall.ENOENT, "no such attribute")
+}
+
+func (f *Fake) exportGpio(name string, n int) error {
+	dir := fmt.Sprintf("/sys/class/gpio/gpio%d", n)
+	switch name {
+	case gpioExport:
+		if f.exists(dir) {
+			return f.violation(name, syscall.EBUSY, "gpio%d is already exported", n)
+		}
+		f.files[dir+"/direction"] = "in"
+		f.files[dir+"/value"] = "0"
+		f.files[dir+"/active_low"] = "0"
+		f.files[dir+"/edge"] = "none"
+
+	case gpioUnexport:
+		if !f.exists(dir) {
+			return f.violation(name, syscall.EINVAL, "gpio%d is not exported", n)
+		}
+		f.remove(dir)
+	}
+	return nil
+}
+
+func (f *Fake) exportPwm(name, chip, attribute string, n int) error {
+	dir := fmt.Sprintf("%s/pwm%d", chip, n)
+	switch attribute {
+	case "export":
+		if n >= FakePwmCount {
+			return f.violation(name, syscall.ENODEV, "%s only has %d pwm", path.Base(chip), FakePwmCount)
+		}
+		if f.exists(dir) {
+			return f.violation(name, syscall.EBUSY, "pwm%d is already exported", n)
+		}
+		f.files[dir+"/period"] = "0"
+		f.files[dir+"/duty_cycle"] = "0"
+		f.files[dir+"/enable"] = "0"
+		f.files[dir+"/polarity"] = "normal"
+
+	case "unexport":
+		if !f.exists(dir) {
+			return f.violation(name, syscall.EINVAL, "pwm%d is not exported", n)
+		}
+		f.remove(dir)
+	}
+	return nil
+}
+
+func (f *Fake) writeGpio(name, dir, attribute, value string) error {
+	if !f.exists(dir) {
+		return f.violation(name, syscall.ENOENT, "%s is not exported", path.Base(dir))
+	}
+
+	switch attribute {
+	case "direction":
+		switch value {
+		case "in", "out":
+			f.files[name] = value
+		case "high", "low": // output with an initial level
+			f.files[name] = "out"
+			f.files[dir+"/value"] = level(value == "high")
+		default:
+			return f.violation(name, syscall.EINVAL, "invalid direction %q", value)
+		}
+		if f.files[name] == "out" {
+			f.files[dir+"/edge"] = "none"
+		}
+
+	case "value":
+		if f.files[dir+"/direction"] != "out" {
+			return f.violation(name, syscall.EPERM, "%s is an input", path.Base(dir))
+		}
+		if value != "0" && value != "1" {
+			return f.violation(name, syscall.EINVAL, "invalid value %q", value)
+		}
+		f.files[name] = level((value == "1") != (f.files[dir+"/active_low"] == "1"))
+
+	case "active_low":
+		if value != "0" && value != "1" {
+			return f.violation(name, syscall.EINVAL, "invalid active_low %q", value)
+		}
+		f.files[name] = value
+
+	case "edge":
+		if f.files[dir+"/direction"] != "in" {
+			return f.violation(name, syscall.EIO, "%s is an output", path.Base(dir))
+		}
+		switch value {
+		case "none", "rising", "falling", "both":
+			f.files[name] = value
+		default:
+			return f.violation(name, syscall.EINVAL, "invalid edge %q", value)
+		}
+
+	default:
+		return f.violation(name, syscall.ENOENT, "no such attribute")
+	}
+	return nil
+}
+
+func (f *Fake) writePwm(name, dir, attribute, value string) error {
+	if !f.exists(dir) {
+		return f.violation(name, syscall.ENOENT, "%s is not exported", path.Base(dir))
+	}
+
+	if attribute == "polarity" {
+		if f.files[dir+"/enable"] != "0" {
+			return f.violation(name, syscall.EBUSY, "%s is enabled", path.Base(dir))
+		}
+		if value != "normal" && value != "inversed" {
+			return f.violation(name, syscall.EINVAL, "invalid polarity %q", value)
+		}
+		f.files[name] = value
+		return nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return f.violation(name, syscall.EINVAL, "invalid number %q", value)
+	}
+	period, _ := strconv.ParseInt(f.files[dir+"/period"], 10, 64)
+	dutyCycle, _ := strconv.ParseInt(f.files[dir+"/duty_cycle"], 10, 64)
+
+	switch attribute {
+	case "period":
+		if n < dutyCycle {
+			return f.violation(name, syscall.EINVAL, "period %d is shorter than the duty cycle %d", n, dutyCycle)
+		}
+	case "duty_cycle":
+		if n > period {
+			return f.violation(name, syscall.EINVAL, "duty cycle %d is longer than the period %d", n, period)
+		}
+	case "enable":
+		if n > 1 {
+			return f.violation(name, syscall.EINVAL, "invalid enable %q", value)
+		}
+		if n == 1 && period == 0 {
+			return f.violation(name, syscall.EINVAL, "%s has no period", path.Base(dir))
+		}
+	default:
+		return f.violation(name, syscall.ENOENT, "no such attribute")
+	}
+	f.files[name] = value
+	return nil
+}
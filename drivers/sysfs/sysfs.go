@@ -0,0 +1,105 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-22 15:03:51
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-22 15:03:51
+ */
+
+// Package sysfs gives access to the sysfs attributes used by the drivers: the real ones under a
+// configurable root, or a fake modelling the kernel to run the drivers without the hardware.
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// FS is where the sysfs attributes are read and written - names are absolute, e.g. /sys/class/gpio/export
+type FS interface {
+	// ReadFile returns the content of an attribute
+	ReadFile(name string) (string, error)
+	// WriteFile writes content to an attribute
+	WriteFile(name, content string) error
+	// Exists returns true when an attribute or a directory exists
+	Exists(name string) bool
+}
+
+// Dir is the FS of the files under a root directory - Dir("/") is the real sysfs
+type Dir string
+
+func (d Dir) path(name string) string {
+	return filepath.Join(string(d), name)
+}
+
+// ReadFile returns the content of an attribute
+func (d Dir) ReadFile(name string) (string, error) {
+	dat, err := ioutil.ReadFile(d.path(name))
+	if err != nil {
+		return "", err
+	}
+	return string(dat), nil
+}
+
+// WriteFile writes content to an attribute
+func (d Dir) WriteFile(name, content string) error {
+	return ioutil.WriteFile(d.path(name), []byte(content), 0644)
+}
+
+// Exists returns true when an attribute or a directory exists
+func (d Dir) Exists(name string) bool {
+	_, err := os.Stat(d.path(name))
+	return !os.IsNotExist(err)
+}
+
+var fs FS = Dir("/")
+
+// Set changes the FS used by the drivers
+func Set(f FS) {
+	fs = f
+}
+
+// SetRoot makes the drivers use the files under root - "/" by default
+func SetRoot(root string) {
+	Set(Dir(root))
+}
+
+// ReadFile returns the content of an attribute
+func ReadFile(name string) (string, error) {
+	return fs.ReadFile(name)
+}
+
+// WriteFile writes content to an attribute
+func WriteFile(name, content string) error {
+	return fs.WriteFile(name, content)
+}
+
+// Exists returns true when an attribute or a directory exists
+func Exists(name string) bool {
+	return fs.Exists(name)
+}
+
+// Path returns the path of an attribute on the file system, to poll it -
+// false when the attributes are not backed by files
+func Path(name string) (string, bool) {
+	if d, ok := fs.(Dir); ok {
+		return d.path(name), true
+	}
+	return "", false
+}
@@ -0,0 +1,140 @@
+package sysfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSysfs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sysfs Suite")
+}
+
+var _ = Describe("Dir", func() {
+
+	It("reads and writes the attributes under its root", func() {
+		root, err := ioutil.TempDir("", "sysfs")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(root)
+		Expect(os.MkdirAll(filepath.Join(root, "sys/class/gpio/gpio12"), 0755)).To(Succeed())
+
+		SetRoot(root)
+		defer SetRoot("/")
+
+		Expect(Exists("/sys/class/gpio/gpio12/")).To(BeTrue())
+		Expect(Exists("/sys/class/gpio/gpio13/")).To(BeFalse())
+		Expect(WriteFile("/sys/class/gpio/gpio12/value", "1\n")).To(Succeed())
+		Expect(ReadFile("/sys/class/gpio/gpio12/value")).To(Equal("1\n"))
+
+		path, ok := Path("/sys/class/gpio/gpio12/value")
+		Expect(ok).To(BeTrue())
+		Expect(path).To(Equal(filepath.Join(root, "sys/class/gpio/gpio12/value")))
+	})
+
+})
+
+var _ = Describe("Fake", func() {
+
+	var fake *Fake
+
+	BeforeEach(func() {
+		fake = NewFake()
+	})
+
+	It("models the export of a gpio", func() {
+		Expect(fake.Exists("/sys/class/gpio/gpio12/")).To(BeFalse())
+		Expect(fake.WriteFile("/sys/class/gpio/export", "12")).To(Succeed())
+		Expect(fake.Exists("/sys/class/gpio/gpio12/")).To(BeTrue())
+		Expect(fake.ReadFile("/sys/class/gpio/gpio12/direction")).To(Equal("in\n"))
+
+		Expect(fake.WriteFile("/sys/class/gpio/unexport", "12")).To(Succeed())
+		Expect(fake.Exists("/sys/class/gpio/gpio12/")).To(BeFalse())
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("models the value of a gpio", func() {
+		Expect(fake.WriteFile("/sys/class/gpio/export", "12")).To(Succeed())
+		Expect(fake.WriteFile("/sys/class/gpio/gpio12/direction", "out")).To(Succeed())
+		Expect(fake.WriteFile("/sys/class/gpio/gpio12/value", "1\n")).To(Succeed())
+		Expect(fake.ReadFile("/sys/class/gpio/gpio12/value")).To(Equal("1\n"))
+
+		Expect(fake.WriteFile("/sys/class/gpio/gpio12/active_low", "1")).To(Succeed())
+		Expect(fake.ReadFile("/sys/class/gpio/gpio12/value")).To(Equal("0\n"))
+
+		Expect(fake.WriteFile("/sys/class/gpio/gpio12/direction", "in")).To(Succeed())
+		Expect(fake.SetInput(12, false)).To(Succeed())
+		Expect(fake.ReadFile("/sys/class/gpio/gpio12/value")).To(Equal("1\n"))
+		Expect(fake.Violations()).To(BeEmpty())
+	})
+
+	It("refuses to write the value of an unexported gpio", func() {
+		Expect(fake.WriteFile("/sys/class/gpio/gpio12/value", "1")).NotTo(Succeed())
+		Expect(fake.Violations()).To(HaveLen(1))
+	})
+
+	It("refuses to write the value of an input", func() {
+		Expect(fake.WriteFile("/sys/class/gpio/export", "12")).To(Succeed())
+		Expect(fake.WriteFile("/sys/class/gpio/gpio12/value", "1")).NotTo(Succeed())
+	})
+
+	It("refuses to export a gpio twice", func() {
+		Expect(fake.WriteFile("/sys/class/gpio/export", "12")).To(Succeed())
+		Expect(fake.WriteFile("/sys/class/gpio/export", "12")).NotTo(Succeed())
+		Expect(fake.WriteFile("/sys/class/gpio/unexport", "13")).NotTo(Succeed())
+		Expect(fake.Violations()).To(HaveLen(2))
+	})
+
+	It("models the pwm", func() {
+		Expect(fake.ReadFile("/sys/class/pwm/pwmchip0/npwm")).To(Equal("4\n"))
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/export", "4")).NotTo(Succeed())
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/export", "2")).To(Succeed())
+
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/pwm2/enable", "1")).NotTo(Succeed(), "no period")
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/pwm2/duty_cycle", "10")).NotTo(Succeed(), "longer than the period")
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/pwm2/period", "100")).To(Succeed())
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/pwm2/duty_cycle", "50")).To(Succeed())
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/pwm2/period", "20")).NotTo(Succeed(), "shorter than the duty cycle")
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/pwm2/enable", "1")).To(Succeed())
+		Expect(fake.ReadFile("/sys/class/pwm/pwmchip0/pwm2/enable")).To(Equal("1\n"))
+
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/unexport", "2")).To(Succeed())
+		Expect(fake.Exists("/sys/class/pwm/pwmchip0/pwm2")).To(BeFalse())
+	})
+
+	It("models the pwm of the selected chips", func() {
+		fake := NewFake(2)
+		Expect(fake.Exists("/sys/class/pwm/pwmchip0")).To(BeFalse())
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip0/export", "1")).NotTo(Succeed())
+
+		Expect(fake.ReadFile("/sys/class/pwm/pwmchip2/npwm")).To(Equal("4\n"))
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip2/npwm", "8")).NotTo(Succeed())
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip2/export", "1")).To(Succeed())
+		Expect(fake.WriteFile("/sys/class/pwm/pwmchip2/pwm1/period", "100")).To(Succeed())
+		Expect(fake.ReadFile("/sys/class/pwm/pwmchip2/pwm1/period")).To(Equal("100\n"))
+		Expect(fake.Violations()).To(HaveLen(2))
+	})
+
+	It("models the pinmux and the i2c mode", func() {
+		Expect(fake.WriteFile("/sys/kernel/debug/gpio_debug/gpio183/current_pinmux", "mode1")).To(Succeed())
+		Expect(fake.ReadFile("/sys/kernel/debug/gpio_debug/gpio183/current_pinmux")).To(Equal("mode1\n"))
+		Expect(fake.WriteFile("/sys/kernel/debug/gpio_debug/gpio183/current_pinmux", "pwm")).NotTo(Succeed())
+
+		Expect(fake.WriteFile("/sys/class/i2c-adapter/i2c-6/device/i2c_dw_sysnode/mode", "fast")).To(Succeed())
+		Expect(fake.WriteFile("/sys/class/i2c-adapter/i2c-6/device/i2c_dw_sysnode/mode", "slow")).NotTo(Succeed())
+	})
+
+	It("has no edge support", func() {
+		Set(fake)
+		defer SetRoot("/")
+
+		_, ok := Path("/sys/class/gpio/gpio12/value")
+		Expect(ok).To(BeFalse())
+	})
+
+})
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-18 17:13:41
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 16:37:18
+* @Last Modified time: 2015-11-10 20:48:26
  */
 
 package gps
@@ -31,9 +31,8 @@ import (
 
 	"github.com/ssoudan/edisonIsThePilot/ap100"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
-	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/magnetic"
 	"github.com/ssoudan/edisonIsThePilot/pilot"
-	"github.com/ssoudan/edisonIsThePilot/tracer"
 
 	"github.com/adrianmo/go-nmea"
 	"github.com/tarm/serial"
@@ -51,7 +50,6 @@ type GPS struct {
 	headingChan  chan interface{}
 	errorChan    chan interface{}
 	panicChan    chan interface{}
-	tracerChan   chan interface{}
 }
 
 // New creates a new GPS component
@@ -59,6 +57,11 @@ func New(deviceName string) GPS {
 	return GPS{deviceName: deviceName, baud: 9600}
 }
 
+// SetBaud sets the baud rate of the serial port (9600 by default)
+func (g *GPS) SetBaud(baud int) {
+	g.baud = baud
+}
+
 // SetMessagesChan sets the channel where the GPS messages are delivered
 func (g *GPS) SetMessagesChan(c chan interface{}) {
 	g.messagesChan = c
@@ -69,7 +72,7 @@ func (g *GPS) SetHeadingChan(c chan interface{}) {
 	g.headingChan = c
 }
 
-// SetErrorChan sets the channel where parsing errors are posted
+// SetErrorChan sets the channel where parsing errors are posted - they are only logged when it is not set
 func (g *GPS) SetErrorChan(c chan interface{}) {
 	g.errorChan = c
 }
@@ -79,9 +82,39 @@ func (g *GPS) SetPanicChan(c chan interface{}) {
 	g.panicChan = c
 }
 
-// SetTracerChan sets the channel to the tracer
-func (g *GPS) SetTracerChan(c chan interface{}) {
-	g.tracerChan = c
+// fixTime returns the date and time of a RMC sentence - or now if they can't be parsed
+func fixTime(t nmea.GPRMC) time.Time {
+	date, err := time.Parse("020106 150405", t.Date+" "+strings.SplitN(t.Time, ".", 2)[0])
+	if err != nil {
+		return time.Now()
+	}
+	return date
+}
+
+// declination returns the magnetic variation of a RMC sentence when present (positive when East) or the one
+// computed from the World Magnetic Model for the position and date of the fix
+func declination(t nmea.GPRMC) float64 {
+	if len(t.Fields) > 10 && t.Fields[9] != "" {
+		variation, err := strconv.ParseFloat(t.Fields[9], 64)
+		switch {
+		case err != nil:
+			log.Warning("Invalid magnetic variation [%s]: %v", t.Fields[9], err)
+		case t.Fields[10] == "W":
+			return -variation
+		case t.Fields[10] == "E":
+			return variation
+		default:
+			log.Warning("Invalid magnetic variation direction [%s]", t.Fields[10])
+		}
+	}
+	return magnetic.Declination(float64(t.Latitude), float64(t.Longitude), fixTime(t))
+}
+
+// reportError posts an error to the error channel if any
+func (g GPS) reportError(err error) {
+	if g.errorChan != nil {
+		g.errorChan <- err
+	}
 }
 
 func (g GPS) doReceiveGPSMessages() {
@@ -110,15 +143,29 @@ func (g GPS) doReceiveGPSMessages() {
 		// log.Debug("[%s]", str)
 		if err != nil {
 			log.Error("Failed to read from serial port: %v", err)
-			g.errorChan <- err
+			g.reportError(err)
 			// Exit this method to close the port, and re-open it later
 			return
 		}
 
-		m, err := nmea.Parse(strings.TrimSuffix(str, "\r\n"))
+		sentence := strings.TrimSuffix(str, "\r\n")
+		if isWindSentence(sentence) {
+			wind, err := parseWind(sentence)
+			if err != nil {
+				// the wind data will go stale and the pilot will notice
+				log.Error("Failed to parse wind sentence: %v", err)
+				continue
+			}
+			log.Info("[WIND] validity: %v angle: %v[˚] speed: %v[knots] \n", wind.Validity, wind.Angle, wind.Speed)
+			g.messagesChan <- wind
+			continue
+		}
+
+		m, err := nmea.Parse(sentence)
 		if err != nil {
 			if !strings.HasSuffix(err.Error(), "not implemented") {
-				g.errorChan <- err
+				log.Warning("Failed to parse sentence: %v", err)
+				g.reportError(err)
 			}
 			// Here we don't return as it is a non-fatal error and the next line
 			// will be better
@@ -150,12 +197,9 @@ func (g GPS) doReceiveGPSMessages() {
 				Time:      t.Time,
 			}
 			if t.Validity == "A" {
-				g.headingChan <- ap100.NewMessage(uint16(t.Course))
-				g.tracerChan <- tracer.MkAddPointMessage(types.Point{
-					Latitude:  float64(t.Latitude),
-					Longitude: float64(t.Longitude),
-					Time:      types.JSONTime(time.Now()),
-				})
+				// the AP100 expects a magnetic course
+				declination := declination(t)
+				g.headingChan <- ap100.NewMessage(uint16(magnetic.ToMagnetic(t.Course, declination)))
 			}
 
 		}
@@ -0,0 +1,74 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-10 20:52:44
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-10 20:52:44
+ */
+
+package gps
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/magnetic"
+
+	"github.com/adrianmo/go-nmea"
+	"github.com/stretchr/testify/assert"
+)
+
+// rmc builds a RMC sentence off Brest on the 13th of November 2015 with the raw fields of the magnetic variation -
+// the Variation decoded by the library is left out on purpose
+func rmc(variation, direction string) nmea.GPRMC {
+	return nmea.GPRMC{
+		Sentence: nmea.Sentence{
+			Type:   "GPRMC",
+			Fields: []string{"220516", "A", "4821.00", "N", "00430.00", "W", "6.8", "231.8", "131115", variation, direction},
+		},
+		Time:      "220516",
+		Validity:  "A",
+		Latitude:  48.35,
+		Longitude: -4.5,
+		Speed:     6.8,
+		Course:    231.8,
+		Date:      "131115",
+	}
+}
+
+func TestFixTime(t *testing.T) {
+	assert.EqualValues(t, time.Date(2015, time.November, 13, 22, 5, 16, 0, time.UTC), fixTime(rmc("", "")))
+}
+
+func TestThatDeclinationComesFromTheSentenceWhenPresent(t *testing.T) {
+	assert.EqualValues(t, 4.2, declination(rmc("004.2", "E")), "easterly variation")
+	assert.EqualValues(t, -4.2, declination(rmc("004.2", "W")), "westerly variation")
+}
+
+func TestThatDeclinationFallsBackToTheWorldMagneticModel(t *testing.T) {
+	expected := magnetic.Declination(48.35, -4.5, time.Date(2015, time.November, 13, 22, 5, 16, 0, time.UTC))
+
+	assert.EqualValues(t, expected, declination(rmc("", "")), "empty variation")
+	assert.EqualValues(t, expected, declination(rmc("4.2.1", "E")), "invalid variation")
+	assert.EqualValues(t, expected, declination(rmc("004.2", "")), "no direction")
+	assert.NotEqual(t, 0., expected, "the model knows about the variation off Brest")
+
+	sentence := rmc("", "")
+	sentence.Fields = sentence.Fields[:9]
+	assert.EqualValues(t, expected, declination(sentence), "no variation field at all")
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-06 21:27:10
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-06 21:27:10
+ */
+
+package gps
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ssoudan/edisonIsThePilot/pilot"
+)
+
+// isWindSentence tells whether the sentence is a MWV or VWR sentence - which are not supported by go-nmea
+func isWindSentence(sentence string) bool {
+	if len(sentence) < 6 || sentence[0] != '$' {
+		return false
+	}
+	t := sentence[3:6]
+	return t == "MWV" || t == "VWR"
+}
+
+// splitSentence checks the checksum of a sentence (if any) and returns its fields
+func splitSentence(sentence string) ([]string, error) {
+	sentence = strings.TrimPrefix(sentence, "$")
+
+	if i := strings.Index(sentence, "*"); i >= 0 {
+		checksum, err := strconv.ParseUint(sentence[i+1:], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checksum [%s]: %v", sentence[i+1:], err)
+		}
+
+		sentence = sentence[:i]
+		var sum byte
+		for j := 0; j < len(sentence); j++ {
+			sum ^= sentence[j]
+		}
+		if sum != byte(checksum) {
+			return nil, fmt.Errorf("checksum mismatch for [%s]: %02X != %02X", sentence, sum, checksum)
+		}
+	}
+
+	return strings.Split(sentence, ","), nil
+}
+
+// speedInKnots converts a wind speed to knots
+func speedInKnots(value string, unit string) (float64, error) {
+	speed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	switch unit {
+	case "N":
+		return speed, nil
+	case "M":
+		return speed * 3600 / 1852, nil
+	case "K":
+		return speed * 1000 / 1852, nil
+	case "S":
+		return speed * 1609.344 / 1852, nil
+	}
+	return 0, fmt.Errorf("unknown speed unit [%s]", unit)
+}
+
+// parseWind parses a MWV or a VWR sentence into a pilot.WindFeedBackAction.
+//
+// Angles are returned in ]-180:180] - positive when the wind comes from starboard.
+// Only relative (apparent) MWV sentences are considered, theoretical ones are reported as not valid.
+func parseWind(sentence string) (pilot.WindFeedBackAction, error) {
+	fields, err := splitSentence(sentence)
+	if err != nil {
+		return pilot.WindFeedBackAction{}, err
+	}
+
+	if len(fields[0]) != 5 {
+		return pilot.WindFeedBackAction{}, fmt.Errorf("not a wind sentence: [%s]", sentence)
+	}
+
+	switch fields[0][2:] {
+	case "MWV":
+		// MWV,angle,R|T,speed,unit,status
+		if len(fields) < 6 {
+			return pilot.WindFeedBackAction{}, fmt.Errorf("MWV sentence is too short: [%s]", sentence)
+		}
+		angle, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return pilot.WindFeedBackAction{}, fmt.Errorf("invalid MWV angle [%s]: %v", fields[1], err)
+		}
+		if angle > 180 {
+			angle -= 360
+		}
+		speed, err := speedInKnots(fields[3], fields[4])
+		if err != nil {
+			return pilot.WindFeedBackAction{}, fmt.Errorf("invalid MWV speed: %v", err)
+		}
+		return pilot.WindFeedBackAction{
+			Angle:    angle,
+			Speed:    speed,
+			Validity: fields[2] == "R" && fields[5] == "A",
+		}, nil
+
+	case "VWR":
+		// VWR,angle,L|R,speed,N,speed,M,speed,K
+		if len(fields) < 5 {
+			return pilot.WindFeedBackAction{}, fmt.Errorf("VWR sentence is too short: [%s]", sentence)
+		}
+		angle, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return pilot.WindFeedBackAction{}, fmt.Errorf("invalid VWR angle [%s]: %v", fields[1], err)
+		}
+		if fields[2] == "L" {
+			angle = -angle
+		}
+		speed, err := speedInKnots(fields[3], fields[4])
+		if err != nil {
+			return pilot.WindFeedBackAction{}, fmt.Errorf("invalid VWR speed: %v", err)
+		}
+		return pilot.WindFeedBackAction{
+			Angle:    angle,
+			Speed:    speed,
+			Validity: fields[2] == "L" || fields[2] == "R",
+		}, nil
+	}
+
+	return pilot.WindFeedBackAction{}, fmt.Errorf("not a wind sentence: [%s]", sentence)
+}
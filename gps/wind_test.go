@@ -0,0 +1,80 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-09 22:37:05
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-09 22:37:05
+ */
+
+package gps
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type windCase struct {
+	sentence         string
+	expectedAngle    float64
+	expectedSpeed    float64
+	expectedValidity bool
+	expectedError    bool
+	description      string
+}
+
+func TestParseWind(t *testing.T) {
+
+	cases := []windCase{
+		windCase{sentence: "$IIMWV,045.0,R,10.0,N,A*0D", expectedAngle: 45., expectedSpeed: 10., expectedValidity: true, description: "MWV relative"},
+		windCase{sentence: "$IIMWV,270.0,R,5.0,M,A*3E", expectedAngle: -90., expectedSpeed: 5. * 3600 / 1852, expectedValidity: true, description: "MWV relative from port in m/s"},
+		windCase{sentence: "$IIMWV,090.0,T,18.52,K,A*39", expectedAngle: 90., expectedSpeed: 10., expectedValidity: false, description: "MWV true in km/h"},
+		windCase{sentence: "$IIMWV,045.0,R,10.0,N,V*1A", expectedAngle: 45., expectedSpeed: 10., expectedValidity: false, description: "MWV with status V"},
+		windCase{sentence: "$IIVWR,030.0,L,10.0,N,5.1,M,18.5,K*5D", expectedAngle: -30., expectedSpeed: 10., expectedValidity: true, description: "VWR from port"},
+		windCase{sentence: "$IIVWR,030.0,R,10.0,N,5.1,M,18.5,K*43", expectedAngle: 30., expectedSpeed: 10., expectedValidity: true, description: "VWR from starboard"},
+		windCase{sentence: "$IIMWV,045.0,R,10.0,N,A*0E", expectedError: true, description: "checksum mismatch"},
+		windCase{sentence: "$IIMWV,045.0,R,10.0,X,A*1B", expectedError: true, description: "unknown speed unit"},
+		windCase{sentence: "$IIMWV,045.0,R*31", expectedError: true, description: "MWV too short"},
+	}
+
+	for _, c := range cases {
+		checkWindCase(t, c)
+	}
+}
+
+func checkWindCase(t *testing.T, c windCase) {
+
+	wind, err := parseWind(c.sentence)
+	if c.expectedError {
+		assert.NotNil(t, err, fmt.Sprintf("\"%s\" [error] case failed", c.description))
+		return
+	}
+
+	assert.Nil(t, err, fmt.Sprintf("\"%s\" [error] case failed", c.description))
+	assert.InDelta(t, c.expectedAngle, wind.Angle, 1e-9, fmt.Sprintf("\"%s\" [angle] case failed", c.description))
+	assert.InDelta(t, c.expectedSpeed, wind.Speed, 1e-9, fmt.Sprintf("\"%s\" [speed] case failed", c.description))
+	assert.EqualValues(t, c.expectedValidity, wind.Validity, fmt.Sprintf("\"%s\" [validity] case failed", c.description))
+}
+
+func TestIsWindSentence(t *testing.T) {
+	assert.True(t, isWindSentence("$IIMWV,045.0,R,10.0,N,A*0D"))
+	assert.True(t, isWindSentence("$IIVWR,030.0,L,10.0,N,5.1,M,18.5,K*5D"))
+	assert.False(t, isWindSentence("$GPRMC,220516,A,5133.82,N,00042.24,W,173.8,231.8,130694,004.2,W*70"))
+	assert.False(t, isWindSentence("MWV"))
+}
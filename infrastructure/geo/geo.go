@@ -0,0 +1,257 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-08 16:09:31
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-08 16:09:31
+ */
+
+// Package geo provides great-circle and rhumb-line computations on positions.
+//
+// Latitudes, longitudes and bearings are in degree, distances are in meters.
+package geo
+
+import (
+	"errors"
+	"math"
+
+	"github.com/adrianmo/go-nmea"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+const (
+	// EarthRadius is the mean radius of the earth (in meters)
+	EarthRadius = 6371008.8
+	// MetersPerNauticalMile is the length of a nautical mile (in meters)
+	MetersPerNauticalMile = 1852.
+
+	// WGS84 ellipsoid
+	wgs84SemiMajorAxis = 6378137.
+	wgs84Flattening    = 1 / 298.257223563
+)
+
+// ErrNoConvergence is returned when Vincenty's formulae fail to converge (nearly antipodal points)
+var ErrNoConvergence = errors.New("Vincenty's formulae failed to converge")
+
+// PointFromLatLong creates a types.Point from the latitude and longitude of a NMEA sentence
+func PointFromLatLong(latitude, longitude nmea.LatLong) types.Point {
+	return types.Point{Latitude: float64(latitude), Longitude: float64(longitude)}
+}
+
+func toRadians(degree float64) float64 {
+	return degree * math.Pi / 180
+}
+
+func toDegrees(radian float64) float64 {
+	return radian * 180 / math.Pi
+}
+
+// normalizeBearing brings a bearing in [0:360[
+func normalizeBearing(bearing float64) float64 {
+	bearing = math.Mod(bearing, 360)
+	if bearing < 0 {
+		bearing += 360
+	}
+	return bearing
+}
+
+// normalizeLongitude brings a longitude in [-180:180[
+func normalizeLongitude(longitude float64) float64 {
+	return normalizeBearing(longitude+180) - 180
+}
+
+// angularDistance is the great-circle angle (in radian) between two positions
+func angularDistance(from, to types.Point) float64 {
+	phi1, phi2 := toRadians(from.Latitude), toRadians(to.Latitude)
+	dPhi := phi2 - phi1
+	dLambda := toRadians(to.Longitude - from.Longitude)
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Distance is the great-circle distance between two positions (haversine formula on a spherical earth)
+func Distance(from, to types.Point) float64 {
+	return EarthRadius * angularDistance(from, to)
+}
+
+// InitialBearing is the bearing to follow from the first position on the great-circle path to the second one
+func InitialBearing(from, to types.Point) float64 {
+	phi1, phi2 := toRadians(from.Latitude), toRadians(to.Latitude)
+	dLambda := toRadians(to.Longitude - from.Longitude)
+
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return normalizeBearing(toDegrees(math.Atan2(y, x)))
+}
+
+// FinalBearing is the bearing on arrival at the second position on the great-circle path from the first one
+func FinalBearing(from, to types.Point) float64 {
+	return normalizeBearing(InitialBearing(to, from) + 180)
+}
+
+// Destination is the position reached from a position after travelling a distance on the great circle
+// starting with the given bearing
+func Destination(from types.Point, bearing, distance float64) types.Point {
+	delta := distance / EarthRadius
+	theta := toRadians(bearing)
+	phi1, lambda1 := toRadians(from.Latitude), toRadians(from.Longitude)
+
+	sinPhi2 := math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta)
+	phi2 := math.Asin(sinPhi2)
+	y := math.Sin(theta) * math.Sin(delta) * math.Cos(phi1)
+	x := math.Cos(delta) - math.Sin(phi1)*sinPhi2
+	lambda2 := lambda1 + math.Atan2(y, x)
+
+	return types.Point{Latitude: toDegrees(phi2), Longitude: normalizeLongitude(toDegrees(lambda2))}
+}
+
+// CrossTrackDistance is the distance of a position to the great-circle path going from start to end.
+// It is negative when the position is on the left (port side) of the path.
+func CrossTrackDistance(position, start, end types.Point) float64 {
+	delta13 := angularDistance(start, position)
+	theta13 := toRadians(InitialBearing(start, position))
+	theta12 := toRadians(InitialBearing(start, end))
+
+	return EarthRadius * math.Asin(math.Sin(delta13)*math.Sin(theta13-theta12))
+}
+
+// AlongTrackDistance is the distance from start to the point of the great-circle path going from start to end
+// that is the closest to the position. It is negative when this point is behind start.
+func AlongTrackDistance(position, start, end types.Point) float64 {
+	delta13 := angularDistance(start, position)
+	theta13 := toRadians(InitialBearing(start, position))
+	theta12 := toRadians(InitialBearing(start, end))
+	deltaXt := math.Asin(math.Sin(delta13) * math.Sin(theta13-theta12))
+
+	deltaAt := math.Acos(math.Max(-1, math.Min(1, math.Cos(delta13)/math.Cos(deltaXt))))
+	if math.Cos(theta12-theta13) < 0 {
+		deltaAt = -deltaAt
+	}
+	return EarthRadius * deltaAt
+}
+
+// VincentyDistance is the distance between two positions on the WGS84 ellipsoid (Vincenty's inverse formula).
+// It also returns the initial and final bearings.
+func VincentyDistance(from, to types.Point) (distance, initialBearing, finalBearing float64, err error) {
+	a := wgs84SemiMajorAxis
+	f := wgs84Flattening
+	b := a * (1 - f)
+
+	L := toRadians(to.Longitude - from.Longitude)
+	U1 := math.Atan((1 - f) * math.Tan(toRadians(from.Latitude)))
+	U2 := math.Atan((1 - f) * math.Tan(toRadians(to.Latitude)))
+	sinU1, cosU1 := math.Sin(U1), math.Cos(U1)
+	sinU2, cosU2 := math.Sin(U2), math.Cos(U2)
+
+	lambda := L
+	var sinLambda, cosLambda, sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	for i := 0; ; i++ {
+		if i == 200 {
+			return 0, 0, 0, ErrNoConvergence
+		}
+
+		sinLambda, cosLambda = math.Sin(lambda), math.Cos(lambda)
+		sinSigma = math.Sqrt((cosU2*sinLambda)*(cosU2*sinLambda) + (cosU1*sinU2-sinU1*cosU2*cosLambda)*(cosU1*sinU2-sinU1*cosU2*cosLambda))
+		if sinSigma == 0 {
+			return 0, 0, 0, nil // coincident points
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cosSqAlpha != 0 { // on the equatorial line
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+		C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+		previous := lambda
+		lambda = L + (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-previous) <= 1e-12 {
+			break
+		}
+	}
+
+	uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	dSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+
+	distance = b * A * (sigma - dSigma)
+	initialBearing = normalizeBearing(toDegrees(math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)))
+	finalBearing = normalizeBearing(toDegrees(math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda)))
+	return distance, initialBearing, finalBearing, nil
+}
+
+// rhumbStretch is the ratio between the latitude difference and the projected latitude difference
+// of a rhumb line (the cosine of the latitude on an E-W line)
+func rhumbStretch(phi1, phi2 float64) float64 {
+	dPsi := math.Log(math.Tan(phi2/2+math.Pi/4) / math.Tan(phi1/2+math.Pi/4))
+	if math.Abs(dPsi) > 10e-12 {
+		return (phi2 - phi1) / dPsi
+	}
+	return math.Cos(phi1)
+}
+
+// shortestLongitudeDifference is the longitude difference (in radian) between two positions, going the short way
+func shortestLongitudeDifference(from, to types.Point) float64 {
+	return toRadians(normalizeLongitude(to.Longitude - from.Longitude))
+}
+
+// RhumbDistance is the distance along the rhumb line (constant bearing) between two positions
+func RhumbDistance(from, to types.Point) float64 {
+	phi1, phi2 := toRadians(from.Latitude), toRadians(to.Latitude)
+	dLambda := shortestLongitudeDifference(from, to)
+	q := rhumbStretch(phi1, phi2)
+
+	return EarthRadius * math.Sqrt((phi2-phi1)*(phi2-phi1)+q*q*dLambda*dLambda)
+}
+
+// RhumbBearing is the constant bearing of the rhumb line between two positions
+func RhumbBearing(from, to types.Point) float64 {
+	phi1, phi2 := toRadians(from.Latitude), toRadians(to.Latitude)
+	dLambda := shortestLongitudeDifference(from, to)
+	dPsi := math.Log(math.Tan(phi2/2+math.Pi/4) / math.Tan(phi1/2+math.Pi/4))
+
+	return normalizeBearing(toDegrees(math.Atan2(dLambda, dPsi)))
+}
+
+// RhumbDestination is the position reached from a position after travelling a distance on the rhumb line
+// with the given bearing
+func RhumbDestination(from types.Point, bearing, distance float64) types.Point {
+	delta := distance / EarthRadius
+	theta := toRadians(bearing)
+	phi1, lambda1 := toRadians(from.Latitude), toRadians(from.Longitude)
+
+	dPhi := delta * math.Cos(theta)
+	phi2 := phi1 + dPhi
+	// check for going past the pole
+	if math.Abs(phi2) > math.Pi/2 {
+		if phi2 > 0 {
+			phi2 = math.Pi - phi2
+		} else {
+			phi2 = -math.Pi - phi2
+		}
+	}
+
+	q := rhumbStretch(phi1, phi2)
+	lambda2 := lambda1 + delta*math.Sin(theta)/q
+
+	return types.Point{Latitude: toDegrees(phi2), Longitude: normalizeLongitude(toDegrees(lambda2))}
+}
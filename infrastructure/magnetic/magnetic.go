@@ -0,0 +1,226 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-07 18:52:44
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-07 18:52:44
+ */
+
+// Package magnetic provides the magnetic declination from the World Magnetic Model
+// and the conversions between true and magnetic headings.
+package magnetic
+
+import (
+	"math"
+	"time"
+)
+
+// WGS84 ellipsoid and geomagnetic reference radius (in km)
+const (
+	semiMajorAxis   = 6378.137
+	semiMinorAxis   = 6356.7523142
+	referenceRadius = 6371.2
+)
+
+const size = wmmMaxOrder + 1
+
+// model is the WMM in the form used by the synthesis: Schmidt normalized coefficients
+// c[m][n] = g(n,m) and c[n][m-1] = h(n,m) (and the same for the secular variation in cd)
+type model struct {
+	c, cd  [size][size]float64
+	k      [size][size]float64
+	fn, fm [size]float64
+}
+
+var wmm = newModel(wmm2015)
+
+func newModel(coefficients []wmmCoefficient) *model {
+	md := &model{}
+
+	for _, c := range coefficients {
+		md.c[c.m][c.n] = c.g
+		md.cd[c.m][c.n] = c.gDot
+		if c.m != 0 {
+			md.c[c.n][c.m-1] = c.h
+			md.cd[c.n][c.m-1] = c.hDot
+		}
+	}
+
+	// convert Schmidt normalized Gauss coefficients to unnormalized
+	var snorm [size][size]float64 // snorm[m][n]
+	snorm[0][0] = 1
+	for n := 1; n <= wmmMaxOrder; n++ {
+		snorm[0][n] = snorm[0][n-1] * float64(2*n-1) / float64(n)
+		j := 2.
+		for m := 0; m <= n; m++ {
+			md.k[m][n] = float64((n-1)*(n-1)-m*m) / float64((2*n-1)*(2*n-3))
+			if m > 0 {
+				flnmj := float64(n-m+1) * j / float64(n+m)
+				snorm[m][n] = snorm[m-1][n] * math.Sqrt(flnmj)
+				j = 1
+				md.c[n][m-1] *= snorm[m][n]
+				md.cd[n][m-1] *= snorm[m][n]
+			}
+			md.c[m][n] *= snorm[m][n]
+			md.cd[m][n] *= snorm[m][n]
+		}
+		md.fn[n] = float64(n + 1)
+		md.fm[n] = float64(n)
+	}
+	md.k[1][1] = 0
+
+	return md
+}
+
+// decimalYear converts a date to a decimal year
+func decimalYear(date time.Time) float64 {
+	date = date.UTC()
+	start := time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1, 0, 0)
+	return float64(date.Year()) + date.Sub(start).Seconds()/end.Sub(start).Seconds()
+}
+
+// field computes the north, east and down components (in nT) of the main field at a geodetic position
+// (latitude and longitude in degree, altitude in km above the ellipsoid) for a decimal year
+func (md *model) field(latitude, longitude, altitude, year float64) (x, y, z float64) {
+	dt := year - wmmEpoch
+
+	rlat := latitude * math.Pi / 180
+	rlon := longitude * math.Pi / 180
+	srlat, crlat := math.Sin(rlat), math.Cos(rlat)
+	srlat2, crlat2 := srlat*srlat, crlat*crlat
+
+	a2 := semiMajorAxis * semiMajorAxis
+	b2 := semiMinorAxis * semiMinorAxis
+	c2 := a2 - b2
+	a4 := a2 * a2
+	c4 := a4 - b2*b2
+
+	// convert from geodetic to spherical coordinates
+	q := math.Sqrt(a2 - c2*srlat2)
+	q1 := altitude * q
+	q2 := ((q1 + a2) / (q1 + b2)) * ((q1 + a2) / (q1 + b2))
+	ct := srlat / math.Sqrt(q2*crlat2+srlat2)
+	st := math.Sqrt(1 - ct*ct)
+	r2 := altitude*altitude + 2*q1 + (a4-c4*srlat2)/(q*q)
+	r := math.Sqrt(r2)
+	d := math.Sqrt(a2*crlat2 + b2*srlat2)
+	ca := (altitude + d) / r
+	sa := c2 * crlat * srlat / (r * d)
+
+	var sp, cp [size]float64
+	sp[1], cp[0], cp[1] = math.Sin(rlon), 1, math.Cos(rlon)
+	for m := 2; m <= wmmMaxOrder; m++ {
+		sp[m] = sp[1]*cp[m-1] + cp[1]*sp[m-1]
+		cp[m] = cp[1]*cp[m-1] - sp[1]*sp[m-1]
+	}
+
+	var p, dp [size][size]float64 // p[m][n] and its derivative
+	var pp [size]float64
+	p[0][0], pp[0] = 1, 1
+
+	aor := referenceRadius / r
+	ar := aor * aor
+	var br, bt, bp, bpp float64
+
+	for n := 1; n <= wmmMaxOrder; n++ {
+		ar *= aor
+		for m := 0; m <= n; m++ {
+			// unnormalized associated Legendre polynomials and derivatives via recursion relations
+			switch {
+			case n == m:
+				p[m][n] = st * p[m-1][n-1]
+				dp[m][n] = st*dp[m-1][n-1] + ct*p[m-1][n-1]
+			case n == 1 && m == 0:
+				p[m][n] = ct * p[m][n-1]
+				dp[m][n] = ct*dp[m][n-1] - st*p[m][n-1]
+			default:
+				if m > n-2 {
+					p[m][n-2] = 0
+					dp[m][n-2] = 0
+				}
+				p[m][n] = ct*p[m][n-1] - md.k[m][n]*p[m][n-2]
+				dp[m][n] = ct*dp[m][n-1] - st*p[m][n-1] - md.k[m][n]*dp[m][n-2]
+			}
+
+			// time adjusted coefficients
+			g := md.c[m][n] + dt*md.cd[m][n]
+			h := 0.
+			if m != 0 {
+				h = md.c[n][m-1] + dt*md.cd[n][m-1]
+			}
+
+			// accumulate terms of the spherical harmonic expansions
+			par := ar * p[m][n]
+			temp1 := g*cp[m] + h*sp[m]
+			temp2 := g*sp[m] - h*cp[m]
+			bt -= ar * temp1 * dp[m][n]
+			bp += md.fm[m] * temp2 * par
+			br += md.fn[n] * temp1 * par
+
+			// special case: north/south geographic poles
+			if st == 0 && m == 1 {
+				if n == 1 {
+					pp[n] = pp[n-1]
+				} else {
+					pp[n] = ct*pp[n-1] - md.k[m][n]*pp[n-2]
+				}
+				bpp += md.fm[m] * temp2 * ar * pp[n]
+			}
+		}
+	}
+
+	if st == 0 {
+		bp = bpp
+	} else {
+		bp /= st
+	}
+
+	// rotate the magnetic vector components from spherical to geodetic coordinates
+	x = -bt*ca - br*sa
+	y = bp
+	z = bt*sa - br*ca
+	return
+}
+
+// Declination returns the magnetic declination (in degree, positive when the magnetic north is east of the true north)
+// at sea level for a position (in degree) and a date.
+// The model (WMM2015) is valid from 2015 to 2020.
+func Declination(latitude, longitude float64, date time.Time) float64 {
+	x, y, _ := wmm.field(latitude, longitude, 0, decimalYear(date))
+	return math.Atan2(y, x) * 180 / math.Pi
+}
+
+// normalize brings a heading in [0:360[
+func normalize(heading float64) float64 {
+	heading = math.Mod(heading, 360)
+	if heading < 0 {
+		heading += 360
+	}
+	return heading
+}
+
+// ToMagnetic converts a true heading to a magnetic heading given the declination (in degree)
+func ToMagnetic(trueHeading, declination float64) float64 {
+	return normalize(trueHeading - declination)
+}
+
+// ToTrue converts a magnetic heading to a true heading given the declination (in degree)
+func ToTrue(magneticHeading, declination float64) float64 {
+	return normalize(magneticHeading + declination)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-07 18:52:44
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-07 18:52:44
+ */
+
+package magnetic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test values from the WMM2015 report
+func TestDeclination(t *testing.T) {
+	epoch := time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)
+	assert.InDelta(t, -3.85, Declination(80, 0, epoch), 0.01)
+	assert.InDelta(t, 0.57, Declination(0, 120, epoch), 0.01)
+	assert.InDelta(t, 69.81, Declination(-80, 240, epoch), 0.01)
+
+	midLife := time.Date(2017, time.July, 2, 12, 0, 0, 0, time.UTC)
+	assert.InDelta(t, -2.75, Declination(80, 0, midLife), 0.01)
+	assert.InDelta(t, 0.32, Declination(0, 120, midLife), 0.01)
+	assert.InDelta(t, 69.58, Declination(-80, 240, midLife), 0.01)
+}
+
+func TestDecimalYear(t *testing.T) {
+	assert.EqualValues(t, 2015., decimalYear(time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)))
+	assert.InDelta(t, 2016.5, decimalYear(time.Date(2016, time.July, 2, 0, 0, 0, 0, time.UTC)), 1e-9)
+}
+
+func TestConversions(t *testing.T) {
+	assert.EqualValues(t, 355., ToMagnetic(5, 10), "east declination")
+	assert.EqualValues(t, 15., ToMagnetic(5, -10), "west declination")
+	assert.EqualValues(t, 5., ToTrue(355, 10), "east declination")
+	assert.EqualValues(t, 5., ToTrue(15, -10), "west declination")
+
+	for _, heading := range []float64{0, 1.5, 90, 180, 359.5} {
+		assert.InDelta(t, heading, ToTrue(ToMagnetic(heading, -3.85), -3.85), 1e-9, "round trip")
+	}
+}
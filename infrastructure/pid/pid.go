@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-24 21:35:33
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 12:15:26
+* @Last Modified time: 2015-11-15 19:42:57
  */
 
 package pid
@@ -42,6 +42,11 @@ type PID struct {
 
 	minOutput float64
 	maxOutput float64
+
+	// contributions of the terms to the last output
+	proportional float64
+	integral     float64
+	derivative   float64
 }
 
 // New creates a new PID with specific parameters
@@ -76,6 +81,7 @@ func (p *PID) updateWithDuration(input float64, timeDifference float64) float64
 	// output computation
 	filterCoefficient := (p.kd*u - p.filterState) * p.n
 	output := (p.kp*u + p.integratorState) + filterCoefficient
+	p.proportional, p.integral, p.derivative = p.kp*u, p.integratorState, filterCoefficient
 
 	if timeDifference > 0 {
 		p.integratorState += p.ki * u * timeDifference
@@ -94,7 +100,40 @@ func (p *PID) updateWithDuration(input float64, timeDifference float64) float64
 	return output
 }
 
+// Contributions returns the contributions of the proportional, integral and derivative terms to the last output
+// - before saturation
+func (p PID) Contributions() (proportional, integral, derivative float64) {
+	return p.proportional, p.integral, p.derivative
+}
+
+// Gains returns the proportional, integral and derivative coefficients
+func (p PID) Gains() (kp, ki, kd float64) {
+	return p.kp, p.ki, p.kd
+}
+
+// SetGains changes the proportional, integral and derivative coefficients - the state of the integrator and
+// the derivative filter is kept so the output does not jump
+func (p *PID) SetGains(kp, ki, kd float64) {
+	p.kp, p.ki, p.kd = kp, ki, kd
+}
+
 // OutputLimits returns the correction limits
 func (p PID) OutputLimits() (float64, float64) {
 	return p.minOutput, p.maxOutput
 }
+
+// SetOutputLimits changes the correction limits
+func (p *PID) SetOutputLimits(minOutput, maxOutput float64) {
+	p.minOutput = minOutput
+	p.maxOutput = maxOutput
+}
+
+// DerivativeFilter returns the derivative filter coefficient
+func (p PID) DerivativeFilter() float64 {
+	return p.n
+}
+
+// SetDerivativeFilter changes the derivative filter coefficient
+func (p *PID) SetDerivativeFilter(n float64) {
+	p.n = n
+}
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-25 16:06:30
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-09-30 14:34:47
+* @Last Modified time: 2015-11-15 19:42:57
  */
 
 package pid
@@ -323,3 +323,56 @@ func TestThatPIDOutputDoNotExceedMinOutput(t *testing.T) {
 	}
 
 }
+
+func TestThatOutputLimitsCanBeChanged(t *testing.T) {
+
+	pidController := New(1, 1, 1, 1, -10, 10)
+
+	pidController.SetOutputLimits(-5, 5)
+
+	min, max := pidController.OutputLimits()
+	assert.EqualValues(t, -5, min)
+	assert.EqualValues(t, 5, max)
+
+	pidController.Set(100)
+
+	for i := 0; i < 10; i++ {
+		output := pidController.updateWithDuration(0, 1.)
+		assert.True(t, output <= 5, "output should never be larger than the new maxOutput")
+	}
+}
+
+func TestThatDerivativeFilterCanBeChanged(t *testing.T) {
+
+	pidController := New(1, 1, 1, 2, -10, 10)
+
+	assert.EqualValues(t, 2, pidController.DerivativeFilter())
+
+	pidController.SetDerivativeFilter(3)
+
+	assert.EqualValues(t, 3, pidController.DerivativeFilter())
+}
+
+func TestThatContributionsAddUpToTheOutput(t *testing.T) {
+
+	pidController := New(2, 0.5, 1, 2, -100, 100)
+
+	pidController.Set(0)
+
+	for i := 0; i < 5; i++ {
+		output := pidController.updateWithDuration(float64(i), 1.)
+		p, in, d := pidController.Contributions()
+		assert.InDelta(t, output, p+in+d, 1e-9, "contributions add up to the output when not saturated")
+		assert.EqualValues(t, -2*float64(i), p, "proportional contribution")
+	}
+}
+
+func TestThatGainsCanBeChanged(t *testing.T) {
+	pid := New(1, 2, 3, 4, -10, 10)
+
+	kp, ki, kd := pid.Gains()
+	assert.EqualValues(t, []float64{1, 2, 3}, []float64{kp, ki, kd})
+
+	pid.SetGains(0.5, 0, 0)
+	assert.EqualValues(t, -0.5, pid.updateWithDuration(1, 0), "only the new proportional gain is applied")
+}
@@ -18,16 +18,21 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-10-19 15:35:41
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 16:47:46
+* @Last Modified time: 2015-12-20 17:52:08
  */
 
 package types
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 )
 
+// ErrStalled is the error of a motor which does not turn - returned by the actuators and reported by the steering
+var ErrStalled = errors.New("motor stalled")
+
 // Enablable is something that can be Enable() or Disable()
 type Enablable interface {
 	Enable() error
@@ -48,6 +53,16 @@ func (t JSONTime) MarshalJSON() ([]byte, error) {
 	return []byte(stamp), nil
 }
 
+// UnmarshalJSON does the JSON deserialization for JSONTime
+func (t *JSONTime) UnmarshalJSON(data []byte) error {
+	stamp, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return err
+	}
+	*t = JSONTime(time.Unix(stamp, 0))
+	return nil
+}
+
 // JSONDuration is an alias for time.Duration with a custom serialization
 type JSONDuration time.Duration
 
@@ -57,9 +72,47 @@ func (d JSONDuration) MarshalJSON() ([]byte, error) {
 	return []byte(stamp), nil
 }
 
-// Point is a position on earth at a specified time instant
+// Point is a position on earth at a specified time instant with the state of the pilot
 type Point struct {
 	Latitude  float64  `json:"latitude"`
 	Longitude float64  `json:"longitude"`
 	Time      JSONTime `json:"time"`
+
+	Course          float64         `json:"course"`          // in degree
+	Speed           float64         `json:"speed"`           // in knots
+	SetPoint        float64         `json:"setPoint"`        // in degree
+	HeadingError    float64         `json:"headingError"`    // in degree
+	PIDOutput       float64         `json:"pidOutput"`       // in degree
+	Proportional    float64         `json:"proportional"`    // contribution of the proportional term to PIDOutput
+	Integral        float64         `json:"integral"`        // contribution of the integral term to PIDOutput
+	Derivative      float64         `json:"derivative"`      // contribution of the derivative term to PIDOutput
+	Steering        float64         `json:"steering"`        // steering command sent (in degree)
+	SteeringEnabled bool            `json:"steeringEnabled"` // steering state sent
+	Alarm           bool            `json:"alarm"`           // alarm state
+	Leds            map[string]bool `json:"leds,omitempty"`  // dashboard state
+}
+
+// Types of the Event published by the components
+const (
+	AutopilotEvent = "autopilot"
+	DashboardEvent = "dashboard"
+	AlarmEvent     = "alarm"
+	PointEvent     = "point"
+)
+
+// Event is a change of state pushed to the live clients of the webserver
+type Event struct {
+	Type string
+	Data interface{}
+}
+
+// Publish sends an Event without ever blocking: when the channel is full the Event is dropped
+// and false is returned. A slow client must never slow down the pilot.
+func Publish(c chan interface{}, event Event) bool {
+	select {
+	case c <- event:
+		return true
+	default:
+		return false
+	}
 }
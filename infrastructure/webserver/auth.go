@@ -0,0 +1,341 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-14 17:26:03
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-14 17:26:03
+ */
+
+package webserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
+)
+
+var audit = logger.Log("audit")
+
+// Role is what a client is allowed to do with the API
+type Role int
+
+// Roles - each one includes the previous ones
+const (
+	NoRole Role = iota
+	ReadOnlyRole
+	ControlRole
+)
+
+func (r Role) String() string {
+	switch r {
+	case ReadOnlyRole:
+		return "read-only"
+	case ControlRole:
+		return "control"
+	}
+	return "none"
+}
+
+const (
+	// MaxFailedLogins is the number of consecutive wrong PINs after which the logins of a client are refused for LoginLockout
+	MaxFailedLogins = 5
+	// LoginLockout is the duration during which the logins of a client are refused after too many wrong PINs - it is also
+	// how long the wrong PINs of a client are remembered
+	LoginLockout = time.Minute
+)
+
+// ErrInvalidPIN is returned when logging in with a wrong PIN
+var ErrInvalidPIN = errors.New("invalid PIN")
+
+// ErrLockedOut is returned when logging in after too many wrong PINs
+var ErrLockedOut = errors.New("too many invalid PINs - try again later")
+
+type session struct {
+	role    Role
+	expires time.Time
+}
+
+// loginAttempts are the recent wrong PINs of a client
+type loginAttempts struct {
+	failures    int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// Authenticator grants a Role to the requests from their API token or the token of the session opened with a PIN.
+// A Role without any credential configured is granted to everybody - the control role includes the read-only one.
+type Authenticator struct {
+	tokens         map[Role][]string
+	pins           map[Role]string
+	sessionTimeout time.Duration
+	now            func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]session        // protected by mu
+	attempts map[string]*loginAttempts // by client host - protected by mu
+}
+
+// NewAuthenticator creates an Authenticator for the given static API tokens and PINs - empty ones are ignored
+func NewAuthenticator(controlTokens, readOnlyTokens []string, controlPIN, readOnlyPIN string, sessionTimeout time.Duration) *Authenticator {
+	a := &Authenticator{
+		tokens:         map[Role][]string{},
+		pins:           map[Role]string{},
+		sessionTimeout: sessionTimeout,
+		now:            time.Now,
+		sessions:       make(map[string]session),
+		attempts:       make(map[string]*loginAttempts),
+	}
+
+	for role, tokens := range map[Role][]string{ControlRole: controlTokens, ReadOnlyRole: readOnlyTokens} {
+		for _, token := range tokens {
+			if token = strings.TrimSpace(token); token != "" {
+				a.tokens[role] = append(a.tokens[role], token)
+			}
+		}
+	}
+	for role, pin := range map[Role]string{ControlRole: controlPIN, ReadOnlyRole: readOnlyPIN} {
+		if pin != "" {
+			a.pins[role] = pin
+		}
+	}
+
+	if !a.protects(ControlRole) {
+		log.Warning("No credential configured for the control role: anybody on the network can steer the boat")
+	}
+	return a
+}
+
+// protects is true when some credential is configured for the role
+func (a *Authenticator) protects(role Role) bool {
+	return len(a.tokens[role]) != 0 || a.pins[role] != ""
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// forgetAttempts removes the clients which are not locked out and have not entered a wrong PIN for LoginLockout
+func (a *Authenticator) forgetAttempts(now time.Time) {
+	for client, attempts := range a.attempts {
+		if !now.Before(attempts.lockedUntil) && now.Sub(attempts.last) >= LoginLockout {
+			delete(a.attempts, client)
+		}
+	}
+}
+
+// Login opens a session for the PIN entered by a client and returns its token, its role and when it expires.
+// Each client is locked out on its own: the wrong PINs of somebody else never prevent a client from logging in.
+func (a *Authenticator) Login(client, pin string) (string, Role, time.Time, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	a.forgetAttempts(now)
+
+	attempts, ok := a.attempts[client]
+	if ok && now.Before(attempts.lockedUntil) {
+		return "", NoRole, time.Time{}, ErrLockedOut
+	}
+
+	role := NoRole
+	for _, r := range []Role{ControlRole, ReadOnlyRole} {
+		if p, ok := a.pins[r]; ok && equal(p, pin) {
+			role = r
+			break
+		}
+	}
+
+	if role == NoRole {
+		if !ok {
+			attempts = &loginAttempts{}
+			a.attempts[client] = attempts
+		}
+		attempts.failures++
+		attempts.last = now
+		if attempts.failures >= MaxFailedLogins {
+			attempts.failures = 0
+			attempts.lockedUntil = now.Add(LoginLockout)
+		}
+		return "", NoRole, time.Time{}, ErrInvalidPIN
+	}
+	delete(a.attempts, client)
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", NoRole, time.Time{}, err
+	}
+	token := hex.EncodeToString(b)
+
+	expires := now.Add(a.sessionTimeout)
+	a.sessions[token] = session{role: role, expires: expires}
+	return token, role, expires, nil
+}
+
+// Logout closes the session of the token
+func (a *Authenticator) Logout(token string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.sessions, token)
+}
+
+// roleOfToken is the role granted to a token - NoRole if it is unknown or expired
+func (a *Authenticator) roleOfToken(token string) Role {
+	if token == "" {
+		return NoRole
+	}
+
+	for _, role := range []Role{ControlRole, ReadOnlyRole} {
+		for _, t := range a.tokens[role] {
+			if equal(t, token) {
+				return role
+			}
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	for t, s := range a.sessions {
+		if !now.Before(s.expires) {
+			delete(a.sessions, t)
+		}
+	}
+	return a.sessions[token].role
+}
+
+// requestToken is the token of the request: from the Authorization header ("Bearer <token>") or the token query
+// parameter - EventSource can't set headers
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	return r.URL.Query().Get("token")
+}
+
+// clientHost is the host a request comes from
+func clientHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Role is the role granted to the request
+func (a *Authenticator) Role(r *http.Request) Role {
+	role := a.roleOfToken(requestToken(r))
+	for _, unprotected := range []Role{ControlRole, ReadOnlyRole} {
+		if role < unprotected && !a.protects(unprotected) {
+			return unprotected
+		}
+	}
+	return role
+}
+
+// requiredRole is the role needed for a request: reading needs ReadOnlyRole, anything else needs ControlRole
+func requiredRole(method string) Role {
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return ReadOnlyRole
+	}
+	return ControlRole
+}
+
+// authorize checks the role of the request and returns the status code of the response written
+// when it is not granted - http.StatusOK when it is
+func (a *Authenticator) authorize(w http.ResponseWriter, r *http.Request, required Role) int {
+	if a.Role(r) >= required {
+		return http.StatusOK
+	}
+
+	if requestToken(r) == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return http.StatusUnauthorized
+	}
+	http.Error(w, "forbidden", http.StatusForbidden)
+	return http.StatusForbidden
+}
+
+// statusWriter is a rest.ResponseWriter remembering the status code of the response
+type statusWriter struct {
+	rest.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write makes the statusWriter an http.ResponseWriter like the rest.ResponseWriter it wraps
+func (w *statusWriter) Write(b []byte) (int, error) {
+	return w.ResponseWriter.(http.ResponseWriter).Write(b)
+}
+
+// Status is the status code of the response - http.StatusOK when none has been written
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// MiddlewareFunc makes the Authenticator a rest.Middleware checking the role of every request - except
+// the ones on /session - and keeping the audit log of the control requests
+func (a *Authenticator) MiddlewareFunc(h rest.HandlerFunc) rest.HandlerFunc {
+	return func(w rest.ResponseWriter, r *rest.Request) {
+		required := requiredRole(r.Method)
+		if r.URL.Path == "/session" {
+			required = NoRole
+		}
+
+		status := a.authorize(w.(http.ResponseWriter), r.Request, required)
+		if status == http.StatusOK {
+			sw := &statusWriter{ResponseWriter: w}
+			h(sw, r)
+			status = sw.Status()
+		}
+
+		if required == ControlRole || r.URL.Path == "/session" {
+			audit.Notice("%s %s %s role=%v status=%d", r.RemoteAddr, r.Method, r.URL.Path, a.Role(r.Request), status)
+		}
+	}
+}
+
+// HandlerFunc wraps an http.HandlerFunc checking the request has the required role
+func (a *Authenticator) HandlerFunc(required Role, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.authorize(w, r, required) == http.StatusOK {
+			h(w, r)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-14 18:02:47
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-14 18:02:47
+ */
+
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ant0ine/go-json-rest/rest"
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// testWriter is a rest.ResponseWriter recording the response
+type testWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func newTestWriter() testWriter {
+	return testWriter{httptest.NewRecorder()}
+}
+
+func (w testWriter) EncodeJson(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (w testWriter) WriteJson(v interface{}) error {
+	b, err := w.EncodeJson(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(b)
+	return err
+}
+
+func newRequest(method, path, token, body string) *rest.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return &rest.Request{Request: r, PathParams: map[string]string{}, Env: map[string]interface{}{}}
+}
+
+// clock is a fake time.Now
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestAuthenticator(controlTokens, readOnlyTokens []string, controlPIN, readOnlyPIN string) (*Authenticator, *clock) {
+	a := NewAuthenticator(controlTokens, readOnlyTokens, controlPIN, readOnlyPIN, time.Hour)
+	c := &clock{now: time.Date(2015, 11, 14, 18, 0, 0, 0, time.UTC)}
+	a.now = c.Now
+	return a, c
+}
+
+func TestRequestToken(t *testing.T) {
+
+	cases := []tokenCase{
+		tokenCase{header: "", query: "", expectedToken: "", description: "No token"},
+		tokenCase{header: "Bearer abc", query: "", expectedToken: "abc", description: "Header"},
+		tokenCase{header: "Bearer  abc ", query: "", expectedToken: "abc", description: "Header with spaces"},
+		tokenCase{header: "Basic abc", query: "", expectedToken: "", description: "Other scheme"},
+		tokenCase{header: "", query: "abc", expectedToken: "abc", description: "Query parameter"},
+		tokenCase{header: "Bearer abc", query: "def", expectedToken: "abc", description: "Header before query parameter"},
+	}
+
+	for _, c := range cases {
+		checkTokenCase(t, c)
+	}
+}
+
+type tokenCase struct {
+	header        string
+	query         string
+	expectedToken string
+	description   string
+}
+
+func checkTokenCase(t *testing.T, c tokenCase) {
+	r := httptest.NewRequest("GET", "/points?token="+c.query, nil)
+	if c.header != "" {
+		r.Header.Set("Authorization", c.header)
+	}
+
+	assert.Equal(t, c.expectedToken, requestToken(r), fmt.Sprintf("\"%s\" [token] case failed", c.description))
+}
+
+func TestRole(t *testing.T) {
+
+	cases := []roleCase{
+		roleCase{token: "", expectedRole: ControlRole, description: "No credential configured"},
+		roleCase{controlTokens: []string{"ctl"}, token: "", expectedRole: ReadOnlyRole, description: "Read-only unprotected"},
+		roleCase{controlTokens: []string{"ctl"}, token: "ctl", expectedRole: ControlRole, description: "Control token"},
+		roleCase{controlTokens: []string{"ctl"}, token: "bad", expectedRole: ReadOnlyRole, description: "Unknown token, read-only unprotected"},
+		roleCase{controlTokens: []string{"ctl"}, readOnlyTokens: []string{"ro"}, token: "", expectedRole: NoRole, description: "All protected"},
+		roleCase{controlTokens: []string{"ctl"}, readOnlyTokens: []string{"ro"}, token: "ro", expectedRole: ReadOnlyRole, description: "Read-only token"},
+		roleCase{controlTokens: []string{"ctl"}, readOnlyTokens: []string{"ro"}, token: "ctl", expectedRole: ControlRole, description: "Control token, all protected"},
+		roleCase{controlTokens: []string{"ctl"}, readOnlyTokens: []string{"ro"}, token: "bad", expectedRole: NoRole, description: "Unknown token, all protected"},
+		roleCase{controlTokens: []string{" ", ""}, token: "", expectedRole: ControlRole, description: "Blank tokens ignored"},
+		roleCase{controlPIN: "1234", token: "", expectedRole: ReadOnlyRole, description: "Control PIN"},
+		roleCase{readOnlyPIN: "1234", token: "", expectedRole: ControlRole, description: "Read-only PIN only"},
+	}
+
+	for _, c := range cases {
+		checkRoleCase(t, c)
+	}
+}
+
+type roleCase struct {
+	controlTokens  []string
+	readOnlyTokens []string
+	controlPIN     string
+	readOnlyPIN    string
+	token          string
+	expectedRole   Role
+	description    string
+}
+
+func checkRoleCase(t *testing.T, c roleCase) {
+	a, _ := newTestAuthenticator(c.controlTokens, c.readOnlyTokens, c.controlPIN, c.readOnlyPIN)
+
+	role := a.Role(newRequest("GET", "/points", c.token, "").Request)
+
+	assert.Equal(t, c.expectedRole, role, fmt.Sprintf("\"%s\" [role] case failed", c.description))
+}
+
+func TestSession(t *testing.T) {
+	a, clock := newTestAuthenticator(nil, []string{"ro"}, "2468", "1357")
+	ws := &Webserver{auth: a}
+
+	w := newTestWriter()
+	ws.loginEndpoint(w, newRequest("POST", "/session", "", `{"pin": "2468"}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	session := Session{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &session))
+	assert.Equal(t, ControlRole.String(), session.Role)
+	assert.Equal(t, clock.now.Add(time.Hour).Unix(), time.Time(session.Expires).Unix())
+
+	w = newTestWriter()
+	ws.sessionEndpoint(w, newRequest("GET", "/session", session.Token, ""))
+	assert.Contains(t, w.Body.String(), `"role":"control"`)
+
+	// the session expires
+	clock.now = clock.now.Add(time.Hour)
+	assert.Equal(t, NoRole, a.Role(newRequest("GET", "/session", session.Token, "").Request))
+
+	// or is closed by DELETE /session
+	w = newTestWriter()
+	ws.loginEndpoint(w, newRequest("POST", "/session", "", `{"pin": "1357"}`))
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &session))
+	assert.Equal(t, ReadOnlyRole.String(), session.Role)
+	assert.Equal(t, ReadOnlyRole, a.Role(newRequest("GET", "/session", session.Token, "").Request))
+
+	w = newTestWriter()
+	ws.logoutEndpoint(w, newRequest("DELETE", "/session", session.Token, ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, NoRole, a.Role(newRequest("GET", "/session", session.Token, "").Request))
+
+	w = newTestWriter()
+	ws.loginEndpoint(w, newRequest("POST", "/session", "", `{"pin": "0000"}`))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLockout(t *testing.T) {
+	a, clock := newTestAuthenticator(nil, nil, "2468", "")
+
+	for i := 0; i < MaxFailedLogins; i++ {
+		_, _, _, err := a.Login("10.0.0.2", "0000")
+		assert.Equal(t, ErrInvalidPIN, err)
+	}
+
+	// the client is locked out even with the right PIN...
+	_, _, _, err := a.Login("10.0.0.2", "2468")
+	assert.Equal(t, ErrLockedOut, err)
+
+	// ... but not the others
+	_, role, _, err := a.Login("10.0.0.3", "2468")
+	assert.Nil(t, err)
+	assert.Equal(t, ControlRole, role)
+
+	clock.now = clock.now.Add(LoginLockout)
+	_, _, _, err = a.Login("10.0.0.2", "2468")
+	assert.Nil(t, err)
+
+	// the wrong PINs are forgotten after a while
+	for i := 0; i < MaxFailedLogins-1; i++ {
+		a.Login("10.0.0.2", "0000")
+	}
+	clock.now = clock.now.Add(LoginLockout)
+	_, _, _, err = a.Login("10.0.0.2", "0000")
+	assert.Equal(t, ErrInvalidPIN, err)
+	_, _, _, err = a.Login("10.0.0.2", "2468")
+	assert.Nil(t, err)
+	assert.Empty(t, a.attempts)
+}
+
+func TestLoginEndpointLockout(t *testing.T) {
+	a, _ := newTestAuthenticator(nil, nil, "2468", "")
+	ws := &Webserver{auth: a}
+
+	login := func(remoteAddr, pin string) int {
+		w := newTestWriter()
+		r := newRequest("POST", "/session", "", fmt.Sprintf(`{"pin": "%s"}`, pin))
+		r.RemoteAddr = remoteAddr
+		ws.loginEndpoint(w, r)
+		return w.Code
+	}
+
+	for i := 0; i < MaxFailedLogins; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login("10.0.0.2:4321", "0000"))
+	}
+	// another connection from the same host
+	assert.Equal(t, http.StatusTooManyRequests, login("10.0.0.2:4322", "2468"))
+	assert.Equal(t, http.StatusOK, login("10.0.0.3:4321", "2468"))
+}
+
+func TestMiddleware(t *testing.T) {
+
+	cases := []middlewareCase{
+		middlewareCase{method: "GET", path: "/points", token: "", expectedStatus: http.StatusOK, description: "Read-only route"},
+		middlewareCase{method: "PUT", path: "/autopilot", token: "", expectedStatus: http.StatusUnauthorized, description: "Control route without token"},
+		middlewareCase{method: "PUT", path: "/autopilot", token: "bad", expectedStatus: http.StatusForbidden, description: "Control route with an unknown token"},
+		middlewareCase{method: "PUT", path: "/autopilot", token: "ctl", expectedStatus: http.StatusOK, description: "Control route with the control token"},
+		middlewareCase{method: "POST", path: "/session", token: "", expectedStatus: http.StatusOK, description: "Login without token"},
+		middlewareCase{method: "DELETE", path: "/session", token: "bad", expectedStatus: http.StatusOK, description: "Logout with an unknown token"},
+		middlewareCase{method: "PUT", path: "/autopilot", token: "ctl", handlerStatus: http.StatusBadRequest, expectedStatus: http.StatusBadRequest, description: "Failed control request"},
+		middlewareCase{method: "PUT", path: "/config", token: "ctl", handlerStatus: http.StatusInternalServerError, expectedStatus: http.StatusInternalServerError, description: "Failed control request"},
+	}
+
+	for _, c := range cases {
+		checkMiddlewareCase(t, c)
+	}
+}
+
+type middlewareCase struct {
+	method         string
+	path           string
+	token          string
+	handlerStatus  int // 0 when the handler only writes JSON
+	expectedStatus int
+	description    string
+}
+
+func checkMiddlewareCase(t *testing.T, c middlewareCase) {
+	a, _ := newTestAuthenticator([]string{"ctl"}, nil, "", "")
+
+	backend := logging.NewMemoryBackend(8)
+	audit.SetBackend(logging.AddModuleLevel(backend))
+
+	called := false
+	handler := a.MiddlewareFunc(func(w rest.ResponseWriter, r *rest.Request) {
+		called = true
+		if c.handlerStatus != 0 {
+			rest.Error(w, "failed", c.handlerStatus)
+			return
+		}
+		w.WriteJson(map[string]string{"status": "OK"})
+	})
+
+	w := newTestWriter()
+	handler(w, newRequest(c.method, c.path, c.token, ""))
+
+	assert.Equal(t, c.expectedStatus, w.Code, fmt.Sprintf("\"%s\" [status] case failed", c.description))
+	assert.Equal(t, c.expectedStatus == http.StatusOK || c.handlerStatus != 0, called, fmt.Sprintf("\"%s\" [called] case failed", c.description))
+
+	// the read-only requests are not audited
+	if c.method == "GET" {
+		assert.Nil(t, backend.Head(), fmt.Sprintf("\"%s\" [audit] case failed", c.description))
+		return
+	}
+	if assert.NotNil(t, backend.Head(), fmt.Sprintf("\"%s\" [audit] case failed", c.description)) {
+		// the status is the last argument of the audit record
+		args := backend.Head().Record.Args
+		assert.Equal(t, c.expectedStatus, args[len(args)-1], fmt.Sprintf("\"%s\" [audit status] case failed", c.description))
+	}
+}
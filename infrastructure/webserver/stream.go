@@ -0,0 +1,186 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-12 22:17:40
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-12 22:17:40
+ */
+
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+	"github.com/ssoudan/edisonIsThePilot/pilot"
+)
+
+const (
+	// EventBufferSize is the suggested capacity of the channel where the components publish their events
+	EventBufferSize = 64
+	// ClientBufferSize is the number of events a live client can lag behind before being disconnected
+	ClientBufferSize = 32
+	// KeepAlivePeriod is the period of the comments sent to keep idle connections open
+	KeepAlivePeriod = 15 * time.Second
+)
+
+// broker fans the events published by the components out to the live clients.
+// It never blocks on a client: a client whose buffer is full is disconnected - the
+// browser reconnects and gets a fresh snapshot.
+type broker struct {
+	eventChan       chan interface{}
+	subscribeChan   chan chan types.Event
+	unsubscribeChan chan chan types.Event
+	clients         map[chan types.Event]bool
+}
+
+func newBroker(eventChan chan interface{}) *broker {
+	return &broker{
+		eventChan:       eventChan,
+		subscribeChan:   make(chan chan types.Event),
+		unsubscribeChan: make(chan chan types.Event),
+		clients:         make(map[chan types.Event]bool),
+	}
+}
+
+// subscribe registers a new client - the returned channel is closed when the client is dropped
+func (b *broker) subscribe() chan types.Event {
+	c := make(chan types.Event, ClientBufferSize)
+	b.subscribeChan <- c
+	return c
+}
+
+// unsubscribe unregisters a client
+func (b *broker) unsubscribe(c chan types.Event) {
+	b.unsubscribeChan <- c
+}
+
+func (b *broker) drop(c chan types.Event) {
+	if b.clients[c] {
+		delete(b.clients, c)
+		close(c)
+	}
+}
+
+func (b *broker) dispatch(event types.Event) {
+	for c := range b.clients {
+		select {
+		case c <- event:
+		default:
+			log.Warning("Dropping a live client that does not keep up")
+			b.drop(c)
+		}
+	}
+}
+
+func (b *broker) run() {
+	for {
+		select {
+		case c := <-b.subscribeChan:
+			b.clients[c] = true
+		case c := <-b.unsubscribeChan:
+			b.drop(c)
+		case m := <-b.eventChan:
+			if event, ok := m.(types.Event); ok {
+				b.dispatch(event)
+			}
+		}
+	}
+}
+
+// mkEventData converts the data of an Event to its serializable structure
+func mkEventData(event types.Event) interface{} {
+	switch data := event.Data.(type) {
+	case pilot.Info:
+		return mkAutopilot(data)
+	}
+	return event.Data
+}
+
+// writeEvent writes an Event in the Server-Sent Events format
+func writeEvent(w http.ResponseWriter, event types.Event) error {
+	data, err := json.Marshal(mkEventData(event))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// streamEndpoint streams the state changes as Server-Sent Events, starting with a snapshot of the
+// autopilot and the dashboard
+func (ws *Webserver) streamEndpoint(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	if ws.broker == nil || ws.pilot == nil || ws.dashboard == nil {
+		log.Error("WS is not initialized")
+		http.Error(w, "WS is not initialized", http.StatusInternalServerError)
+		return
+	}
+
+	events := ws.broker.subscribe()
+	defer ws.broker.unsubscribe(events)
+
+	var closed <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closed = notifier.CloseNotify()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	snapshot := []types.Event{
+		{Type: types.AutopilotEvent, Data: ws.pilot.GetInfoAction()},
+		{Type: types.DashboardEvent, Data: ws.dashboard.GetDashboardInfoAction()},
+	}
+	for _, event := range snapshot {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(KeepAlivePeriod)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+		flusher.Flush()
+	}
+}
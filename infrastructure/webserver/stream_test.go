@@ -0,0 +1,165 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-12 23:05:18
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-12 23:05:18
+ */
+
+package webserver
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+	"github.com/ssoudan/edisonIsThePilot/pilot"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePilot only implements GetInfoAction - the other methods of pilotable panic
+type fakePilot struct {
+	pilotable
+	info pilot.Info
+}
+
+func (p fakePilot) GetInfoAction() pilot.Info {
+	return p.info
+}
+
+type fakeDashboard map[string]bool
+
+func (d fakeDashboard) GetDashboardInfoAction() map[string]bool {
+	return d
+}
+
+func receive(t *testing.T, c chan types.Event) (types.Event, bool) {
+	select {
+	case event, ok := <-c:
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+	return types.Event{}, false
+}
+
+func TestBrokerSubscribeUnsubscribe(t *testing.T) {
+	eventChan := make(chan interface{})
+	b := newBroker(eventChan)
+	go b.run()
+
+	c := b.subscribe()
+
+	// only the Events are dispatched
+	eventChan <- "not an event"
+	eventChan <- types.Event{Type: types.AlarmEvent, Data: true}
+	event, ok := receive(t, c)
+	assert.True(t, ok)
+	assert.Equal(t, types.Event{Type: types.AlarmEvent, Data: true}, event)
+
+	b.unsubscribe(c)
+	_, ok = receive(t, c)
+	assert.False(t, ok, "the channel of an unsubscribed client is closed")
+
+	// unsubscribing twice is harmless
+	b.unsubscribe(c)
+}
+
+func TestBrokerDropsSlowClients(t *testing.T) {
+	b := newBroker(nil)
+	slow := make(chan types.Event, ClientBufferSize)
+	fast := make(chan types.Event, ClientBufferSize)
+	b.clients[slow] = true
+	b.clients[fast] = true
+
+	for i := 0; i <= ClientBufferSize; i++ {
+		b.dispatch(types.Event{Type: types.PointEvent, Data: i})
+		<-fast
+	}
+
+	assert.False(t, b.clients[slow], "the slow client is dropped")
+	assert.True(t, b.clients[fast], "the fast client is kept")
+
+	// the slow client gets what was buffered and then sees its channel closed
+	for i := 0; i < ClientBufferSize; i++ {
+		event, ok := <-slow
+		assert.True(t, ok)
+		assert.Equal(t, i, event.Data)
+	}
+	_, ok := <-slow
+	assert.False(t, ok)
+}
+
+func TestStreamEndpointNotInitialized(t *testing.T) {
+	ws := &Webserver{}
+
+	w := httptest.NewRecorder()
+	ws.streamEndpoint(w, httptest.NewRequest("GET", "/api/stream", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestStreamEndpoint(t *testing.T) {
+	eventChan := make(chan interface{})
+	ws := &Webserver{
+		pilot:     fakePilot{info: pilot.Info{Enabled: true, SetPoint: 42, Mode: pilot.Compass}},
+		dashboard: fakeDashboard{"noGPSFix": true},
+		broker:    newBroker(eventChan),
+	}
+	go ws.broker.run()
+
+	server := httptest.NewServer(http.HandlerFunc(ws.streamEndpoint))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// the snapshot comes first
+	assert.Equal(t, fmt.Sprintf("event: %s\ndata: %s\n", types.AutopilotEvent,
+		`{"enabled":true,"headingOffset":0,"setPoint":42,"course":0,"speed":0,"response":"","activeResponse":"",`+
+			`"tacking":false,"mode":"compass","windAngle":0,"apparentWind":0,"windSpeed":0}`), readEvent())
+	assert.Equal(t, fmt.Sprintf("event: %s\ndata: %s\n", types.DashboardEvent, `{"noGPSFix":true}`), readEvent())
+
+	// then the events published by the components
+	eventChan <- types.Event{Type: types.AlarmEvent, Data: true}
+	assert.Equal(t, fmt.Sprintf("event: %s\ndata: true\n", types.AlarmEvent), readEvent())
+}
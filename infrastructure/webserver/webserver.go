@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-28 22:13:28
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 16:39:00
+* @Last Modified time: 2015-11-15 19:42:57
  */
 
 package webserver
@@ -26,13 +26,19 @@ package webserver
 import (
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/ant0ine/go-json-rest/rest"
 
+	"github.com/ssoudan/edisonIsThePilot/conf"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
 	"github.com/ssoudan/edisonIsThePilot/pilot"
+	"github.com/ssoudan/edisonIsThePilot/tracer"
 )
 
 var log = logger.Log("webserver")
@@ -53,24 +59,185 @@ func redirect(w http.ResponseWriter, r *http.Request) {
 type Control struct {
 	Enabled       bool    `json:"enabled"`
 	HeadingOffset float64 `json:"headingOffset"`
+	Response      string  `json:"response,omitempty"`
+	Mode          string  `json:"mode,omitempty"`
 }
 
 // Autopilot is the serializable structure used to get the autopilot state
 type Autopilot struct {
-	Enabled       bool    `json:"enabled"`
-	HeadingOffset float64 `json:"headingOffset"`
-	SetPoint      float64 `json:"setPoint"`
-	Course        float64 `json:"course"`
-	Speed         float64 `json:"speed"`
+	Enabled        bool    `json:"enabled"`
+	HeadingOffset  float64 `json:"headingOffset"`
+	SetPoint       float64 `json:"setPoint"`
+	Course         float64 `json:"course"`
+	Speed          float64 `json:"speed"`
+	Response       string  `json:"response"`
+	ActiveResponse string  `json:"activeResponse"`
+	Tacking        bool    `json:"tacking"`
+	Mode           string  `json:"mode"`
+	WindAngle      float64 `json:"windAngle"`
+	ApparentWind   float64 `json:"apparentWind"`
+	WindSpeed      float64 `json:"windSpeed"`
+}
+
+// mkAutopilot converts the pilot Info in an Autopilot
+func mkAutopilot(pi pilot.Info) Autopilot {
+	return Autopilot{
+		Enabled:        pi.Enabled,
+		HeadingOffset:  pi.HeadingOffset,
+		SetPoint:       pi.SetPoint,
+		Course:         pi.Course,
+		Speed:          pi.Speed,
+		Response:       string(pi.Response),
+		ActiveResponse: string(pi.ActiveResponse),
+		Tacking:        pi.Tacking,
+		Mode:           string(pi.Mode),
+		WindAngle:      pi.WindAngle,
+		ApparentWind:   pi.ApparentWind,
+		WindSpeed:      pi.WindSpeed,
+	}
+}
+
+// Heading is the serializable structure used to set the autopilot setpoint
+type Heading struct {
+	Heading float64 `json:"heading"`
+}
+
+// HeadingAdjustment is the serializable structure used to nudge the autopilot setpoint
+type HeadingAdjustment struct {
+	Delta float64 `json:"delta"`
+}
+
+// Tack is the serializable structure used to tack or gybe
+type Tack struct {
+	Side string `json:"side"`
+}
+
+// Config is the serializable structure of the parameters that can be changed without restarting - see conf.LiveKeys
+type Config struct {
+	P                   float64 `json:"p"`
+	I                   float64 `json:"i"`
+	D                   float64 `json:"d"`
+	N                   float64 `json:"n"`
+	MinPIDOutputLimits  float64 `json:"minPIDOutputLimits"`
+	MaxPIDOutputLimits  float64 `json:"maxPIDOutputLimits"`
+	Bounds              float64 `json:"bounds"`
+	MinimumSpeedInKnots float64 `json:"minimumSpeedInKnots"`
+}
+
+// mkConfig extracts the Config from a conf.Configuration
+func mkConfig(c conf.Configuration) Config {
+	return Config{
+		P:                   c.P,
+		I:                   c.I,
+		D:                   c.D,
+		N:                   c.N,
+		MinPIDOutputLimits:  c.MinPIDOutputLimits,
+		MaxPIDOutputLimits:  c.MaxPIDOutputLimits,
+		Bounds:              c.Bounds,
+		MinimumSpeedInKnots: c.MinimumSpeedInKnots,
+	}
+}
+
+// apply returns a copy of the conf.Configuration with the values of the Config
+func (config Config) apply(c conf.Configuration) conf.Configuration {
+	c.P = config.P
+	c.I = config.I
+	c.D = config.D
+	c.N = config.N
+	c.MinPIDOutputLimits = config.MinPIDOutputLimits
+	c.MaxPIDOutputLimits = config.MaxPIDOutputLimits
+	c.Bounds = config.Bounds
+	c.MinimumSpeedInKnots = config.MinimumSpeedInKnots
+	return c
+}
+
+// Login is the serializable structure used to open a session
+type Login struct {
+	PIN string `json:"pin"`
+}
+
+// Session is the serializable structure describing a session
+type Session struct {
+	Token   string         `json:"token,omitempty"`
+	Role    string         `json:"role"`
+	Expires types.JSONTime `json:"expires,omitempty"`
+}
+
+// TimeSeries is the serializable structure of the trace used to plot the behavior of the pilot
+type TimeSeries struct {
+	Time            []types.JSONTime  `json:"time"`
+	Course          []float64         `json:"course"`
+	Speed           []float64         `json:"speed"`
+	SetPoint        []float64         `json:"setPoint"`
+	HeadingError    []float64         `json:"headingError"`
+	PIDOutput       []float64         `json:"pidOutput"`
+	Proportional    []float64         `json:"proportional"`
+	Integral        []float64         `json:"integral"`
+	Derivative      []float64         `json:"derivative"`
+	Steering        []float64         `json:"steering"`
+	SteeringEnabled []bool            `json:"steeringEnabled"`
+	Alarm           []bool            `json:"alarm"`
+	Leds            map[string][]bool `json:"leds"`
+}
+
+// mkTimeSeries transposes the points in a TimeSeries
+func mkTimeSeries(points []types.Point) TimeSeries {
+	n := len(points)
+	ts := TimeSeries{
+		Time:            make([]types.JSONTime, n),
+		Course:          make([]float64, n),
+		Speed:           make([]float64, n),
+		SetPoint:        make([]float64, n),
+		HeadingError:    make([]float64, n),
+		PIDOutput:       make([]float64, n),
+		Proportional:    make([]float64, n),
+		Integral:        make([]float64, n),
+		Derivative:      make([]float64, n),
+		Steering:        make([]float64, n),
+		SteeringEnabled: make([]bool, n),
+		Alarm:           make([]bool, n),
+		Leds:            make(map[string][]bool),
+	}
+
+	for i, p := range points {
+		ts.Time[i] = p.Time
+		ts.Course[i] = p.Course
+		ts.Speed[i] = p.Speed
+		ts.SetPoint[i] = p.SetPoint
+		ts.HeadingError[i] = p.HeadingError
+		ts.PIDOutput[i] = p.PIDOutput
+		ts.Proportional[i] = p.Proportional
+		ts.Integral[i] = p.Integral
+		ts.Derivative[i] = p.Derivative
+		ts.Steering[i] = p.Steering
+		ts.SteeringEnabled[i] = p.SteeringEnabled
+		ts.Alarm[i] = p.Alarm
+		for led, state := range p.Leds {
+			if _, ok := ts.Leds[led]; !ok {
+				ts.Leds[led] = make([]bool, n)
+			}
+			ts.Leds[led][i] = state
+		}
+	}
+	return ts
 }
 
 // Webserver is a web server component exposing both static files (static/) and the api (api/)
 type Webserver struct {
 	pilot     pilotable
 	dashboard queryable
-	tracer    tracer
+	tracer    traceable
 	version   string
 
+	eventChan chan interface{}
+	broker    *broker
+
+	auth *Authenticator
+
+	configMu   sync.Mutex
+	config     conf.Configuration // protected by configMu
+	configFile string
+
 	panicChan chan interface{}
 }
 
@@ -79,14 +246,68 @@ type pilotable interface {
 	Enable() error
 	Disable() error
 	SetOffset(headingOffset float64) error
+	SetResponse(response pilot.Response) error
+	AdjustHeading(delta float64) error
+	SetHeading(heading float64) error
+	RecaptureHeading() error
+	Tack(side pilot.Side) error
+	SetMode(mode pilot.Mode) error
+	Configure(configuration conf.Configuration) error
 }
 
 type queryable interface {
 	GetDashboardInfoAction() map[string]bool
 }
 
-type tracer interface {
-	GetPoints() []types.Point
+type traceable interface {
+	GetFilteredPoints(filter tracer.Filter) []types.Point
+	GetTrack(from, to time.Time) ([]types.Point, error)
+}
+
+// parseTimeParameter parses a time query parameter given in seconds since epoch
+func parseTimeParameter(r *rest.Request, name string, defaultValue time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	stamp, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return defaultValue, fmt.Errorf("invalid %s parameter [%s]: %v", name, value, err)
+	}
+	return time.Unix(stamp, 0), nil
+}
+
+// parseFloatParameter parses a numerical query parameter
+func parseFloatParameter(r *rest.Request, name string, defaultValue float64) (float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return defaultValue, fmt.Errorf("invalid %s parameter [%s]", name, value)
+	}
+	return f, nil
+}
+
+// parseFilter builds a tracer.Filter from the since (seconds since epoch), bucket (seconds)
+// and tolerance (meters) query parameters
+func parseFilter(r *rest.Request) (tracer.Filter, error) {
+	var filter tracer.Filter
+	var err error
+
+	if filter.Since, err = parseTimeParameter(r, "since", time.Time{}); err != nil {
+		return filter, err
+	}
+
+	bucket, err := parseFloatParameter(r, "bucket", 0)
+	if err != nil {
+		return filter, err
+	}
+	filter.Bucket = time.Duration(bucket * float64(time.Second))
+
+	filter.Tolerance, err = parseFloatParameter(r, "tolerance", 0)
+	return filter, err
 }
 
 // New creates a new Webserver
@@ -105,10 +326,173 @@ func (ws *Webserver) SetPilot(p pilotable) {
 }
 
 // SetTracer sets the Tracer used by the Webserver
-func (ws *Webserver) SetTracer(p tracer) {
+func (ws *Webserver) SetTracer(p traceable) {
 	ws.tracer = p
 }
 
+// SetEventChan sets the channel where the components publish the events streamed to the live clients
+func (ws *Webserver) SetEventChan(c chan interface{}) {
+	ws.eventChan = c
+}
+
+// SetAuthenticator sets the Authenticator checking the requests - without one the API is open to anybody
+func (ws *Webserver) SetAuthenticator(auth *Authenticator) {
+	ws.auth = auth
+}
+
+// SetConfiguration sets the configuration in use and the file where its changes are saved
+func (ws *Webserver) SetConfiguration(configuration conf.Configuration, file string) {
+	ws.configMu.Lock()
+	defer ws.configMu.Unlock()
+	ws.config = configuration
+	ws.configFile = file
+}
+
+// updateConfiguration validates, applies and then saves a change of the configuration.
+//
+// A change the pilot refuses is not saved. A change that is applied but cannot be saved is kept until the next restart
+// and reported as an error.
+func (ws *Webserver) updateConfiguration(update func(conf.Configuration) (conf.Configuration, error)) (conf.Configuration, int, error) {
+	ws.configMu.Lock()
+	defer ws.configMu.Unlock()
+
+	next, err := update(ws.config)
+	if err != nil {
+		return ws.config, http.StatusBadRequest, err
+	}
+	if err := conf.Validate(next); err != nil {
+		return ws.config, http.StatusBadRequest, err
+	}
+
+	if err := ws.pilot.Configure(next); err != nil {
+		return ws.config, http.StatusBadRequest, err
+	}
+	ws.config = next
+
+	if err := conf.Save(ws.configFile, next, conf.LiveKeys); err != nil {
+		log.Error("Failed to save the configuration in %s: %v", ws.configFile, err)
+		return next, http.StatusInternalServerError, fmt.Errorf("configuration applied but not saved: %v", err)
+	}
+
+	return next, http.StatusOK, nil
+}
+
+// controlEndpoint changes the autopilot state
+func (ws *Webserver) controlEndpoint(w rest.ResponseWriter, r *rest.Request) {
+	if _, ok := ws.pilot.(pilotable); !ok {
+		log.Error("WS is not initialized")
+		rest.Error(w, "WS is not initialized", http.StatusInternalServerError)
+		return
+	}
+
+	autopilot := Control{}
+	err := r.DecodeJsonPayload(&autopilot)
+
+	if err != nil {
+		log.Error("Failed to parse json:", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Info("Got %v", autopilot)
+	// nothing is applied unless the whole request is valid
+	if autopilot.Response != "" {
+		if err := pilot.ValidateResponse(pilot.Response(autopilot.Response)); err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if autopilot.Mode != "" {
+		if err := pilot.ValidateMode(pilot.Mode(autopilot.Mode)); err != nil {
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if autopilot.Response != "" {
+		err = ws.pilot.SetResponse(pilot.Response(autopilot.Response))
+		if err != nil {
+			log.Error("Failed to change the response level:", err)
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if autopilot.Mode != "" {
+		err = ws.pilot.SetMode(pilot.Mode(autopilot.Mode))
+		if err != nil {
+			log.Error("Failed to change the mode:", err)
+			rest.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	ws.pilot.SetOffset(autopilot.HeadingOffset)
+	if autopilot.Enabled {
+		err = ws.pilot.Enable()
+		if err != nil {
+			log.Error("Failed to enable autopilot:", err)
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		err = ws.pilot.Disable()
+		if err != nil {
+			log.Error("Failed to disable autopilot:", err)
+			rest.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteJson(map[string]string{"status": "OK"})
+}
+
+// sessionEndpoint tells the role granted to the request
+func (ws *Webserver) sessionEndpoint(w rest.ResponseWriter, r *rest.Request) {
+	if ws.auth == nil {
+		w.WriteJson(Session{Role: ControlRole.String()})
+		return
+	}
+	w.WriteJson(Session{Role: ws.auth.Role(r.Request).String()})
+}
+
+// loginEndpoint opens a session for a PIN
+func (ws *Webserver) loginEndpoint(w rest.ResponseWriter, r *rest.Request) {
+	if ws.auth == nil {
+		rest.Error(w, "authentication is not enabled", http.StatusNotFound)
+		return
+	}
+
+	login := Login{}
+	err := r.DecodeJsonPayload(&login)
+	if err != nil {
+		log.Error("Failed to parse json:", err)
+		rest.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token, role, expires, err := ws.auth.Login(clientHost(r.Request), login.PIN)
+	switch err {
+	case nil:
+	case ErrInvalidPIN:
+		rest.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case ErrLockedOut:
+		rest.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	default:
+		log.Error("Failed to open a session:", err)
+		rest.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteJson(Session{Token: token, Role: role.String(), Expires: types.JSONTime(expires)})
+}
+
+// logoutEndpoint closes the session of the request
+func (ws *Webserver) logoutEndpoint(w rest.ResponseWriter, r *rest.Request) {
+	if ws.auth != nil {
+		ws.auth.Logout(requestToken(r.Request))
+	}
+	w.WriteJson(map[string]string{"status": "OK"})
+}
+
 // SetDashboard sets the Dashboard where the Webserver will get the LED state from
 func (ws *Webserver) SetDashboard(dashboard queryable) {
 	ws.dashboard = dashboard
@@ -124,17 +508,113 @@ func (ws *Webserver) Start() {
 			}
 		}()
 
+		if ws.eventChan != nil {
+			ws.broker = newBroker(ws.eventChan)
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						ws.panicChan <- r
+					}
+				}()
+				ws.broker.run()
+			}()
+		}
+
 		api := rest.NewApi()
 		api.Use(rest.DefaultDevStack...)
+		if ws.auth != nil {
+			api.Use(ws.auth)
+		}
 
 		router, err := rest.MakeRouter(
 			rest.Get("/points", func(w rest.ResponseWriter, req *rest.Request) {
-				if _, ok := ws.pilot.(pilotable); !ok {
+				if _, ok := ws.tracer.(traceable); !ok {
 					log.Error("WS is not initialized")
 					rest.Error(w, "WS is not initialized", http.StatusInternalServerError)
 					return
 				}
-				w.WriteJson(ws.tracer.GetPoints())
+
+				filter, err := parseFilter(req)
+				if err != nil {
+					rest.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				w.WriteJson(ws.tracer.GetFilteredPoints(filter))
+			}),
+			rest.Get("/timeseries", func(w rest.ResponseWriter, r *rest.Request) {
+				if _, ok := ws.tracer.(traceable); !ok {
+					log.Error("WS is not initialized")
+					rest.Error(w, "WS is not initialized", http.StatusInternalServerError)
+					return
+				}
+
+				// the last hour by default
+				to, err := parseTimeParameter(r, "to", time.Now())
+				if err != nil {
+					rest.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				from, err := parseTimeParameter(r, "from", to.Add(-time.Hour))
+				if err != nil {
+					rest.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				points, err := ws.tracer.GetTrack(from, to)
+				if err == tracer.ErrRangeTooLarge {
+					rest.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if err != nil {
+					log.Error("Failed to get the track:", err)
+					rest.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.WriteJson(mkTimeSeries(points))
+			}),
+			rest.Get("/track/:format", func(w rest.ResponseWriter, r *rest.Request) {
+				if _, ok := ws.tracer.(traceable); !ok {
+					log.Error("WS is not initialized")
+					rest.Error(w, "WS is not initialized", http.StatusInternalServerError)
+					return
+				}
+
+				format := r.PathParam("format")
+				contentType, ok := tracer.ContentTypes[format]
+				if !ok {
+					rest.Error(w, fmt.Sprintf("unknown track format: %s", format), http.StatusNotFound)
+					return
+				}
+
+				// the last 24 hours by default
+				to, err := parseTimeParameter(r, "to", time.Now())
+				if err != nil {
+					rest.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				from, err := parseTimeParameter(r, "from", to.Add(-24*time.Hour))
+				if err != nil {
+					rest.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+
+				points, err := ws.tracer.GetTrack(from, to)
+				if err == tracer.ErrRangeTooLarge {
+					rest.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if err != nil {
+					log.Error("Failed to get the track:", err)
+					rest.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				w.Header().Set("Content-Type", contentType)
+				w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"track.%s\"", format))
+				err = tracer.Export(w.(http.ResponseWriter), format, points)
+				if err != nil {
+					log.Error("Failed to export the track:", err)
+				}
 			}),
 			rest.Get("/autopilot", func(w rest.ResponseWriter, req *rest.Request) {
 				if _, ok := ws.pilot.(pilotable); !ok {
@@ -143,14 +623,7 @@ func (ws *Webserver) Start() {
 					return
 				}
 
-				pi := ws.pilot.GetInfoAction()
-				w.WriteJson(Autopilot{
-					Enabled:       pi.Enabled,
-					HeadingOffset: pi.HeadingOffset,
-					SetPoint:      pi.SetPoint,
-					Course:        pi.Course,
-					Speed:         pi.Speed,
-				})
+				w.WriteJson(mkAutopilot(ws.pilot.GetInfoAction()))
 				return
 			}),
 			rest.Get("/dashboard", func(w rest.ResponseWriter, req *rest.Request) {
@@ -162,38 +635,141 @@ func (ws *Webserver) Start() {
 				data := ws.dashboard.GetDashboardInfoAction()
 				w.WriteJson(data)
 			}),
-			rest.Put("/autopilot", func(w rest.ResponseWriter, r *rest.Request) {
+			rest.Put("/autopilot", ws.controlEndpoint),
+			rest.Put("/autopilot/heading", func(w rest.ResponseWriter, r *rest.Request) {
 				if _, ok := ws.pilot.(pilotable); !ok {
 					log.Error("WS is not initialized")
 					rest.Error(w, "WS is not initialized", http.StatusInternalServerError)
 					return
 				}
 
-				autopilot := Control{}
-				err := r.DecodeJsonPayload(&autopilot)
+				heading := Heading{}
+				err := r.DecodeJsonPayload(&heading)
+				if err != nil {
+					log.Error("Failed to parse json:", err)
+					rest.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				if !ws.pilot.GetInfoAction().Enabled {
+					rest.Error(w, "the autopilot is not enabled", http.StatusConflict)
+					return
+				}
 
+				log.Info("Got %v", heading)
+				err = ws.pilot.SetHeading(heading.Heading)
+				if err != nil {
+					log.Error("Failed to set the heading:", err)
+					rest.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				w.WriteJson(map[string]string{"status": "OK"})
+			}),
+			rest.Post("/autopilot/heading/adjust", func(w rest.ResponseWriter, r *rest.Request) {
+				if _, ok := ws.pilot.(pilotable); !ok {
+					log.Error("WS is not initialized")
+					rest.Error(w, "WS is not initialized", http.StatusInternalServerError)
+					return
+				}
+
+				adjustment := HeadingAdjustment{}
+				err := r.DecodeJsonPayload(&adjustment)
 				if err != nil {
 					log.Error("Failed to parse json:", err)
 					rest.Error(w, err.Error(), http.StatusInternalServerError)
 					return
 				}
 
-				log.Info("Got %v", autopilot)
-				ws.pilot.SetOffset(autopilot.HeadingOffset)
-				if autopilot.Enabled {
-					err = ws.pilot.Enable()
-					if err != nil {
-						log.Error("Failed to enable autopilot:", err)
-						rest.Error(w, err.Error(), http.StatusInternalServerError)
-						return
-					}
-				} else {
-					err = ws.pilot.Disable()
-					if err != nil {
-						log.Error("Failed to disable autopilot:", err)
-						rest.Error(w, err.Error(), http.StatusInternalServerError)
-						return
+				if !ws.pilot.GetInfoAction().Enabled {
+					rest.Error(w, "the autopilot is not enabled", http.StatusConflict)
+					return
+				}
+
+				log.Info("Got %v", adjustment)
+				err = ws.pilot.AdjustHeading(adjustment.Delta)
+				if err != nil {
+					log.Error("Failed to adjust the heading:", err)
+					rest.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				w.WriteJson(map[string]string{"status": "OK"})
+			}),
+			rest.Post("/autopilot/heading/recapture", func(w rest.ResponseWriter, r *rest.Request) {
+				if _, ok := ws.pilot.(pilotable); !ok {
+					log.Error("WS is not initialized")
+					rest.Error(w, "WS is not initialized", http.StatusInternalServerError)
+					return
+				}
+
+				if !ws.pilot.GetInfoAction().Enabled {
+					rest.Error(w, "the autopilot is not enabled", http.StatusConflict)
+					return
+				}
+
+				err := ws.pilot.RecaptureHeading()
+				if err != nil {
+					log.Error("Failed to recapture the heading:", err)
+					rest.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				w.WriteJson(map[string]string{"status": "OK"})
+			}),
+			rest.Get("/config", func(w rest.ResponseWriter, r *rest.Request) {
+				ws.configMu.Lock()
+				defer ws.configMu.Unlock()
+				w.WriteJson(mkConfig(ws.config))
+			}),
+			rest.Put("/config", func(w rest.ResponseWriter, r *rest.Request) {
+				if _, ok := ws.pilot.(pilotable); !ok {
+					log.Error("WS is not initialized")
+					rest.Error(w, "WS is not initialized", http.StatusInternalServerError)
+					return
+				}
+
+				next, status, err := ws.updateConfiguration(func(c conf.Configuration) (conf.Configuration, error) {
+					// the parameters missing from the payload keep their current value
+					config := mkConfig(c)
+					if err := r.DecodeJsonPayload(&config); err != nil {
+						return c, err
 					}
+					log.Info("Got %v", config)
+					return config.apply(c), nil
+				})
+				if err != nil {
+					rest.Error(w, err.Error(), status)
+					return
+				}
+				w.WriteJson(mkConfig(next))
+			}),
+			rest.Get("/session", ws.sessionEndpoint),
+			rest.Post("/session", ws.loginEndpoint),
+			rest.Delete("/session", ws.logoutEndpoint),
+			rest.Post("/autopilot/tack", func(w rest.ResponseWriter, r *rest.Request) {
+				if _, ok := ws.pilot.(pilotable); !ok {
+					log.Error("WS is not initialized")
+					rest.Error(w, "WS is not initialized", http.StatusInternalServerError)
+					return
+				}
+
+				tack := Tack{}
+				err := r.DecodeJsonPayload(&tack)
+				if err != nil {
+					log.Error("Failed to parse json:", err)
+					rest.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				if !ws.pilot.GetInfoAction().Enabled {
+					rest.Error(w, "the autopilot is not enabled", http.StatusConflict)
+					return
+				}
+
+				log.Info("Got %v", tack)
+				err = ws.pilot.Tack(pilot.Side(tack.Side))
+				if err != nil {
+					log.Error("Failed to tack:", err)
+					rest.Error(w, err.Error(), http.StatusBadRequest)
+					return
 				}
 				w.WriteJson(map[string]string{"status": "OK"})
 			}),
@@ -204,6 +780,11 @@ func (ws *Webserver) Start() {
 		api.SetApp(router)
 
 		http.Handle("/api/", http.StripPrefix("/api", api.MakeHandler()))
+		if ws.auth != nil {
+			http.HandleFunc("/api/stream", ws.auth.HandlerFunc(ReadOnlyRole, ws.streamEndpoint))
+		} else {
+			http.HandleFunc("/api/stream", ws.streamEndpoint)
+		}
 
 		http.Handle("/static/", http.StripPrefix("/static", http.FileServer(http.Dir("."))))
 
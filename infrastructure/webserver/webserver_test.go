@@ -0,0 +1,96 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-16 21:40:12
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-16 21:40:12
+ */
+
+package webserver
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/ssoudan/edisonIsThePilot/pilot"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingPilot records the changes made by the control endpoint - the other methods of pilotable panic
+type recordingPilot struct {
+	pilotable
+	calls []string
+}
+
+func (p *recordingPilot) SetResponse(response pilot.Response) error {
+	p.calls = append(p.calls, "SetResponse")
+	return nil
+}
+
+func (p *recordingPilot) SetMode(mode pilot.Mode) error {
+	p.calls = append(p.calls, "SetMode")
+	return nil
+}
+
+func (p *recordingPilot) SetOffset(headingOffset float64) error {
+	p.calls = append(p.calls, "SetOffset")
+	return nil
+}
+
+func (p *recordingPilot) Enable() error {
+	p.calls = append(p.calls, "Enable")
+	return nil
+}
+
+func (p *recordingPilot) Disable() error {
+	p.calls = append(p.calls, "Disable")
+	return nil
+}
+
+func TestControl(t *testing.T) {
+
+	cases := []controlCase{
+		controlCase{payload: `{"enabled": true}`, expectedStatus: http.StatusOK, expectedCalls: []string{"SetOffset", "Enable"}, description: "Enable"},
+		controlCase{payload: `{"enabled": false, "response": "economy", "mode": "wind"}`, expectedStatus: http.StatusOK, expectedCalls: []string{"SetResponse", "SetMode", "SetOffset", "Disable"}, description: "Response and mode"},
+		controlCase{payload: `{"enabled": true, "response": "economy", "mode": "nope"}`, expectedStatus: http.StatusBadRequest, description: "Unknown mode"},
+		controlCase{payload: `{"enabled": true, "response": "nope", "mode": "wind"}`, expectedStatus: http.StatusBadRequest, description: "Unknown response"},
+	}
+
+	for _, c := range cases {
+		checkControlCase(t, c)
+	}
+}
+
+type controlCase struct {
+	payload        string
+	expectedStatus int
+	expectedCalls  []string
+	description    string
+}
+
+func checkControlCase(t *testing.T, c controlCase) {
+	p := &recordingPilot{}
+	ws := &Webserver{pilot: p}
+
+	w := newTestWriter()
+	ws.controlEndpoint(w, newRequest("PUT", "/autopilot", "", c.payload))
+
+	assert.Equal(t, c.expectedStatus, w.Code, fmt.Sprintf("\"%s\" [status] case failed", c.description))
+	assert.Equal(t, c.expectedCalls, p.calls, fmt.Sprintf("\"%s\" [calls] case failed", c.description))
+}
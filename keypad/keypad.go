@@ -0,0 +1,226 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-04 20:41:12
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-04 20:41:12
+ */
+
+package keypad
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+var log = logger.Log("keypad")
+
+// Names of the buttons of the keypad
+const (
+	Minus10 = "Minus10"
+	Minus1  = "Minus1"
+	Plus1   = "Plus1"
+	Plus10  = "Plus10"
+	Auto    = "Auto"
+	Standby = "Standby"
+)
+
+const (
+	// DebounceDelay is the duration a button has to keep the same value to be considered stable
+	DebounceDelay = 50 * time.Millisecond
+	// LongPressDelay is the duration a button has to be held to be considered as a long press
+	LongPressDelay = 1 * time.Second
+
+	pollingPeriod = 10 * time.Millisecond
+)
+
+// Gesture is a short or long press on a button or on a combination of buttons
+type Gesture struct {
+	Buttons string // names of the buttons sorted and joined with '+'
+	Long    bool
+}
+
+func mkGesture(long bool, buttons ...string) Gesture {
+	names := append([]string(nil), buttons...)
+	sort.Strings(names)
+	return Gesture{Buttons: strings.Join(names, "+"), Long: long}
+}
+
+// Short is a short press on one or more buttons at the same time
+func Short(buttons ...string) Gesture {
+	return mkGesture(false, buttons...)
+}
+
+// Long is a long press on one or more buttons at the same time
+func Long(buttons ...string) Gesture {
+	return mkGesture(true, buttons...)
+}
+
+type button struct {
+	input     types.Readable
+	raw       bool
+	changedAt time.Time
+	pressed   bool // debounced state
+}
+
+// Keypad is a component that monitors several buttons and dispatches the gestures to the bound actions
+type Keypad struct {
+	buttons  map[string]*button
+	handlers map[Gesture]func() error
+
+	// current gesture
+	gesture   map[string]bool
+	since     time.Time
+	longFired bool
+
+	// channels
+	shutdownChan chan interface{}
+	panicChan    chan interface{}
+}
+
+// New creates a new Keypad component from a map of button names to their input
+func New(buttons map[string]types.Readable) *Keypad {
+	k := &Keypad{
+		buttons:      make(map[string]*button, len(buttons)),
+		handlers:     make(map[Gesture]func() error),
+		gesture:      make(map[string]bool),
+		shutdownChan: make(chan interface{})}
+	for name, input := range buttons {
+		k.buttons[name] = &button{input: input}
+	}
+	return k
+}
+
+// SetPanicChan sets the channel where panics will be sent
+func (k *Keypad) SetPanicChan(p chan interface{}) {
+	k.panicChan = p
+}
+
+// Bind sets the action triggered by a gesture - must be called before Start()
+func (k *Keypad) Bind(gesture Gesture, action func() error) {
+	k.handlers[gesture] = action
+}
+
+func (k *Keypad) dispatch(gesture Gesture) {
+	action, ok := k.handlers[gesture]
+	if !ok {
+		log.Info("No action bound to %v", gesture)
+		return
+	}
+
+	log.Notice("Got %v", gesture)
+	if err := action(); err != nil {
+		log.Error("Failed to handle %v: %v", gesture, err)
+	}
+}
+
+func (k *Keypad) update(now time.Time) error {
+
+	down := false
+	for name, b := range k.buttons {
+		value, err := b.input.Value()
+		if err != nil {
+			return err
+		}
+
+		if value != b.raw {
+			b.raw = value
+			b.changedAt = now
+		}
+		if b.raw != b.pressed && now.Sub(b.changedAt) >= DebounceDelay {
+			b.pressed = b.raw
+		}
+
+		if b.pressed {
+			down = true
+			if len(k.gesture) == 0 {
+				k.since = now
+			}
+			// every button pressed while the gesture is in progress is part of it
+			k.gesture[name] = true
+		}
+	}
+
+	if len(k.gesture) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(k.gesture))
+	for name := range k.gesture {
+		names = append(names, name)
+	}
+
+	if down {
+		if !k.longFired && now.Sub(k.since) >= LongPressDelay {
+			k.longFired = true
+			k.dispatch(Long(names...))
+		}
+		return nil
+	}
+
+	// all the buttons have been released
+	if !k.longFired {
+		k.dispatch(Short(names...))
+	}
+	k.gesture = make(map[string]bool)
+	k.longFired = false
+
+	return nil
+}
+
+// Shutdown stops the event loop
+func (k Keypad) Shutdown() {
+	k.shutdownChan <- 1
+	<-k.shutdownChan
+}
+
+func (k Keypad) shutdown() {
+	// Nothing
+	close(k.shutdownChan)
+}
+
+// Start the event loop of the Keypad component
+func (k Keypad) Start() {
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				k.panicChan <- r
+			}
+		}()
+
+		for {
+			select {
+			case now := <-time.After(pollingPeriod):
+				err := k.update(now)
+				if err != nil {
+					log.Panicf("Error while reading the keypad: %v", err)
+				}
+			case <-k.shutdownChan:
+				k.shutdown()
+				return
+			}
+
+		}
+	}()
+
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-04 20:41:12
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-04 20:41:12
+ */
+package keypad
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+var _ = Describe("Keypad", func() {
+
+	var (
+		keypad   *Keypad
+		plus     *testButton
+		minus    *testButton
+		gestures []Gesture
+		now      time.Time
+	)
+
+	// step advances the clock and polls the keypad
+	step := func(d time.Duration) {
+		now = now.Add(d)
+		Expect(keypad.update(now)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		plus = &testButton{}
+		minus = &testButton{}
+		gestures = nil
+		now = time.Now()
+
+		keypad = New(map[string]types.Readable{Plus1: plus, Minus1: minus})
+		for _, g := range []Gesture{Short(Plus1), Long(Plus1), Short(Minus1), Short(Plus1, Minus1)} {
+			g := g
+			keypad.Bind(g, func() error {
+				gestures = append(gestures, g)
+				return nil
+			})
+		}
+	})
+
+	Describe("when a button bounces", func() {
+
+		It("ignores the glitches", func() {
+			plus.value = true
+			step(10 * time.Millisecond)
+			plus.value = false
+			step(10 * time.Millisecond)
+			step(DebounceDelay)
+
+			Expect(gestures).To(BeEmpty())
+		})
+
+	})
+
+	Describe("when a button is pressed and released", func() {
+
+		It("dispatches a short press", func() {
+			plus.value = true
+			step(10 * time.Millisecond)
+			step(DebounceDelay)
+			Expect(gestures).To(BeEmpty())
+
+			plus.value = false
+			step(10 * time.Millisecond)
+			step(DebounceDelay)
+
+			Expect(gestures).To(Equal([]Gesture{Short(Plus1)}))
+		})
+
+	})
+
+	Describe("when a button is held", func() {
+
+		It("dispatches a single long press", func() {
+			plus.value = true
+			step(10 * time.Millisecond)
+			step(DebounceDelay)
+			step(LongPressDelay)
+			Expect(gestures).To(Equal([]Gesture{Long(Plus1)}))
+
+			step(LongPressDelay)
+			plus.value = false
+			step(10 * time.Millisecond)
+			step(DebounceDelay)
+
+			Expect(gestures).To(Equal([]Gesture{Long(Plus1)}))
+		})
+
+	})
+
+	Describe("when two buttons are pressed together", func() {
+
+		It("dispatches a combination", func() {
+			plus.value = true
+			step(10 * time.Millisecond)
+			step(DebounceDelay)
+			minus.value = true
+			step(10 * time.Millisecond)
+			step(DebounceDelay)
+
+			plus.value = false
+			step(10 * time.Millisecond)
+			step(DebounceDelay)
+			Expect(gestures).To(BeEmpty())
+
+			minus.value = false
+			step(10 * time.Millisecond)
+			step(DebounceDelay)
+
+			Expect(gestures).To(Equal([]Gesture{Short(Minus1, Plus1)}))
+		})
+
+	})
+
+	Describe("when started", func() {
+
+		It("polls the buttons", func() {
+			done := make(chan interface{}, 1)
+			keypad.Bind(Short(Minus1), func() error {
+				done <- 1
+				return nil
+			})
+			keypad.Start()
+
+			minus.value = true
+			time.Sleep(2 * DebounceDelay)
+			minus.value = false
+
+			Eventually(done).Should(Receive())
+			keypad.Shutdown()
+		})
+
+	})
+
+})
+
+type testButton struct {
+	value bool
+	err   error
+}
+
+func (t *testButton) Value() (bool, error) {
+	return t.value, t.err
+}
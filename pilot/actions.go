@@ -18,12 +18,16 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-20 21:45:21
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 12:48:03
+* @Last Modified time: 2015-12-01 21:12:40
  */
 
 package pilot
 
 import (
+	"fmt"
+
+	"github.com/ssoudan/edisonIsThePilot/dashboard"
+
 	"github.com/adrianmo/go-nmea"
 )
 
@@ -48,13 +52,53 @@ type setOffsetAction struct {
 	headingOffset float64
 }
 
+const (
+	// SmallHeadingAdjustment is the small nudge (in degree) of the setpoint
+	SmallHeadingAdjustment = 1.
+	// LargeHeadingAdjustment is the large nudge (in degree) of the setpoint
+	LargeHeadingAdjustment = 10.
+)
+
+type adjustHeadingAction struct {
+	delta float64
+}
+
+type setHeadingAction struct {
+	heading float64
+}
+
+type recaptureHeadingAction struct {
+}
+
+// NewAdjustHeadingMessage creates a message to nudge the setpoint by delta degree (positive is to starboard)
+func NewAdjustHeadingMessage(delta float64) interface{} {
+	return adjustHeadingAction{delta: delta}
+}
+
+// NewSetHeadingMessage creates a message to set the setpoint to an absolute heading (in degree)
+func NewSetHeadingMessage(heading float64) interface{} {
+	return setHeadingAction{heading: heading}
+}
+
+// NewRecaptureHeadingMessage creates a message to set the setpoint to the next course received
+func NewRecaptureHeadingMessage() interface{} {
+	return recaptureHeadingAction{}
+}
+
 // Info contains the Pilot state information as used by the Webserver for example
 type Info struct {
-	Course        float64
-	SetPoint      float64
-	HeadingOffset float64
-	Speed         float64
-	Enabled       bool
+	Course         float64
+	SetPoint       float64
+	HeadingOffset  float64
+	Speed          float64
+	Enabled        bool
+	Response       Response
+	ActiveResponse Response
+	Tacking        bool
+	Mode           Mode
+	WindAngle      float64
+	ApparentWind   float64
+	WindSpeed      float64
 }
 
 type getInfoAction struct {
@@ -70,14 +114,24 @@ func (p *Pilot) GetInfoAction() Info {
 }
 
 func (p *Pilot) getInfoAction(c chan Info) {
-	i := Info{
-		Course:        p.course,
-		SetPoint:      p.heading,
-		HeadingOffset: p.headingOffset,
-		Enabled:       p.enabled,
-		Speed:         p.speed,
+	c <- p.info()
+}
+
+func (p *Pilot) info() Info {
+	return Info{
+		Course:         p.course,
+		SetPoint:       p.heading,
+		HeadingOffset:  p.headingOffset,
+		Enabled:        p.enabled,
+		Speed:          p.speed,
+		Response:       p.response,
+		ActiveResponse: p.activeResponse,
+		Tacking:        p.tack != nil,
+		Mode:           p.mode,
+		WindAngle:      p.windAngle,
+		ApparentWind:   p.apparentWind,
+		WindSpeed:      p.windSpeed,
 	}
-	c <- i
 }
 
 // SetOffset changes the heading offset
@@ -90,6 +144,74 @@ func (p *Pilot) setOffset(headingOffset float64) {
 	p.headingOffset = headingOffset
 }
 
+// AdjustHeading nudges the setpoint by delta degree (positive is to starboard)
+func (p *Pilot) AdjustHeading(delta float64) error {
+	if delta <= -180 || delta > 180 {
+		return fmt.Errorf("heading adjustment must be in ]-180:180] range: %v", delta)
+	}
+	p.inputChan <- NewAdjustHeadingMessage(delta)
+	return nil
+}
+
+// SetHeading sets the setpoint to an absolute heading (in degree)
+func (p *Pilot) SetHeading(heading float64) error {
+	if heading < 0 || heading >= 360 {
+		return fmt.Errorf("heading must be in [0:360[ range: %v", heading)
+	}
+	p.inputChan <- NewSetHeadingMessage(heading)
+	return nil
+}
+
+// RecaptureHeading sets the setpoint to the current course
+func (p *Pilot) RecaptureHeading() error {
+	p.inputChan <- NewRecaptureHeadingMessage()
+	return nil
+}
+
+func (p *Pilot) adjustHeading(delta float64) {
+	if p.enabled && p.mode == WindVane && p.windSet {
+		// turning to starboard brings the wind toward port
+		p.changeWindSetPoint(p.windAngle-delta, fmt.Sprintf("adjusted by %+v", delta))
+		return
+	}
+	if !p.enabled || !p.headingSet {
+		log.Warning("Ignoring heading adjustment of %v: no heading is held", delta)
+		return
+	}
+	p.cancelTack("setpoint adjusted")
+	p.changeSetPoint(p.heading+delta, fmt.Sprintf("adjusted by %+v", delta))
+}
+
+func (p *Pilot) setHeading(heading float64) {
+	if !p.enabled {
+		log.Warning("Ignoring heading %v: the pilot is not enabled", heading)
+		return
+	}
+	if p.mode != Compass {
+		log.Notice("Mode changed from %v to %v", p.mode, Compass)
+		p.mode = Compass
+	}
+	p.cancelTack("setpoint set")
+	p.changeSetPoint(heading, "set")
+	p.pid.Set(0) // Reference is always 0 for us
+	p.headingSet = true
+}
+
+func (p *Pilot) recaptureHeading() {
+	if !p.enabled {
+		log.Warning("Ignoring heading recapture: the pilot is not enabled")
+		return
+	}
+	p.cancelTack("heading recaptured")
+	if p.mode == WindVane {
+		log.Notice("Setpoint will be set to the next apparent wind angle")
+		p.windSet = false
+		return
+	}
+	log.Notice("Setpoint will be set to the next course")
+	p.headingSet = false
+}
+
 // Enable the autopilot
 func (p *Pilot) Enable() error {
 	p.inputChan <- enableAction{}
@@ -105,11 +227,17 @@ func (p *Pilot) Disable() error {
 func (p *Pilot) enable() {
 	p.enabled = true
 	p.headingSet = false
+	p.windSet = false
+	p.cancelTack("pilot enabled")
 }
 
 func (p *Pilot) disable() {
+	p.cancelTack("pilot disabled")
 	p.enabled = false
 	p.alarm = UNRAISED
+	p.windAlarm = false
+	p.leds[dashboard.WindDataStale] = false
+	p.leds[dashboard.MotorFault] = false
 }
 
 // Shutdown the event loop of the Pilot
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-20 21:48:32
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-09-29 20:16:34
+* @Last Modified time: 2015-11-03 21:14:37
  */
 
 package pilot
@@ -96,3 +96,80 @@ func TestEnableDisable(t *testing.T) {
 	assert.EqualValues(t, gpsHeading3, pilot.heading, "heading has been set to another value")
 
 }
+
+func TestHeadingAdjustments(t *testing.T) {
+
+	c := make(chan interface{})
+
+	go func() {
+		for {
+			<-c
+		}
+	}()
+	pilot := Pilot{
+		alarm:         UNRAISED,
+		bound:         45.,
+		leds:          make(map[string]bool),
+		dashboardChan: c,
+		alarmChan:     c,
+		steeringChan:  c,
+		inputChan:     make(chan interface{}),
+		pid:           &testController{}}
+
+	// Not enabled: everything is ignored
+	pilot.adjustHeading(LargeHeadingAdjustment)
+	pilot.setHeading(90.)
+	pilot.recaptureHeading()
+	assert.EqualValues(t, false, pilot.headingSet, "heading is not set while the pilot is disabled")
+	assert.EqualValues(t, 0., pilot.heading, "heading is not changed while the pilot is disabled")
+
+	pilot.enable()
+
+	// Enabled but no heading captured yet: adjustments are ignored
+	pilot.adjustHeading(SmallHeadingAdjustment)
+	assert.EqualValues(t, false, pilot.headingSet, "adjustment does not set the heading")
+	assert.EqualValues(t, 0., pilot.heading, "adjustment is ignored until a heading is held")
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 355., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.EqualValues(t, 355., pilot.heading, "heading has been captured")
+
+	pilot.adjustHeading(LargeHeadingAdjustment)
+	assert.EqualValues(t, 5., pilot.heading, "+10 wraps around north")
+
+	pilot.adjustHeading(-LargeHeadingAdjustment)
+	pilot.adjustHeading(-SmallHeadingAdjustment)
+	assert.EqualValues(t, 354., pilot.heading, "-10 and -1 wrap around north")
+
+	pilot.adjustHeading(SmallHeadingAdjustment)
+	assert.EqualValues(t, 355., pilot.heading, "+1")
+
+	pilot.setHeading(120.)
+	assert.EqualValues(t, true, pilot.headingSet, "heading is still held")
+	assert.EqualValues(t, 120., pilot.heading, "heading has been set")
+
+	pilot.recaptureHeading()
+	assert.EqualValues(t, false, pilot.headingSet, "heading need to be set during next updateFeedback")
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 42., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.EqualValues(t, true, pilot.headingSet, "headingSet is set")
+	assert.EqualValues(t, 42., pilot.heading, "heading has been recaptured")
+}
+
+func TestHeadingCommandsValidation(t *testing.T) {
+
+	pilot := Pilot{inputChan: make(chan interface{}, 10)}
+
+	assert.NotNil(t, pilot.AdjustHeading(180.1), "adjustment is out of range")
+	assert.NotNil(t, pilot.AdjustHeading(-180.), "adjustment is out of range")
+	assert.NotNil(t, pilot.SetHeading(360.), "heading is out of range")
+	assert.NotNil(t, pilot.SetHeading(-1.), "heading is out of range")
+	assert.Equal(t, 0, len(pilot.inputChan), "invalid commands are not sent")
+
+	assert.Nil(t, pilot.AdjustHeading(-SmallHeadingAdjustment))
+	assert.Nil(t, pilot.SetHeading(0.))
+	assert.Nil(t, pilot.RecaptureHeading())
+
+	assert.Equal(t, NewAdjustHeadingMessage(-SmallHeadingAdjustment), <-pilot.inputChan)
+	assert.Equal(t, NewSetHeadingMessage(0.), <-pilot.inputChan)
+	assert.Equal(t, NewRecaptureHeadingMessage(), <-pilot.inputChan)
+}
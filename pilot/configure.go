@@ -0,0 +1,69 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-15 19:42:57
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-15 19:42:57
+ */
+
+package pilot
+
+import (
+	"github.com/ssoudan/edisonIsThePilot/conf"
+)
+
+// Adjustable is a Controller whose gains can be changed at runtime
+type Adjustable interface {
+	SetGains(kp, ki, kd float64)
+}
+
+type configureAction struct {
+	configuration conf.Configuration
+}
+
+// NewConfigureMessage creates a new message to apply the conf.LiveKeys parameters of a configuration
+func NewConfigureMessage(configuration conf.Configuration) interface{} {
+	return configureAction{configuration: configuration}
+}
+
+// Configure applies the conf.LiveKeys parameters of a configuration: PID gains and output limits,
+// heading error bounds and minimum speed. The configuration is validated first.
+func (p *Pilot) Configure(configuration conf.Configuration) error {
+	if err := conf.Validate(configuration); err != nil {
+		return err
+	}
+	p.inputChan <- configureAction{configuration: configuration}
+	return nil
+}
+
+func (p *Pilot) configure(c conf.Configuration) {
+	log.Notice("Configuration changed: P=%v I=%v D=%v N=%v output limits=[%v:%v] bounds=%v minimum speed=%v",
+		c.P, c.I, c.D, c.N, c.MinPIDOutputLimits, c.MaxPIDOutputLimits, c.Bounds, c.MinimumSpeedInKnots)
+
+	p.bound = c.Bounds
+	p.minimumSpeed = c.MinimumSpeedInKnots
+	p.minOutput, p.maxOutput = c.MinPIDOutputLimits, c.MaxPIDOutputLimits
+	p.derivativeFilter = c.N
+
+	if a, ok := p.pid.(Adjustable); ok {
+		a.SetGains(c.P, c.I, c.D)
+	}
+
+	// the response profile scales the new output limits and derivative filter
+	p.applyResponse(p.activeResponse)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-15 19:42:57
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-15 19:42:57
+ */
+
+package pilot
+
+import (
+	"testing"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type adjustableController struct {
+	tunableController
+	kp, ki, kd float64
+}
+
+func (c *adjustableController) SetGains(kp, ki, kd float64) {
+	c.kp, c.ki, c.kd = kp, ki, kd
+}
+
+func TestThatConfigurationIsAppliedLive(t *testing.T) {
+	controller := &adjustableController{tunableController: tunableController{minOutput: -100, maxOutput: 100, n: 2}}
+	pilot := New(controller, 45)
+	pilot.setResponse(Economy)
+
+	c := conf.Conf
+	c.P, c.I, c.D, c.N = 1, 2, 3, 4
+	c.MinPIDOutputLimits, c.MaxPIDOutputLimits = -200, 200
+	c.Bounds = 30
+	c.MinimumSpeedInKnots = 1.5
+	pilot.configure(c)
+
+	assert.EqualValues(t, []float64{1, 2, 3}, []float64{controller.kp, controller.ki, controller.kd})
+	assert.EqualValues(t, 30, pilot.bound)
+	assert.EqualValues(t, 1.5, pilot.minimumSpeed)
+	assert.EqualValues(t, -100, controller.minOutput, "the response profile still applies")
+	assert.EqualValues(t, 100, controller.maxOutput, "the response profile still applies")
+	assert.EqualValues(t, 2, controller.n, "the response profile still applies")
+
+	pilot.setResponse(Normal)
+	assert.EqualValues(t, -200, controller.minOutput, "the new limits are kept")
+	assert.EqualValues(t, 4, controller.n, "the new derivative filter is kept")
+}
+
+func TestThatInvalidConfigurationIsRejected(t *testing.T) {
+	pilot := New(&testController{}, 45)
+
+	c := conf.Conf
+	c.MinPIDOutputLimits, c.MaxPIDOutputLimits = 10, -10
+	assert.Error(t, pilot.Configure(c))
+}
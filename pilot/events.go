@@ -0,0 +1,76 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-12 22:17:40
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-12 22:17:40
+ */
+
+package pilot
+
+import (
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+// publishState publishes the pilot info and the alarm and LED changes on eventChan.
+// A change that could not be published is published again on the next call.
+func (p *Pilot) publishState() {
+	if p.eventChan == nil {
+		return
+	}
+
+	types.Publish(p.eventChan, types.Event{Type: types.AutopilotEvent, Data: p.info()})
+
+	alarm := bool(p.alarm) || p.windAlarm
+	if !p.statePublished || alarm != p.publishedAlarm {
+		if !types.Publish(p.eventChan, types.Event{Type: types.AlarmEvent, Data: alarm}) {
+			return
+		}
+		p.publishedAlarm = alarm
+	}
+
+	if !p.statePublished || !sameLeds(p.leds, p.publishedLeds) {
+		leds := copyLeds(p.leds)
+		if !types.Publish(p.eventChan, types.Event{Type: types.DashboardEvent, Data: leds}) {
+			return
+		}
+		p.publishedLeds = leds
+	}
+
+	p.statePublished = true
+}
+
+func copyLeds(leds map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(leds))
+	for k, v := range leds {
+		c[k] = v
+	}
+	return c
+}
+
+func sameLeds(a, b map[string]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
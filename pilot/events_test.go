@@ -0,0 +1,101 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-12 22:17:40
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-12 22:17:40
+ */
+
+package pilot
+
+import (
+	"testing"
+
+	"github.com/ssoudan/edisonIsThePilot/dashboard"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func drainEvents(c chan interface{}) []types.Event {
+	events := []types.Event{}
+	for {
+		select {
+		case m := <-c:
+			events = append(events, m.(types.Event))
+		default:
+			return events
+		}
+	}
+}
+
+func eventTypes(events []types.Event) []string {
+	t := []string{}
+	for _, e := range events {
+		t = append(t, e.Type)
+	}
+	return t
+}
+
+func TestThatStateChangesArePublished(t *testing.T) {
+	eventChan := make(chan interface{}, 10)
+
+	pilot := Pilot{
+		leds:      map[string]bool{dashboard.NoGPSFix: false},
+		eventChan: eventChan,
+	}
+
+	pilot.publishState()
+	events := drainEvents(eventChan)
+	assert.Equal(t, []string{types.AutopilotEvent, types.AlarmEvent, types.DashboardEvent}, eventTypes(events), "first state is published")
+	assert.Equal(t, false, events[1].Data)
+	assert.Equal(t, map[string]bool{dashboard.NoGPSFix: false}, events[2].Data)
+
+	pilot.publishState()
+	assert.Equal(t, []string{types.AutopilotEvent}, eventTypes(drainEvents(eventChan)), "unchanged alarm and LEDs are not published")
+
+	pilot.alarm = RAISED
+	pilot.leds[dashboard.NoGPSFix] = true
+	pilot.publishState()
+	events = drainEvents(eventChan)
+	assert.Equal(t, []string{types.AutopilotEvent, types.AlarmEvent, types.DashboardEvent}, eventTypes(events))
+	assert.Equal(t, true, events[1].Data)
+	assert.Equal(t, map[string]bool{dashboard.NoGPSFix: true}, events[2].Data)
+
+	pilot.leds[dashboard.NoGPSFix] = false
+	assert.Equal(t, true, events[2].Data.(map[string]bool)[dashboard.NoGPSFix], "published LEDs are a copy")
+}
+
+func TestThatPublishingNeverBlocks(t *testing.T) {
+	eventChan := make(chan interface{}, 1)
+
+	pilot := Pilot{
+		leds:      map[string]bool{dashboard.NoGPSFix: false},
+		eventChan: eventChan,
+	}
+
+	// only the pilot info fits
+	pilot.publishState()
+	assert.Equal(t, []string{types.AutopilotEvent}, eventTypes(drainEvents(eventChan)))
+
+	// the dropped changes are published again
+	eventChan = make(chan interface{}, 10)
+	pilot.eventChan = eventChan
+	pilot.publishState()
+	assert.Equal(t, []string{types.AutopilotEvent, types.AlarmEvent, types.DashboardEvent}, eventTypes(drainEvents(eventChan)))
+}
@@ -18,17 +18,21 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-20 09:58:02
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 12:48:18
+* @Last Modified time: 2015-12-01 21:12:40
  */
 
 package pilot
 
 import (
+	"math"
+
 	"github.com/ssoudan/edisonIsThePilot/alarm"
 	"github.com/ssoudan/edisonIsThePilot/conf"
 	"github.com/ssoudan/edisonIsThePilot/dashboard"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
 	"github.com/ssoudan/edisonIsThePilot/steering"
+	"github.com/ssoudan/edisonIsThePilot/tracer"
 	"time"
 )
 
@@ -40,6 +44,7 @@ type Pilot struct {
 	heading       float64 // target heading (set point)
 	headingOffset float64
 	bound         float64
+	minimumSpeed  float64 // speed (in knots) below which the alarm is raised
 	course        float64
 	speed         float64
 
@@ -50,11 +55,41 @@ type Pilot struct {
 	leds map[string]bool
 	pid  Controller
 
+	// response level
+	response         Response
+	activeResponse   Response
+	profile          responseProfile
+	headingSamples   []headingSample
+	lastCorrection   time.Time
+	minOutput        float64 // configured output limits of the controller
+	maxOutput        float64
+	derivativeFilter float64 // configured derivative filter coefficient of the controller
+
+	tack *tackManoeuvre // tack in progress if any
+
+	// wind vane mode
+	mode         Mode
+	windAngle    float64 // target apparent wind angle (set point)
+	windSet      bool
+	apparentWind float64
+	windSpeed    float64
+	lastWind     time.Time // time of the last valid wind message
+	windAlarm    bool      // raised when we fell back to heading hold
+
+	// last state successfully published on eventChan
+	statePublished bool
+	publishedAlarm bool
+	publishedLeds  map[string]bool
+
+	droppedPoints uint64 // points the tracer was too busy to take
+
 	// channels with the other components
 	dashboardChan chan interface{}
 	inputChan     chan interface{}
 	alarmChan     chan interface{}
 	steeringChan  chan interface{}
+	tracerChan    chan interface{}
+	eventChan     chan interface{}
 	shutdownChan  chan interface{}
 	panicChan     chan interface{}
 }
@@ -66,6 +101,11 @@ type Controller interface {
 	OutputLimits() (float64, float64)
 }
 
+// Inspectable is a Controller that can tell the contributions of its terms to the last output
+type Inspectable interface {
+	Contributions() (proportional, integral, derivative float64)
+}
+
 // Leds is the state of all the LED (errors/warnings)
 type Leds map[Led]bool
 
@@ -97,11 +137,22 @@ func ComputeHeadingError(heading float64, gpsHeading float64, headingOffset floa
 // New creates a new Pilot from a particular controller.
 func New(controller Controller, bound float64) *Pilot {
 
-	return &Pilot{
+	p := &Pilot{
 		leds:         make(map[string]bool),
 		bound:        bound,
+		minimumSpeed: conf.Conf.MinimumSpeedInKnots,
 		pid:          controller,
+		mode:         Compass,
 		shutdownChan: make(chan interface{})}
+
+	p.minOutput, p.maxOutput = controller.OutputLimits()
+	if t, ok := controller.(Tunable); ok {
+		p.derivativeFilter = t.DerivativeFilter()
+	}
+	p.response = Normal
+	p.applyResponse(Normal)
+
+	return p
 }
 
 // SetDashboardChan sets the channel to reach teh dashboard
@@ -124,6 +175,16 @@ func (p *Pilot) SetSteeringChan(c chan interface{}) {
 	p.steeringChan = c
 }
 
+// SetTracerChan sets the channel where the pilot send the trace points - see tracer.InputBufferSize
+func (p *Pilot) SetTracerChan(c chan interface{}) {
+	p.tracerChan = c
+}
+
+// SetEventChan sets the channel where the pilot publishes its state changes for the live clients
+func (p *Pilot) SetEventChan(c chan interface{}) {
+	p.eventChan = c
+}
+
 // SetPanicChan sets the channel where the panic message have to be sent
 func (p *Pilot) SetPanicChan(c chan interface{}) {
 	p.panicChan = c
@@ -141,34 +202,71 @@ func (p *Pilot) updateFixStatus(fix FixStatus) {
 	p.leds[dashboard.NoGPSFix] = fixLed
 }
 
-func (p Pilot) tellTheWorld() {
+func (p *Pilot) tellTheWorld() {
 	// Keep the alarm first - so at least we get notified something is wrong
-	p.alarmChan <- alarm.NewMessage(bool(p.alarm))
+	p.alarmChan <- alarm.NewMessage(bool(p.alarm) || p.windAlarm)
 	p.dashboardChan <- dashboard.NewMessage(p.leds)
+
+	p.publishState()
+}
+
+// changeSetPoint changes the heading set point - every change goes through here to be logged
+func (p *Pilot) changeSetPoint(heading float64, reason string) {
+	heading = math.Mod(heading, 360)
+	if heading < 0 {
+		heading += 360
+	}
+
+	log.Notice("Setpoint changed from %v to %v (%s)", p.heading, heading, reason)
+	p.heading = heading
 }
 
 func (p *Pilot) updateFeedback(gpsHeading GPSFeedBackAction) {
 
+	now := time.Now()
+
 	p.course = gpsHeading.Heading
 	p.speed = gpsHeading.Speed
 
+	if gpsHeading.Validity {
+		p.recordHeading(now, gpsHeading.Heading)
+	}
+
+	// fall back to heading hold if we lost the wind
+	p.checkWindData(now)
+
 	// Set the heading with the current GPS heading if it has not been set before
 	if p.enabled && !p.headingSet && gpsHeading.Validity {
-		log.Info("Heading to %v", gpsHeading.Heading)
-		p.heading = gpsHeading.Heading
+		p.changeSetPoint(gpsHeading.Heading, "captured the current course")
 		p.pid.Set(0) // Reference is always 0 for us
 		p.headingSet = true
 	}
 
+	// swing the setpoint if we are tacking
+	p.advanceTack(now)
+
 	// check the validity of the message validity of the gps message
 	validityAlarm := checkValidityError(gpsHeading.Validity)
 
 	// check the speed
-	speedAlarm := checkSpeedError(gpsHeading.Speed)
+	speedAlarm := checkSpeedError(gpsHeading.Speed, p.minimumSpeed)
+
+	headingError := p.processError(gpsHeading)
 
-	headingError := ComputeHeadingError(p.heading, gpsHeading.Heading, p.headingOffset)
+	// the heading error is expected to be out of bounds during a tack
+	tacking := p.settleTack(now, headingError)
 
-	headingAlarm := !validityAlarm && !speedAlarm && p.checkHeadingError(headingError)
+	headingAlarm := !validityAlarm && !speedAlarm && !Alarm(tacking) && p.checkHeadingError(headingError)
+
+	point := types.Point{
+		Latitude:     float64(gpsHeading.Latitude),
+		Longitude:    float64(gpsHeading.Longitude),
+		Time:         types.JSONTime(now),
+		Course:       gpsHeading.Heading,
+		Speed:        gpsHeading.Speed,
+		SetPoint:     p.heading,
+		HeadingError: headingError,
+	}
 
 	/////////////////////////
 	// Update pilot state from previous checks
@@ -193,11 +291,19 @@ func (p *Pilot) updateFeedback(gpsHeading GPSFeedBackAction) {
 			p.leds[dashboard.SpeedTooLow] = true
 		}
 
-		headingControl := p.pid.Update(headingError)
+		headingControl := p.pid.Update(applyDeadBand(headingError, p.profile.deadBand))
+		point.PIDOutput = headingControl
+		if i, ok := p.pid.(Inspectable); ok {
+			point.Proportional, point.Integral, point.Derivative = i.Contributions()
+		}
 
 		steeringEnabled := p.computeSteeringState()
 
-		if steeringEnabled {
+		if steeringEnabled && p.profile.correctionPeriod > 0 && time.Since(p.lastCorrection) < p.profile.correctionPeriod {
+			log.Notice("Steering Enabled - holding until next correction")
+			p.steeringChan <- steering.NewMessage(0, true)
+			point.SteeringEnabled = true
+		} else if steeringEnabled {
 			log.Notice("Heading control is %v", headingControl)
 
 			log.Notice("Steering Enabled")
@@ -209,6 +315,9 @@ func (p *Pilot) updateFeedback(gpsHeading GPSFeedBackAction) {
 			}
 
 			p.steeringChan <- steering.NewMessage(headingControl, true)
+			p.lastCorrection = time.Now()
+			point.Steering = headingControl
+			point.SteeringEnabled = true
 
 		} else {
 			log.Notice("Steering Disabled")
@@ -233,6 +342,33 @@ func (p *Pilot) updateFeedback(gpsHeading GPSFeedBackAction) {
 		// </This section is updated when the pilot is not enabled>
 		////////////////////////
 	}
+
+	if gpsHeading.Validity {
+		p.trace(point)
+	}
+}
+
+// trace sends the point with the alarm and LED states to the tracer
+func (p *Pilot) trace(point types.Point) {
+	if p.tracerChan == nil && p.eventChan == nil {
+		return
+	}
+
+	point.Alarm = bool(p.alarm) || p.windAlarm
+	point.Leds = copyLeds(p.leds)
+
+	if p.tracerChan != nil {
+		// never wait for the tracer: it may be busy writing the track on a slow storage
+		select {
+		case p.tracerChan <- tracer.MkAddPointMessage(point):
+		default:
+			p.droppedPoints++
+			log.Warning("Tracer is busy, point dropped (%d so far)", p.droppedPoints)
+		}
+	}
+	if p.eventChan != nil {
+		types.Publish(p.eventChan, types.Event{Type: types.PointEvent, Data: point})
+	}
 }
 
 func (p *Pilot) updateAfterTimeout() {
@@ -252,6 +388,12 @@ func (p *Pilot) updateAfterError() {
 	}
 }
 
+func (p *Pilot) updateAfterMotorFault() {
+	// the steering has already disabled the motor
+	p.leds[dashboard.MotorFault] = true
+	p.updateAfterError()
+}
+
 // Start the event loop of the Pilot component
 func (p Pilot) Start() {
 
@@ -279,6 +421,25 @@ func (p Pilot) Start() {
 					p.getInfoAction(m.backChannel)
 				case setOffsetAction:
 					p.setOffset(m.headingOffset)
+				case setResponseAction:
+					p.setResponse(m.response)
+				case configureAction:
+					p.configure(m.configuration)
+				case adjustHeadingAction:
+					p.adjustHeading(m.delta)
+				case setHeadingAction:
+					p.setHeading(m.heading)
+				case recaptureHeadingAction:
+					p.recaptureHeading()
+				case tackAction:
+					p.startTack(time.Now(), m.side)
+				case WindFeedBackAction:
+					p.updateWind(time.Now(), m)
+				case setModeAction:
+					p.setMode(time.Now(), m.mode)
+				case steering.Fault:
+					log.Error("Motor fault: %v", m)
+					p.updateAfterMotorFault()
 				case error:
 					log.Error("Received an error: %v", m)
 					p.updateAfterError()
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-20 09:58:18
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 14:25:52
+* @Last Modified time: 2015-12-01 21:12:40
  */
 
 package pilot
@@ -28,6 +28,7 @@ import (
 	"testing"
 
 	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/dashboard"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -327,6 +328,20 @@ type headingCase struct {
 	description   string
 }
 
+func TestThatAMotorFaultRaisesTheAlarmUntilThePilotIsDisabled(t *testing.T) {
+	pilot := newTackingPilot()
+	pilot.enable()
+
+	pilot.updateAfterMotorFault()
+	assert.EqualValues(t, RAISED, pilot.alarm, "alarm is raised")
+	assert.EqualValues(t, true, pilot.leds[dashboard.MotorFault], "motor fault LED is on")
+	assert.EqualValues(t, false, pilot.computeSteeringState(), "steering is disabled")
+
+	pilot.disable()
+	assert.EqualValues(t, UNRAISED, pilot.alarm, "disabling the pilot resets the alarm")
+	assert.EqualValues(t, false, pilot.leds[dashboard.MotorFault], "disabling the pilot resets the motor fault LED")
+}
+
 func checkHeadingCase(t *testing.T, c headingCase) {
 
 	pilot := Pilot{heading: c.heading}
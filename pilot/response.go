@@ -0,0 +1,189 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-02 09:41:17
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-02 11:32:08
+ */
+
+package pilot
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+)
+
+// Response is the response level of the pilot
+type Response string
+
+const (
+	// Economy response ignores the small heading errors and corrects less often -- for rough seas
+	Economy Response = "economy"
+	// Normal response
+	Normal Response = "normal"
+	// Performance response holds the heading as tightly as possible
+	Performance Response = "performance"
+	// Auto response selects one of the other profiles from the measured heading variance
+	Auto Response = "auto"
+)
+
+const (
+	// heading standard deviation (in degree) above which the sea is considered rough
+	roughSeaHeadingDeviation = 10.
+	// heading standard deviation (in degree) below which the sea is considered calm
+	calmSeaHeadingDeviation = 3.
+	// minimum number of samples before Auto response selects a profile
+	minHeadingSamples = 10
+)
+
+// Tunable is a Controller whose output limits and derivative filter can be changed at runtime
+type Tunable interface {
+	SetOutputLimits(minOutput, maxOutput float64)
+	DerivativeFilter() float64
+	SetDerivativeFilter(n float64)
+}
+
+// responseProfile are the settings changed together by a Response
+type responseProfile struct {
+	deadBand              float64       // heading error (in degree) below which no correction is computed
+	outputLimitsRatio     float64       // ratio applied to the configured output limits of the controller
+	derivativeFilterRatio float64       // ratio applied to the configured derivative filter coefficient
+	correctionPeriod      time.Duration // minimum duration between two corrections
+}
+
+var responseProfiles = map[Response]responseProfile{
+	Economy:     {deadBand: 5, outputLimitsRatio: 0.5, derivativeFilterRatio: 0.5, correctionPeriod: 5 * time.Second},
+	Normal:      {deadBand: 0, outputLimitsRatio: 1, derivativeFilterRatio: 1, correctionPeriod: 0},
+	Performance: {deadBand: 0, outputLimitsRatio: 1, derivativeFilterRatio: 1.5, correctionPeriod: 0},
+}
+
+type headingSample struct {
+	time   time.Time
+	course float64
+}
+
+type setResponseAction struct {
+	response Response
+}
+
+// ValidateResponse checks the response level is a known one
+func ValidateResponse(response Response) error {
+	if _, ok := responseProfiles[response]; !ok && response != Auto {
+		return fmt.Errorf("unknown response level: %s", response)
+	}
+	return nil
+}
+
+// SetResponse changes the response level of the pilot
+func (p *Pilot) SetResponse(response Response) error {
+	if err := ValidateResponse(response); err != nil {
+		return err
+	}
+	p.inputChan <- setResponseAction{response: response}
+	return nil
+}
+
+func (p *Pilot) setResponse(response Response) {
+	log.Notice("Response level changed from %v to %v", p.response, response)
+	p.response = response
+
+	if response == Auto {
+		// start from the normal profile until we have enough samples
+		p.applyResponse(Normal)
+		return
+	}
+	p.applyResponse(response)
+}
+
+// applyResponse makes the profile of a (non Auto) response the active one
+func (p *Pilot) applyResponse(response Response) {
+	profile := responseProfiles[response]
+	p.activeResponse = response
+	p.profile = profile
+
+	if t, ok := p.pid.(Tunable); ok {
+		t.SetOutputLimits(p.minOutput*profile.outputLimitsRatio, p.maxOutput*profile.outputLimitsRatio)
+		t.SetDerivativeFilter(p.derivativeFilter * profile.derivativeFilterRatio)
+	}
+}
+
+// recordHeading keeps the course samples of the last ResponseAutoWindowInMinutes minutes
+// and selects the profile to use when the response is Auto
+func (p *Pilot) recordHeading(now time.Time, course float64) {
+	window := time.Duration(conf.Conf.ResponseAutoWindowInMinutes) * time.Minute
+
+	p.headingSamples = append(p.headingSamples, headingSample{time: now, course: course})
+	i := 0
+	for i < len(p.headingSamples) && now.Sub(p.headingSamples[i].time) > window {
+		i++
+	}
+	p.headingSamples = p.headingSamples[i:]
+
+	if p.response != Auto || len(p.headingSamples) < minHeadingSamples {
+		return
+	}
+
+	selected := selectResponse(headingDeviation(p.headingSamples))
+	if selected != p.activeResponse {
+		log.Notice("Auto response selects %v profile", selected)
+		p.applyResponse(selected)
+	}
+}
+
+// headingDeviation returns the circular standard deviation (in degree) of the course samples
+func headingDeviation(samples []headingSample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+
+	var s, c float64
+	for _, sample := range samples {
+		sin, cos := math.Sincos(sample.course * math.Pi / 180)
+		s += sin
+		c += cos
+	}
+	n := float64(len(samples))
+	r := math.Hypot(s/n, c/n)
+	if r >= 1 {
+		return 0
+	}
+
+	return math.Sqrt(-2*math.Log(r)) * 180 / math.Pi
+}
+
+func selectResponse(deviation float64) Response {
+	switch {
+	case deviation >= roughSeaHeadingDeviation:
+		return Economy
+	case deviation <= calmSeaHeadingDeviation:
+		return Performance
+	default:
+		return Normal
+	}
+}
+
+// applyDeadBand returns 0 when the heading error is within the dead-band
+func applyDeadBand(headingError, deadBand float64) float64 {
+	if math.Abs(headingError) < deadBand {
+		return 0
+	}
+	return headingError
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-02 11:05:52
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-02 11:32:08
+ */
+
+package pilot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tunableController struct {
+	testController
+	minOutput float64
+	maxOutput float64
+	n         float64
+}
+
+func (c *tunableController) SetOutputLimits(minOutput, maxOutput float64) {
+	c.minOutput = minOutput
+	c.maxOutput = maxOutput
+}
+
+func (c tunableController) DerivativeFilter() float64 {
+	return c.n
+}
+
+func (c *tunableController) SetDerivativeFilter(n float64) {
+	c.n = n
+}
+
+func (c tunableController) OutputLimits() (float64, float64) {
+	return c.minOutput, c.maxOutput
+}
+
+func TestThatDeadBandIgnoresSmallHeadingErrors(t *testing.T) {
+	assert.EqualValues(t, 0., applyDeadBand(2., 5.), "error within the dead-band is ignored")
+	assert.EqualValues(t, 0., applyDeadBand(-2., 5.), "error within the dead-band is ignored")
+	assert.EqualValues(t, 6., applyDeadBand(6., 5.), "error out of the dead-band is unchanged")
+	assert.EqualValues(t, -6., applyDeadBand(-6., 5.), "error out of the dead-band is unchanged")
+	assert.EqualValues(t, 0.5, applyDeadBand(0.5, 0.), "no dead-band")
+}
+
+func TestThatResponseProfileIsAppliedToTheController(t *testing.T) {
+	controller := &tunableController{minOutput: -100, maxOutput: 100, n: 2}
+
+	pilot := New(controller, 45)
+
+	assert.EqualValues(t, Normal, pilot.response, "response is normal by default")
+	assert.EqualValues(t, -100, controller.minOutput, "normal response keeps the configured limits")
+	assert.EqualValues(t, 100, controller.maxOutput, "normal response keeps the configured limits")
+	assert.EqualValues(t, 2, controller.n, "normal response keeps the configured derivative filter")
+
+	pilot.setResponse(Economy)
+
+	assert.EqualValues(t, -50, controller.minOutput, "economy response reduces the limits")
+	assert.EqualValues(t, 50, controller.maxOutput, "economy response reduces the limits")
+	assert.EqualValues(t, 1, controller.n, "economy response filters more the derivative")
+	assert.EqualValues(t, responseProfiles[Economy].deadBand, pilot.profile.deadBand)
+
+	pilot.setResponse(Normal)
+
+	assert.EqualValues(t, -100, controller.minOutput, "limits are restored")
+	assert.EqualValues(t, 100, controller.maxOutput, "limits are restored")
+	assert.EqualValues(t, 2, controller.n, "derivative filter is restored")
+}
+
+func TestThatUnknownResponseIsRejected(t *testing.T) {
+	pilot := New(&testController{}, 45)
+
+	assert.Error(t, pilot.SetResponse(Response("sporty")))
+}
+
+func TestHeadingDeviation(t *testing.T) {
+	constant := []headingSample{{course: 10}, {course: 10}, {course: 10}}
+	assert.InDelta(t, 0., headingDeviation(constant), 1e-6, "no deviation for a constant course")
+
+	acrossNorth := []headingSample{{course: 355}, {course: 5}, {course: 355}, {course: 5}}
+	assert.InDelta(t, 5., headingDeviation(acrossNorth), 0.1, "deviation is computed across North")
+
+	assert.EqualValues(t, 0., headingDeviation([]headingSample{}))
+}
+
+func TestThatAutoResponseSelectsTheProfileFromTheHeadingDeviation(t *testing.T) {
+	controller := &tunableController{minOutput: -100, maxOutput: 100, n: 2}
+	pilot := New(controller, 45)
+	pilot.setResponse(Auto)
+
+	assert.EqualValues(t, Normal, pilot.activeResponse, "auto starts with the normal profile")
+
+	now := time.Now()
+	for i := 0; i < minHeadingSamples; i++ {
+		pilot.recordHeading(now.Add(time.Duration(i)*time.Second), 90.+30.*float64(i%2))
+	}
+
+	assert.EqualValues(t, Economy, pilot.activeResponse, "rough sea selects the economy profile")
+	assert.EqualValues(t, -50, controller.minOutput)
+
+	later := now.Add(time.Duration(conf.Conf.ResponseAutoWindowInMinutes)*time.Minute + time.Hour)
+	for i := 0; i < minHeadingSamples; i++ {
+		pilot.recordHeading(later.Add(time.Duration(i)*time.Second), 90.)
+	}
+
+	assert.Len(t, pilot.headingSamples, minHeadingSamples, "old samples are dropped")
+	assert.EqualValues(t, Performance, pilot.activeResponse, "calm sea selects the performance profile")
+}
+
+func TestThatCorrectionsAreHeldDuringTheCorrectionPeriod(t *testing.T) {
+
+	steeringChan := make(chan interface{}, 10)
+	c := make(chan interface{})
+
+	go func() {
+		for {
+			<-c
+		}
+	}()
+
+	pilot := New(&testController{}, 45)
+	pilot.SetDashboardChan(c)
+	pilot.SetAlarmChan(c)
+	pilot.SetSteeringChan(steeringChan)
+	pilot.setResponse(Economy)
+
+	pilot.enable()
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 180., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.EqualValues(t, "{2 true}", fmt.Sprintf("%v", <-steeringChan), "first correction is applied")
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 170., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.EqualValues(t, "{0 true}", fmt.Sprintf("%v", <-steeringChan), "next correction is held")
+
+	pilot.lastCorrection = time.Now().Add(-responseProfiles[Economy].correctionPeriod)
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 170., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.EqualValues(t, "{2 true}", fmt.Sprintf("%v", <-steeringChan), "correction is applied after the correction period")
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-05 22:03:51
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-06 21:27:10
+ */
+
+package pilot
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+)
+
+// Side is the side toward which the boat turns during a tack or a gybe
+type Side string
+
+const (
+	// Port turns the boat to port (the setpoint decreases)
+	Port = Side("port")
+	// Starboard turns the boat to starboard (the setpoint increases)
+	Starboard = Side("starboard")
+)
+
+// tackManoeuvre is a tack (or a gybe) in progress
+type tackManoeuvre struct {
+	direction float64 // +1 to starboard, -1 to port
+	remaining float64 // angle (in degree) the setpoint still has to swing through
+	started   time.Time
+	lastStep  time.Time
+}
+
+type tackAction struct {
+	side Side
+}
+
+// NewTackMessage creates a message to tack (or gybe) toward side
+func NewTackMessage(side Side) interface{} {
+	return tackAction{side: side}
+}
+
+// Tack swings the setpoint through the configured tack angle toward side
+func (p *Pilot) Tack(side Side) error {
+	switch side {
+	case Port, Starboard:
+		p.inputChan <- NewTackMessage(side)
+		return nil
+	}
+	return fmt.Errorf("unknown side: %v", side)
+}
+
+func (p *Pilot) startTack(now time.Time, side Side) {
+	if !p.enabled || !p.headingSet {
+		log.Warning("Ignoring tack to %v: no heading is held", side)
+		return
+	}
+	if p.mode == WindVane {
+		log.Warning("Ignoring tack to %v: not supported in %v mode", side, p.mode)
+		return
+	}
+
+	direction := 1.
+	if side == Port {
+		direction = -1.
+	}
+
+	log.Notice("Tacking to %v from %v", side, p.heading)
+	p.tack = &tackManoeuvre{
+		direction: direction,
+		remaining: conf.Conf.TackAngleInDegrees,
+		started:   now,
+		lastStep:  now,
+	}
+}
+
+func (p *Pilot) cancelTack(reason string) {
+	if p.tack != nil {
+		log.Notice("Tack cancelled: %s", reason)
+		p.tack = nil
+	}
+}
+
+// advanceTack swings the setpoint at the configured turn rate until the whole tack angle has been covered
+func (p *Pilot) advanceTack(now time.Time) {
+	t := p.tack
+	if t == nil || t.remaining <= 0 {
+		return
+	}
+
+	step := t.remaining
+	if rate := conf.Conf.TackTurnRateInDegreesPerSecond; rate > 0 {
+		step = math.Min(rate*now.Sub(t.lastStep).Seconds(), t.remaining)
+	}
+	t.lastStep = now
+	if step <= 0 {
+		return
+	}
+
+	t.remaining -= step
+	p.changeSetPoint(p.heading+t.direction*step, "tacking")
+}
+
+// settleTack tells whether the tack is still in progress - in which case the heading error alarm is suppressed.
+// The tack ends once the setpoint has reached its target and the heading error is back within bounds
+// or when it did not complete in time.
+func (p *Pilot) settleTack(now time.Time, headingError float64) bool {
+	t := p.tack
+	if t == nil {
+		return false
+	}
+
+	if t.remaining <= 0 && validateInput(p.bound, headingError) == VALID {
+		log.Notice("Tack completed - holding %v", p.heading)
+		p.tack = nil
+		return false
+	}
+
+	timeout := time.Duration(conf.Conf.TackTimeoutInSeconds) * time.Second
+	if now.Sub(t.started) > timeout {
+		log.Warning("Tack did not complete within %v - resuming heading hold", timeout)
+		p.tack = nil
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-05 22:03:51
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-07 20:14:37
+ */
+
+package pilot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTackingPilot() *Pilot {
+	c := make(chan interface{})
+
+	go func() {
+		for {
+			<-c
+		}
+	}()
+
+	return &Pilot{
+		alarm:         UNRAISED,
+		bound:         25.,
+		leds:          make(map[string]bool),
+		dashboardChan: c,
+		alarmChan:     c,
+		steeringChan:  c,
+		inputChan:     make(chan interface{}),
+		pid:           &testController{}}
+}
+
+func TestThatTackIsIgnoredWhenNoHeadingIsHeld(t *testing.T) {
+	pilot := newTackingPilot()
+
+	pilot.startTack(time.Now(), Port)
+	assert.Nil(t, pilot.tack, "pilot is disabled")
+
+	pilot.enable()
+	pilot.startTack(time.Now(), Port)
+	assert.Nil(t, pilot.tack, "heading is not set yet")
+}
+
+func TestThatTackSwingsTheSetpointAtTheTurnRate(t *testing.T) {
+	pilot := newTackingPilot()
+	pilot.enable()
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 10., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+
+	now := time.Now()
+	pilot.startTack(now, Port)
+	assert.NotNil(t, pilot.tack, "tack has started")
+
+	now = now.Add(time.Second)
+	pilot.advanceTack(now)
+	assert.InDelta(t, 10.-conf.Conf.TackTurnRateInDegreesPerSecond, pilot.heading, 1e-9, "setpoint moved by one second worth of turn")
+
+	duration := time.Duration(conf.Conf.TackAngleInDegrees/conf.Conf.TackTurnRateInDegreesPerSecond) * time.Second
+	pilot.advanceTack(now.Add(2 * duration))
+	assert.InDelta(t, 270., pilot.heading, 1e-9, "setpoint does not go beyond the tack angle")
+	assert.EqualValues(t, 0., pilot.tack.remaining, "the whole angle has been covered")
+}
+
+func TestThatTackEndsOnceWithinBounds(t *testing.T) {
+	pilot := newTackingPilot()
+	pilot.enable()
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 10., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+
+	now := time.Now()
+	pilot.startTack(now, Starboard)
+
+	assert.True(t, pilot.settleTack(now, 50.), "still turning")
+
+	pilot.tack.remaining = 0
+	assert.True(t, pilot.settleTack(now, 50.), "setpoint reached but heading error is out of bounds")
+	assert.False(t, pilot.settleTack(now, 5.), "heading error back within bounds")
+	assert.Nil(t, pilot.tack, "tack is completed")
+
+	pilot.startTack(now, Starboard)
+	timeout := time.Duration(conf.Conf.TackTimeoutInSeconds) * time.Second
+	assert.False(t, pilot.settleTack(now.Add(timeout+time.Second), 50.), "tack timed out")
+	assert.Nil(t, pilot.tack, "tack is abandoned")
+}
+
+func TestThatHeadingAlarmIsSuppressedDuringTack(t *testing.T) {
+	pilot := newTackingPilot()
+	pilot.enable()
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 10., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+
+	pilot.startTack(time.Now(), Starboard)
+	pilot.tack.remaining = 0
+	pilot.changeSetPoint(110., "test")
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 40., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.EqualValues(t, UNRAISED, pilot.alarm, "heading error alarm is suppressed during the tack")
+	assert.NotNil(t, pilot.tack, "pilot is still tacking")
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 105., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.Nil(t, pilot.tack, "tack is completed")
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 40., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.EqualValues(t, RAISED, pilot.alarm, "heading error alarm is back")
+}
+
+func TestThatSetpointChangesCancelTheTack(t *testing.T) {
+	pilot := newTackingPilot()
+	pilot.enable()
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 10., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+
+	pilot.startTack(time.Now(), Port)
+	pilot.adjustHeading(SmallHeadingAdjustment)
+	assert.Nil(t, pilot.tack, "adjustment cancels the tack")
+
+	pilot.startTack(time.Now(), Port)
+	pilot.disable()
+	assert.Nil(t, pilot.tack, "disabling the pilot cancels the tack")
+
+	pilot.enable()
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 10., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	pilot.startTack(time.Now(), Port)
+	pilot.enable()
+	assert.Nil(t, pilot.tack, "enabling the pilot again cancels the tack")
+}
+
+func TestThatTackRejectsUnknownSide(t *testing.T) {
+	pilot := Pilot{inputChan: make(chan interface{}, 1)}
+
+	assert.NotNil(t, pilot.Tack(Side("aft")), "unknown side")
+	assert.Nil(t, pilot.Tack(Starboard))
+	assert.Equal(t, NewTackMessage(Starboard), <-pilot.inputChan)
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-10 20:48:26
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-12 18:26:49
+ */
+
+package pilot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/dashboard"
+	"github.com/ssoudan/edisonIsThePilot/tracer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type inspectableController struct {
+	testController
+}
+
+func (c inspectableController) Contributions() (float64, float64, float64) {
+	return 1.5, 0.25, 0.25
+}
+
+func TestThatFeedbackIsTraced(t *testing.T) {
+	c := make(chan interface{})
+
+	go func() {
+		for {
+			<-c
+		}
+	}()
+
+	tracerChan := make(chan interface{}, tracer.InputBufferSize)
+	theTracer := tracer.New(10)
+	theTracer.SetInputChan(tracerChan)
+	theTracer.Start()
+	defer theTracer.Shutdown()
+
+	pilot := Pilot{
+		alarm:         UNRAISED,
+		bound:         25.,
+		leds:          make(map[string]bool),
+		dashboardChan: c,
+		alarmChan:     c,
+		steeringChan:  c,
+		tracerChan:    tracerChan,
+		inputChan:     make(chan interface{}),
+		pid:           &inspectableController{}}
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 10., Validity: false, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.Equal(t, 0, len(theTracer.GetPoints()), "invalid fixes are not traced")
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 10., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1, Latitude: 45., Longitude: 4.})
+	points := theTracer.GetPoints()
+	assert.Equal(t, 1, len(points), "valid fixes are traced")
+	assert.EqualValues(t, 45., points[0].Latitude)
+	assert.EqualValues(t, 4., points[0].Longitude)
+	assert.EqualValues(t, 10., points[0].Course)
+	assert.EqualValues(t, false, points[0].SteeringEnabled, "pilot is disabled")
+	assert.EqualValues(t, 0., points[0].PIDOutput, "pilot is disabled")
+
+	pilot.enable()
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 10., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 15., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+
+	points = theTracer.GetPoints()
+	assert.Equal(t, 3, len(points))
+	last := points[2]
+	assert.EqualValues(t, 10., last.SetPoint)
+	assert.EqualValues(t, 15., last.Course)
+	assert.EqualValues(t, 5., last.HeadingError)
+	assert.EqualValues(t, 2., last.PIDOutput)
+	assert.EqualValues(t, 1.5, last.Proportional)
+	assert.EqualValues(t, 0.25, last.Integral)
+	assert.EqualValues(t, 0.25, last.Derivative)
+	assert.EqualValues(t, 2., last.Steering)
+	assert.EqualValues(t, true, last.SteeringEnabled)
+	assert.EqualValues(t, false, last.Alarm)
+	assert.EqualValues(t, false, last.Leds[dashboard.HeadingErrorOutOfBounds])
+	assert.WithinDuration(t, time.Now(), time.Time(last.Time), time.Minute)
+}
+
+func TestThatTracePointsAreDroppedWhenTheTracerIsBusy(t *testing.T) {
+	c := make(chan interface{})
+
+	go func() {
+		for {
+			<-c
+		}
+	}()
+
+	// nobody reads the tracer channel
+	tracerChan := make(chan interface{}, 1)
+
+	pilot := Pilot{
+		alarm:         UNRAISED,
+		bound:         25.,
+		leds:          make(map[string]bool),
+		dashboardChan: c,
+		alarmChan:     c,
+		steeringChan:  c,
+		tracerChan:    tracerChan,
+		inputChan:     make(chan interface{}),
+		pid:           &inspectableController{}}
+
+	for i := 0; i < 3; i++ {
+		pilot.updateFeedback(GPSFeedBackAction{Heading: 10., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	}
+
+	assert.Equal(t, 1, len(tracerChan), "the first point is queued")
+	assert.EqualValues(t, 2, pilot.droppedPoints, "the others are dropped rather than blocking the pilot")
+}
@@ -18,15 +18,11 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-21 22:53:24
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-09-22 14:03:38
+* @Last Modified time: 2015-11-15 19:42:57
  */
 
 package pilot
 
-import (
-	"github.com/ssoudan/edisonIsThePilot/conf"
-)
-
-func checkSpeedError(speed float64) Alarm {
-	return Alarm(speed < conf.Conf.MinimumSpeedInKnots)
+func checkSpeedError(speed, minimumSpeed float64) Alarm {
+	return Alarm(speed < minimumSpeed)
 }
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-21 22:49:13
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-09-22 14:04:40
+* @Last Modified time: 2015-11-15 19:42:57
  */
 
 package pilot
@@ -34,7 +34,7 @@ import (
 func TestCheckSpeedErrorRaisesAnAlarmWhenTooSlow(t *testing.T) {
 
 	input := conf.Conf.MinimumSpeedInKnots * 0.9
-	alarm := checkSpeedError(input)
+	alarm := checkSpeedError(input, conf.Conf.MinimumSpeedInKnots)
 
 	expected := RAISED
 
@@ -44,7 +44,7 @@ func TestCheckSpeedErrorRaisesAnAlarmWhenTooSlow(t *testing.T) {
 func TestCheckSpeedErrorDoNotRaiseAnAlarmWhenLargeEnough(t *testing.T) {
 
 	input := conf.Conf.MinimumSpeedInKnots * 1.1
-	alarm := checkSpeedError(input)
+	alarm := checkSpeedError(input, conf.Conf.MinimumSpeedInKnots)
 
 	expected := UNRAISED
 
@@ -0,0 +1,167 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-06 21:27:10
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-06 21:27:10
+ */
+
+package pilot
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/dashboard"
+)
+
+// WindFeedBackAction is the apparent wind message provided by the GPS component
+type WindFeedBackAction struct {
+	Angle    float64 // apparent wind angle in ]-180:180] - positive when the wind comes from starboard
+	Speed    float64 // apparent wind speed in knots
+	Validity bool
+}
+
+// Mode is the process variable held by the pilot
+type Mode string
+
+const (
+	// Compass holds a heading
+	Compass = Mode("compass")
+	// WindVane holds an apparent wind angle
+	WindVane = Mode("wind")
+)
+
+type setModeAction struct {
+	mode Mode
+}
+
+// NewSetModeMessage creates a message to change the mode of the pilot
+func NewSetModeMessage(mode Mode) interface{} {
+	return setModeAction{mode: mode}
+}
+
+// ValidateMode checks the mode is a known one
+func ValidateMode(mode Mode) error {
+	switch mode {
+	case Compass, WindVane:
+		return nil
+	}
+	return fmt.Errorf("unknown mode: %v", mode)
+}
+
+// SetMode changes the mode of the pilot
+func (p *Pilot) SetMode(mode Mode) error {
+	if err := ValidateMode(mode); err != nil {
+		return err
+	}
+	p.inputChan <- NewSetModeMessage(mode)
+	return nil
+}
+
+// ComputeWindError determines the error to be passed to the Controller when holding an apparent wind angle.
+// It has the same sign as the heading error: positive when the boat has to come back to port.
+func ComputeWindError(windAngle float64, apparentWindAngle float64) float64 {
+	return normalizeAngle(windAngle - apparentWindAngle)
+}
+
+// normalizeAngle brings an angle in ]-180:180]
+func normalizeAngle(angle float64) float64 {
+	angle = math.Mod(angle, 360)
+	if angle > 180. {
+		angle -= 360.
+	}
+	if angle <= -180. {
+		angle += 360.
+	}
+	return angle
+}
+
+func (p *Pilot) setMode(now time.Time, mode Mode) {
+	if mode == p.mode {
+		return
+	}
+
+	if mode == WindVane && !p.windIsFresh(now) {
+		log.Warning("Ignoring wind vane mode: no fresh wind data")
+		return
+	}
+
+	log.Notice("Mode changed from %v to %v", p.mode, mode)
+	p.cancelTack("mode changed")
+	p.mode = mode
+	p.windAlarm = false
+	p.leds[dashboard.WindDataStale] = false
+
+	// the setpoint of the new mode is captured with the next message
+	p.windSet = false
+	p.headingSet = false
+}
+
+// changeWindSetPoint changes the apparent wind angle set point - every change goes through here to be logged
+func (p *Pilot) changeWindSetPoint(windAngle float64, reason string) {
+	windAngle = normalizeAngle(windAngle)
+
+	log.Notice("Wind angle setpoint changed from %v to %v (%s)", p.windAngle, windAngle, reason)
+	p.windAngle = windAngle
+}
+
+func (p *Pilot) windIsFresh(now time.Time) bool {
+	timeout := time.Duration(conf.Conf.WindDataTimeoutInSeconds) * time.Second
+	return !p.lastWind.IsZero() && now.Sub(p.lastWind) <= timeout
+}
+
+func (p *Pilot) updateWind(now time.Time, wind WindFeedBackAction) {
+	p.apparentWind = wind.Angle
+	p.windSpeed = wind.Speed
+
+	if !wind.Validity {
+		return
+	}
+	p.lastWind = now
+
+	// Set the wind angle with the current apparent wind angle if it has not been set before
+	if p.enabled && p.mode == WindVane && !p.windSet {
+		p.changeWindSetPoint(wind.Angle, "captured the current apparent wind angle")
+		p.windSet = true
+	}
+}
+
+// checkWindData falls back to heading hold on the current course when the wind data are stale
+func (p *Pilot) checkWindData(now time.Time) {
+	if !p.enabled || p.mode != WindVane || p.windIsFresh(now) {
+		return
+	}
+
+	log.Warning("Wind data is stale - falling back to heading hold")
+	p.mode = Compass
+	p.windSet = false
+	p.headingSet = false
+	p.windAlarm = true
+	p.leds[dashboard.WindDataStale] = true
+}
+
+// processError gives the error of the process variable held in the current mode
+func (p *Pilot) processError(gpsHeading GPSFeedBackAction) float64 {
+	if p.mode == WindVane && p.windSet {
+		return ComputeWindError(p.windAngle, p.apparentWind)
+	}
+	return ComputeHeadingError(p.heading, gpsHeading.Heading, p.headingOffset)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-06 21:27:10
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-06 21:27:10
+ */
+
+package pilot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/conf"
+	"github.com/ssoudan/edisonIsThePilot/dashboard"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeWindError(t *testing.T) {
+	assert.EqualValues(t, -10., ComputeWindError(45., 55.), "wind went aft on starboard: the boat has to come back to starboard")
+	assert.EqualValues(t, 10., ComputeWindError(-45., -55.), "wind went aft on port: the boat has to come back to port")
+	assert.EqualValues(t, -20., ComputeWindError(170., -170.), "wind error wraps around")
+	assert.EqualValues(t, 180., ComputeWindError(-90., 90.), "wind error is in ]-180:180]")
+}
+
+func newWindPilot() *Pilot {
+	pilot := newTackingPilot()
+	pilot.mode = Compass
+	return pilot
+}
+
+func TestThatWindVaneModeRequiresFreshWindData(t *testing.T) {
+	pilot := newWindPilot()
+	now := time.Now()
+
+	pilot.setMode(now, WindVane)
+	assert.EqualValues(t, Compass, pilot.mode, "no wind data yet")
+
+	pilot.updateWind(now, WindFeedBackAction{Angle: 45., Speed: 12., Validity: false})
+	pilot.setMode(now, WindVane)
+	assert.EqualValues(t, Compass, pilot.mode, "invalid wind data")
+
+	pilot.updateWind(now, WindFeedBackAction{Angle: 45., Speed: 12., Validity: true})
+	pilot.setMode(now, WindVane)
+	assert.EqualValues(t, WindVane, pilot.mode, "fresh wind data")
+}
+
+func TestThatWindAngleIsHeld(t *testing.T) {
+	pilot := newWindPilot()
+	pilot.enable()
+
+	now := time.Now()
+	pilot.updateWind(now, WindFeedBackAction{Angle: 45., Speed: 12., Validity: true})
+	pilot.setMode(now, WindVane)
+	assert.EqualValues(t, false, pilot.windSet, "wind angle is captured with the next wind message")
+
+	pilot.updateWind(now, WindFeedBackAction{Angle: 50., Speed: 12., Validity: true})
+	assert.EqualValues(t, true, pilot.windSet, "wind angle has been captured")
+	assert.EqualValues(t, 50., pilot.windAngle, "wind angle has been captured")
+
+	pilot.updateWind(now, WindFeedBackAction{Angle: 60., Speed: 12., Validity: true})
+	assert.EqualValues(t, -10., pilot.processError(GPSFeedBackAction{Heading: 10., Validity: true}), "error is on the apparent wind angle")
+
+	pilot.adjustHeading(SmallHeadingAdjustment)
+	assert.EqualValues(t, 49., pilot.windAngle, "turning to starboard brings the wind toward port")
+
+	pilot.recaptureHeading()
+	assert.EqualValues(t, false, pilot.windSet, "wind angle is recaptured with the next wind message")
+	assert.EqualValues(t, WindVane, pilot.mode, "still holding the wind")
+}
+
+func TestThatStaleWindDataFallsBackToHeadingHold(t *testing.T) {
+	pilot := newWindPilot()
+	pilot.enable()
+
+	pilot.updateWind(time.Now(), WindFeedBackAction{Angle: 45., Speed: 12., Validity: true})
+	pilot.setMode(time.Now(), WindVane)
+	pilot.updateWind(time.Now(), WindFeedBackAction{Angle: 45., Speed: 12., Validity: true})
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 10., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.EqualValues(t, WindVane, pilot.mode, "wind data is fresh")
+	assert.EqualValues(t, false, pilot.windAlarm, "wind data is fresh")
+
+	// make the wind data stale
+	pilot.lastWind = time.Now().Add(-time.Duration(conf.Conf.WindDataTimeoutInSeconds+1) * time.Second)
+
+	pilot.updateFeedback(GPSFeedBackAction{Heading: 20., Validity: true, Speed: conf.Conf.MinimumSpeedInKnots * 1.1})
+	assert.EqualValues(t, Compass, pilot.mode, "fell back to heading hold")
+	assert.EqualValues(t, true, pilot.headingSet, "heading has been captured")
+	assert.EqualValues(t, 20., pilot.heading, "heading hold on the current course")
+	assert.EqualValues(t, true, pilot.windAlarm, "wind alarm is raised")
+	assert.EqualValues(t, true, pilot.leds[dashboard.WindDataStale], "wind LED is on")
+	assert.EqualValues(t, true, pilot.computeSteeringState(), "still steering")
+
+	pilot.disable()
+	assert.EqualValues(t, false, pilot.windAlarm, "disabling the pilot resets the wind alarm")
+	assert.EqualValues(t, false, pilot.leds[dashboard.WindDataStale], "disabling the pilot resets the wind LED")
+}
+
+func TestThatSetModeRejectsUnknownMode(t *testing.T) {
+	pilot := Pilot{inputChan: make(chan interface{}, 1)}
+
+	assert.NotNil(t, pilot.SetMode(Mode("gyro")), "unknown mode")
+	assert.Nil(t, pilot.SetMode(WindVane))
+	assert.Equal(t, NewSetModeMessage(WindVane), <-pilot.inputChan)
+}
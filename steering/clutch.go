@@ -0,0 +1,73 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-03 19:27:55
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-03 19:27:55
+ */
+
+package steering
+
+import (
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+// SetClutch sets the output driving the clutch of the drive unit - the clutch is engaged while the pilot steers.
+// engageDelay is waited after the clutch is engaged before the first move, releaseDelay after it is released
+// before the actionner is disabled.
+func (m *Steering) SetClutch(clutch types.Enablable, engageDelay, releaseDelay time.Duration) {
+	m.clutch = clutch
+	m.engageDelay = engageDelay
+	m.releaseDelay = releaseDelay
+}
+
+// engageClutch engages the clutch if it is not already
+func (m *Steering) engageClutch() {
+	if m.clutch == nil || m.engaged {
+		return
+	}
+
+	if err := m.clutch.Enable(); err != nil {
+		log.Panicf("Failed to engage the clutch: %v", err)
+	}
+	m.engaged = true
+	time.Sleep(m.engageDelay)
+}
+
+// releaseClutch releases the clutch - whatever we think its state is
+func (m *Steering) releaseClutch() {
+	if m.clutch == nil {
+		return
+	}
+
+	if err := m.clutch.Disable(); err != nil {
+		log.Error("Failed to release the clutch: %v", err)
+	}
+	if m.engaged {
+		m.engaged = false
+		time.Sleep(m.releaseDelay)
+	}
+}
+
+// disengage releases the clutch and disables the actionner
+func (m *Steering) disengage() {
+	m.releaseClutch()
+	m.actionner.Disable()
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-03 19:27:55
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-03 19:27:55
+ */
+
+package steering
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClutch struct {
+	engaged bool
+	changes int
+}
+
+func (c *fakeClutch) Enable() error {
+	if !c.engaged {
+		c.changes++
+	}
+	c.engaged = true
+	return nil
+}
+
+func (c *fakeClutch) Disable() error {
+	if c.engaged {
+		c.changes++
+	}
+	c.engaged = false
+	return nil
+}
+
+func TestThatTheClutchIsEngagedWhileSteering(t *testing.T) {
+	actionner := &fakeActionner{}
+	clutch := &fakeClutch{}
+
+	s := New(actionner)
+	s.SetClutch(clutch, 20*time.Millisecond, 10*time.Millisecond)
+
+	start := time.Now()
+	s.processMessage(message{rotationInDegree: 0., stayEnabled: true})
+	assert.True(t, clutch.engaged, "engaged when steering")
+	assert.True(t, time.Since(start) >= 20*time.Millisecond, "waited for the clutch")
+
+	s.processMessage(message{rotationInDegree: 18., stayEnabled: true})
+	assert.True(t, clutch.engaged, "still engaged")
+	assert.Equal(t, 1, clutch.changes, "engaged once")
+	assert.Equal(t, 1, actionner.moves, "moved")
+
+	s.processMessage(message{rotationInDegree: 0., stayEnabled: false})
+	assert.False(t, clutch.engaged, "released when the pilot disengages")
+	assert.False(t, actionner.isEnabled(), "motor disabled")
+}
+
+func TestThatTheClutchIsReleasedOnAFault(t *testing.T) {
+	actionner := &fakeActionner{}
+	clutch := &fakeClutch{}
+
+	s := New(actionner)
+	s.SetClutch(clutch, 0, 0)
+	s.SetCurrentSensor(fakeCurrentSensor(4.), 3., 2., 300*time.Millisecond)
+
+	s.processMessage(message{rotationInDegree: 180., stayEnabled: true})
+	assert.False(t, clutch.engaged, "released on the fault")
+
+	s.processMessage(message{rotationInDegree: 0., stayEnabled: true})
+	assert.False(t, clutch.engaged, "stays released until the pilot disengages")
+}
+
+func TestThatTheClutchIsReleasedOnShutdown(t *testing.T) {
+	actionner := &fakeActionner{}
+	clutch := &fakeClutch{}
+
+	s := New(actionner)
+	s.SetClutch(clutch, 0, 0)
+	s.SetInputChan(make(chan interface{}))
+	s.Start()
+
+	clutch.engaged = true
+	s.Shutdown()
+	assert.False(t, clutch.engaged, "released")
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-01 21:12:40
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-20 17:52:08
+ */
+
+package steering
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+// monitoringPeriod is the period of the reading of the current sensor during a move
+const monitoringPeriod = 10 * time.Millisecond
+
+// minimumRudderRotationInDegree is the rotation of the rudder below which no stall is detected by the rudder sensor
+const minimumRudderRotationInDegree = 1.
+
+var (
+	// ErrOvercurrent is the fault of a motor drawing more than its maximum current
+	ErrOvercurrent = errors.New("motor overcurrent")
+	// ErrStalled is the fault of a motor which does not turn - the error returned by the actionners detecting it themselves
+	ErrStalled = types.ErrStalled
+)
+
+// Fault is the error sent to the pilot when the actionner has been disabled after a stall or an overcurrent
+type Fault struct {
+	Err error
+}
+
+func (f Fault) Error() string {
+	return f.Err.Error()
+}
+
+// CurrentSensor measures the current drawn by the actionner (in A)
+type CurrentSensor interface {
+	Current() (float64, error)
+}
+
+// RudderSensor measures the angle of the rudder (in degree)
+type RudderSensor interface {
+	RudderAngle() (float64, error)
+}
+
+// currentWatch tells when the current drawn by the actionner is too high
+type currentWatch struct {
+	overcurrent  float64 // current (in A) disabling the actionner right away
+	stallCurrent float64 // current (in A) of a stalled motor
+	stallTimeout time.Duration
+
+	stalledSince time.Time
+}
+
+// update checks a reading of the current - the motor is stalled when the current stays above stallCurrent for stallTimeout
+func (w *currentWatch) update(now time.Time, current float64) error {
+	current = math.Abs(current)
+
+	if current >= w.overcurrent {
+		return ErrOvercurrent
+	}
+
+	switch {
+	case current < w.stallCurrent:
+		w.stalledSince = time.Time{}
+	case w.stalledSince.IsZero():
+		w.stalledSince = now
+	case now.Sub(w.stalledSince) >= w.stallTimeout:
+		return ErrStalled
+	}
+	return nil
+}
+
+// monitor reads the current sensor until done is closed - the actionner is disabled as soon as a fault is detected.
+// The fault (or nil) is sent on result.
+func (m *Steering) monitor(done <-chan interface{}, result chan<- error) {
+	watch := currentWatch{overcurrent: m.overcurrent, stallCurrent: m.stallCurrent, stallTimeout: m.stallTimeout}
+
+	ticker := time.NewTicker(monitoringPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			result <- nil
+			return
+		case now := <-ticker.C:
+			current, err := m.currentSensor.Current()
+			if err != nil {
+				log.Warning("Failed to read the current of the motor: %v", err)
+				continue
+			}
+			if err := watch.update(now, current); err != nil {
+				log.Error("%v: %vA - disabling the motor", err, current)
+				m.actionner.Disable()
+				result <- err
+				return
+			}
+		}
+	}
+}
+
+// rudderStalled tells whether the rudder turned by less than half of the expected rotation
+func rudderStalled(before, after, expectedRotationInDegree float64) bool {
+	if math.Abs(expectedRotationInDegree) < minimumRudderRotationInDegree {
+		return false
+	}
+	rotation := math.Mod(after-before+540, 360) - 180
+	return rotation*expectedRotationInDegree <= 0 || math.Abs(rotation) < math.Abs(expectedRotationInDegree)/2
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-12-01 21:12:40
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-20 17:52:08
+ */
+
+package steering
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThatOvercurrentIsDetectedRightAway(t *testing.T) {
+	w := currentWatch{overcurrent: 3., stallCurrent: 2., stallTimeout: 300 * time.Millisecond}
+	now := time.Now()
+
+	assert.Nil(t, w.update(now, 1.), "nominal current")
+	assert.Equal(t, ErrOvercurrent, w.update(now, 3.5), "overcurrent")
+	assert.Equal(t, ErrOvercurrent, w.update(now, -3.5), "overcurrent in the other direction")
+}
+
+func TestThatStallIsDetectedAfterTheTimeout(t *testing.T) {
+	w := currentWatch{overcurrent: 3., stallCurrent: 2., stallTimeout: 300 * time.Millisecond}
+	now := time.Now()
+
+	assert.Nil(t, w.update(now, 2.5), "starting current")
+	assert.Nil(t, w.update(now.Add(200*time.Millisecond), 2.5), "not stalled for long enough")
+	assert.Nil(t, w.update(now.Add(250*time.Millisecond), 1.), "the motor turns")
+	assert.Nil(t, w.update(now.Add(300*time.Millisecond), 2.5), "stall timeout restarted")
+	assert.Equal(t, ErrStalled, w.update(now.Add(600*time.Millisecond), 2.5), "stalled")
+}
+
+func TestThatTheRudderHasToFollowTheMotor(t *testing.T) {
+	assert.False(t, rudderStalled(0., 9., 10.), "the rudder followed")
+	assert.False(t, rudderStalled(355., 4., 10.), "the rudder followed across 0")
+	assert.True(t, rudderStalled(0., 2., 10.), "the rudder did not follow")
+	assert.True(t, rudderStalled(0., -9., 10.), "the rudder went the other way")
+	assert.False(t, rudderStalled(0., 0., .5), "rotation too small to be checked")
+}
+
+type fakeActionner struct {
+	mu      sync.Mutex
+	enabled bool
+	moves   int
+	err     error // returned by Move
+}
+
+func (a *fakeActionner) Enable() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.enabled = true
+	return nil
+}
+
+func (a *fakeActionner) Disable() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.enabled = false
+	return nil
+}
+
+func (a *fakeActionner) isEnabled() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.enabled
+}
+
+func (a *fakeActionner) Move(clockwise bool, stepsBySecond uint32, duration time.Duration) error {
+	a.mu.Lock()
+	a.moves++
+	a.mu.Unlock()
+	time.Sleep(duration)
+	return a.err
+}
+
+func (a *fakeActionner) StepsPerRevolution() uint32 {
+	return 200
+}
+
+type fakeCurrentSensor float64
+
+func (s fakeCurrentSensor) Current() (float64, error) {
+	return float64(s), nil
+}
+
+type fakeRudderSensor struct {
+	angles []float64
+}
+
+func (s *fakeRudderSensor) RudderAngle() (float64, error) {
+	if len(s.angles) == 0 {
+		return 0, errors.New("no more angle")
+	}
+	angle := s.angles[0]
+	s.angles = s.angles[1:]
+	return angle, nil
+}
+
+func TestThatAnOvercurrentDisablesTheSteeringUntilItIsDisengaged(t *testing.T) {
+	actionner := &fakeActionner{}
+	errorChan := make(chan interface{}, 1)
+
+	s := New(actionner)
+	s.SetErrorChan(errorChan)
+	s.SetCurrentSensor(fakeCurrentSensor(4.), 3., 2., 300*time.Millisecond)
+
+	s.processMessage(message{rotationInDegree: 180., stayEnabled: true})
+	assert.Equal(t, Fault{Err: ErrOvercurrent}, <-errorChan, "the pilot is told")
+	assert.False(t, actionner.isEnabled(), "the motor is disabled")
+
+	s.processMessage(message{rotationInDegree: 180., stayEnabled: true})
+	assert.Equal(t, 1, actionner.moves, "no move until the steering is disengaged")
+	assert.False(t, actionner.isEnabled(), "the motor stays disabled")
+
+	s.processMessage(message{rotationInDegree: 0., stayEnabled: false})
+	s.SetCurrentSensor(fakeCurrentSensor(1.), 3., 2., 300*time.Millisecond)
+	s.processMessage(message{rotationInDegree: 18., stayEnabled: true})
+	assert.Equal(t, 2, actionner.moves, "moving again")
+	assert.True(t, actionner.isEnabled(), "the motor is enabled")
+}
+
+func TestThatARudderNotFollowingIsAStall(t *testing.T) {
+	actionner := &fakeActionner{}
+	errorChan := make(chan interface{}, 1)
+
+	s := New(actionner)
+	s.SetErrorChan(errorChan)
+	s.SetRudderSensor(&fakeRudderSensor{angles: []float64{0., 9., 9., 9.}}, 10.)
+
+	s.processMessage(message{rotationInDegree: 100., stayEnabled: true})
+	assert.True(t, actionner.isEnabled(), "the rudder followed")
+
+	s.processMessage(message{rotationInDegree: 100., stayEnabled: true})
+	assert.Equal(t, Fault{Err: ErrStalled}, <-errorChan, "the pilot is told")
+	assert.False(t, actionner.isEnabled(), "the motor is disabled")
+}
+
+func TestThatAStallReportedByTheActionnerIsAFault(t *testing.T) {
+	actionner := &fakeActionner{err: ErrStalled}
+	errorChan := make(chan interface{}, 1)
+
+	s := New(actionner)
+	s.SetErrorChan(errorChan)
+
+	s.processMessage(message{rotationInDegree: 10., stayEnabled: true})
+	assert.Equal(t, Fault{Err: ErrStalled}, <-errorChan, "the pilot is told")
+	assert.False(t, actionner.isEnabled(), "the motor is disabled")
+
+	s.processMessage(message{rotationInDegree: 10., stayEnabled: true})
+	assert.Equal(t, 1, actionner.moves, "no move until the steering is disengaged")
+}
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-21 17:40:00
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-21 12:31:39
+* @Last Modified time: 2015-12-03 19:27:55
  */
 
 package steering
@@ -33,17 +33,31 @@ import (
 
 var log = logger.Log("steering")
 
-const (
-	numberOfSteps                 = 200
-	rotationSpeedInStepPerSeconds = 2 * numberOfSteps // aka 2 rotation per second
-)
+// rotationSpeedInRevolutionPerSeconds is the speed of the motor
+const rotationSpeedInRevolutionPerSeconds = 2
 
 // Steering is the component driving the steering wheel through an Actionner
 type Steering struct {
 	actionner Actionner
 
+	// feedback
+	currentSensor  CurrentSensor
+	overcurrent    float64
+	stallCurrent   float64
+	stallTimeout   time.Duration
+	rudderSensor   RudderSensor
+	reductionRatio float64
+	faulted        bool // the actionner stays disabled until the pilot disengages the steering
+
+	// clutch
+	clutch       types.Enablable
+	engageDelay  time.Duration
+	releaseDelay time.Duration
+	engaged      bool
+
 	// channels
 	inputChan    chan interface{}
+	errorChan    chan interface{}
 	shutdownChan chan interface{}
 	panicChan    chan interface{}
 }
@@ -52,6 +66,7 @@ type Steering struct {
 type Actionner interface {
 	types.Enablable
 	Move(clockwise bool, speedInStepBySeconds uint32, duration time.Duration) error
+	StepsPerRevolution() uint32
 }
 
 // New creates a new Steering component for a Actionner
@@ -79,36 +94,130 @@ func (m *Steering) SetPanicChan(c chan interface{}) {
 	m.panicChan = c
 }
 
-func rotationInDegreeToMove(rotationInDegree float64) (clockwise bool, speed uint32, duration time.Duration) {
+// SetErrorChan sets the channel where the faults of the motor are sent - the input channel of the pilot
+func (m *Steering) SetErrorChan(c chan interface{}) {
+	m.errorChan = c
+}
+
+// SetCurrentSensor sets the sensor of the current drawn by the actionner - the actionner is disabled as soon as the current
+// reaches overcurrent, or when it stays above stallCurrent for stallTimeout during a move
+func (m *Steering) SetCurrentSensor(sensor CurrentSensor, overcurrent, stallCurrent float64, stallTimeout time.Duration) {
+	m.currentSensor = sensor
+	m.overcurrent = overcurrent
+	m.stallCurrent = stallCurrent
+	m.stallTimeout = stallTimeout
+}
+
+// SetRudderSensor sets the sensor of the angle of the rudder - reductionRatio is the rotation of the motor for one degree of
+// rudder. The actionner is disabled when the rudder does not follow a move.
+func (m *Steering) SetRudderSensor(sensor RudderSensor, reductionRatio float64) {
+	m.rudderSensor = sensor
+	m.reductionRatio = reductionRatio
+}
+
+func rotationInDegreeToMove(rotationInDegree float64, stepsPerRevolution uint32) (clockwise bool, speed uint32, duration time.Duration) {
 	clockwise = rotationInDegree > 0.
-	speed = uint32(rotationSpeedInStepPerSeconds)
+	speed = rotationSpeedInRevolutionPerSeconds * stepsPerRevolution
 	duration = time.Duration(
-		math.Abs(rotationInDegree/360.*numberOfSteps/rotationSpeedInStepPerSeconds) * float64(time.Second))
+		math.Abs(rotationInDegree/360.*float64(stepsPerRevolution)/float64(speed)) * float64(time.Second))
 
 	return
 }
 
+// move rotates the motor while watching the sensors - it returns the fault detected if any
+func (m *Steering) move(rotationInDegree float64) error {
+	clockwise, speed, duration := rotationInDegreeToMove(rotationInDegree, m.actionner.StepsPerRevolution())
+
+	var rudderBefore float64
+	var rudderErr error
+	if m.rudderSensor != nil {
+		if rudderBefore, rudderErr = m.rudderSensor.RudderAngle(); rudderErr != nil {
+			log.Warning("Failed to read the rudder angle: %v", rudderErr)
+		}
+	}
+
+	var done chan interface{}
+	var result chan error
+	if m.currentSensor != nil {
+		done = make(chan interface{})
+		result = make(chan error, 1)
+		go m.monitor(done, result)
+	}
+
+	err := m.actionner.Move(clockwise, speed, duration)
+
+	var fault error
+	if m.currentSensor != nil {
+		close(done)
+		fault = <-result
+	}
+
+	switch {
+	case err == ErrStalled:
+		// the actionner noticed it by itself
+		if fault == nil {
+			log.Error("%v during the move - disabling the motor", err)
+			m.actionner.Disable()
+			fault = err
+		}
+	case err != nil:
+		log.Panicf("Failed to move [clockwise=%v] for %v at %v: %v", clockwise, duration, speed, err)
+	}
+
+	if fault == nil && m.rudderSensor != nil && rudderErr == nil {
+		rudderAfter, err := m.rudderSensor.RudderAngle()
+		if err != nil {
+			log.Warning("Failed to read the rudder angle: %v", err)
+		} else if rudderStalled(rudderBefore, rudderAfter, rotationInDegree/m.reductionRatio) {
+			log.Error("%v: the rudder went from %v to %v - disabling the motor", ErrStalled, rudderBefore, rudderAfter)
+			m.actionner.Disable()
+			fault = ErrStalled
+		}
+	}
+
+	return fault
+}
+
+// reportFault tells the pilot about a fault - without blocking as the pilot might be sending us an order
+func (m *Steering) reportFault(err error) {
+	if m.errorChan == nil {
+		return
+	}
+	go func() {
+		m.errorChan <- Fault{Err: err}
+	}()
+}
+
 func (m *Steering) processSteeringState(msg message) {
 
 	rotationInDegree := msg.rotationInDegree
 
 	if !msg.stayEnabled {
-		defer m.actionner.Disable()
+		m.faulted = false
+		defer m.disengage()
+	}
+
+	if m.faulted {
+		// stay disabled until the pilot disengages the steering
+		return
+	}
+
+	if msg.stayEnabled {
+		m.engageClutch()
 	}
 
 	if rotationInDegree != 0. {
 		m.actionner.Enable()
-		clockwise, speed, duration := rotationInDegreeToMove(rotationInDegree)
 
-		err := m.actionner.Move(clockwise, speed, duration)
-		if err != nil {
-			log.Panicf("Failed to move [clockwise=%v] for %v at %v", clockwise, duration, speed)
+		if fault := m.move(rotationInDegree); fault != nil {
+			m.faulted = true
+			m.releaseClutch()
+			m.reportFault(fault)
 		}
-
 	}
 }
 
-func (m Steering) processMessage(msg message) {
+func (m *Steering) processMessage(msg message) {
 	// no state to update
 
 	// move
@@ -123,7 +232,7 @@ func (m Steering) Shutdown() {
 
 func (m Steering) shutdown() {
 	// disable the steering -- should not be Enabled()
-	m.actionner.Disable()
+	m.disengage()
 	close(m.shutdownChan)
 }
 
@@ -18,7 +18,7 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-09-21 18:47:13
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-09-24 17:25:31
+* @Last Modified time: 2015-11-27 22:08:45
  */
 
 package steering
@@ -31,30 +31,40 @@ import (
 )
 
 func TestThatRotationDirectionIsClockwise(t *testing.T) {
-	clockwise, _, _ := rotationInDegreeToMove(12)
+	clockwise, _, _ := rotationInDegreeToMove(12, 200)
 
 	assert.EqualValues(t, true, clockwise, "supposed to be clockwise")
 
-	clockwise, _, _ = rotationInDegreeToMove(-12)
+	clockwise, _, _ = rotationInDegreeToMove(-12, 200)
 
 	assert.EqualValues(t, false, clockwise, "supposed to be anticlockwise")
 
 }
 
 func TestThatSpeedIsConstant(t *testing.T) {
-	_, speed1, _ := rotationInDegreeToMove(12)
+	_, speed1, _ := rotationInDegreeToMove(12, 200)
 
-	_, speed2, _ := rotationInDegreeToMove(140)
+	_, speed2, _ := rotationInDegreeToMove(140, 200)
 
 	assert.EqualValues(t, speed1, speed2, "speed is contant")
 
 }
 
 func TestThatNothingMovesAtNullSpeed(t *testing.T) {
-	clockwise, _, duration := rotationInDegreeToMove(0)
+	clockwise, _, duration := rotationInDegreeToMove(0, 200)
 
 	assert.EqualValues(t, false, clockwise, "supposed to be clockwise")
 
 	assert.EqualValues(t, time.Duration(0), duration, "supposed to be clockwise")
 
 }
+
+func TestThatTheDurationDependsOnTheAngleOnly(t *testing.T) {
+	_, speed, duration := rotationInDegreeToMove(180, 200)
+	assert.EqualValues(t, 400, speed)
+	assert.Equal(t, 250*time.Millisecond, duration)
+
+	_, speed, duration = rotationInDegreeToMove(180, 200*16)
+	assert.EqualValues(t, 6400, speed, "microsteps are faster")
+	assert.Equal(t, 250*time.Millisecond, duration, "but the rotation is the same")
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-09 23:16:05
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-09 23:16:05
+ */
+
+package tracer
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+// Export formats of a track
+const (
+	GPX     = "gpx"
+	KML     = "kml"
+	GeoJSON = "geojson"
+)
+
+// ContentTypes are the MIME types of the export formats
+var ContentTypes = map[string]string{
+	GPX:     "application/gpx+xml",
+	KML:     "application/vnd.google-earth.kml+xml",
+	GeoJSON: "application/geo+json",
+}
+
+const trackName = "Edison is the pilot"
+
+// Export writes the points in one of the export formats
+func Export(w io.Writer, format string, points []types.Point) error {
+	switch format {
+	case GPX:
+		return WriteGPX(w, points)
+	case KML:
+		return WriteKML(w, points)
+	case GeoJSON:
+		return WriteGeoJSON(w, points)
+	}
+	return fmt.Errorf("unknown track format: %v", format)
+}
+
+type gpxPoint struct {
+	Latitude  float64 `xml:"lat,attr"`
+	Longitude float64 `xml:"lon,attr"`
+	Time      string  `xml:"time"`
+}
+
+type gpx struct {
+	XMLName xml.Name   `xml:"gpx"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	Version string     `xml:"version,attr"`
+	Creator string     `xml:"creator,attr"`
+	Name    string     `xml:"trk>name"`
+	Points  []gpxPoint `xml:"trk>trkseg>trkpt"`
+}
+
+// WriteGPX writes the points as a GPX 1.1 track
+func WriteGPX(w io.Writer, points []types.Point) error {
+	doc := gpx{
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Version: "1.1",
+		Creator: trackName,
+		Name:    trackName,
+		Points:  make([]gpxPoint, len(points)),
+	}
+	for i, p := range points {
+		doc.Points[i] = gpxPoint{
+			Latitude:  p.Latitude,
+			Longitude: p.Longitude,
+			Time:      time.Time(p.Time).UTC().Format(time.RFC3339),
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
+
+type kml struct {
+	XMLName     xml.Name `xml:"kml"`
+	Xmlns       string   `xml:"xmlns,attr"`
+	Name        string   `xml:"Document>Placemark>name"`
+	Tessellate  int      `xml:"Document>Placemark>LineString>tessellate"`
+	Coordinates string   `xml:"Document>Placemark>LineString>coordinates"`
+}
+
+// WriteKML writes the points as a KML 2.2 line string
+func WriteKML(w io.Writer, points []types.Point) error {
+	coordinates := make([]string, len(points))
+	for i, p := range points {
+		coordinates[i] = fmt.Sprintf("%v,%v", p.Longitude, p.Latitude)
+	}
+
+	doc := kml{
+		Xmlns:       "http://www.opengis.net/kml/2.2",
+		Name:        trackName,
+		Tessellate:  1,
+		Coordinates: strings.Join(coordinates, " "),
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(doc)
+}
+
+type geoJSONGeometry struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// WriteGeoJSON writes the points as a GeoJSON feature with a LineString geometry.
+// The times of the points are in the "times" property (seconds since epoch).
+func WriteGeoJSON(w io.Writer, points []types.Point) error {
+	coordinates := make([][2]float64, len(points))
+	times := make([]types.JSONTime, len(points))
+	for i, p := range points {
+		coordinates[i] = [2]float64{p.Longitude, p.Latitude}
+		times[i] = p.Time
+	}
+
+	return json.NewEncoder(w).Encode(geoJSONFeature{
+		Type:       "Feature",
+		Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: coordinates},
+		Properties: map[string]interface{}{"name": trackName, "times": times},
+	})
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-09 23:16:05
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-11-09 23:16:05
+ */
+
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+var _ = Describe("export", func() {
+
+	var (
+		points []types.Point
+		buffer *bytes.Buffer
+	)
+
+	BeforeEach(func() {
+		buffer = &bytes.Buffer{}
+		start := time.Date(2015, time.November, 9, 10, 0, 0, 0, time.UTC)
+		points = []types.Point{
+			{Latitude: 45.5, Longitude: 4.25, Time: types.JSONTime(start)},
+			{Latitude: 45.75, Longitude: 4.5, Time: types.JSONTime(start.Add(time.Minute))},
+		}
+	})
+
+	It("writes GPX", func() {
+		Expect(Export(buffer, GPX, points)).To(Succeed())
+
+		var doc gpx
+		Expect(xml.Unmarshal(buffer.Bytes(), &doc)).To(Succeed())
+		Expect(doc.Points).To(Equal([]gpxPoint{
+			{Latitude: 45.5, Longitude: 4.25, Time: "2015-11-09T10:00:00Z"},
+			{Latitude: 45.75, Longitude: 4.5, Time: "2015-11-09T10:01:00Z"},
+		}))
+	})
+
+	It("writes KML", func() {
+		Expect(Export(buffer, KML, points)).To(Succeed())
+
+		var doc kml
+		Expect(xml.Unmarshal(buffer.Bytes(), &doc)).To(Succeed())
+		Expect(doc.Coordinates).To(Equal("4.25,45.5 4.5,45.75"))
+	})
+
+	It("writes GeoJSON", func() {
+		Expect(Export(buffer, GeoJSON, points)).To(Succeed())
+
+		var doc geoJSONFeature
+		Expect(json.Unmarshal(buffer.Bytes(), &doc)).To(Succeed())
+		Expect(doc.Geometry.Type).To(Equal("LineString"))
+		Expect(doc.Geometry.Coordinates).To(Equal([][2]float64{{4.25, 45.5}, {4.5, 45.75}}))
+	})
+
+	It("rejects unknown formats", func() {
+		Expect(Export(buffer, "csv", points)).NotTo(Succeed())
+	})
+
+})
@@ -0,0 +1,137 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-11 21:32:18
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-13 16:05:31
+ */
+
+package tracer
+
+import (
+	"math"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/geo"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+// Filter describes how the points are reduced before being returned to a client
+type Filter struct {
+	// Since only keeps the points of a second strictly more recent than this time - zero to keep them all
+	Since time.Time
+	// Bucket only keeps the most recent point of each time bucket of this duration - zero to keep them all
+	Bucket time.Duration
+	// Tolerance in meters for the Douglas-Peucker simplification - zero to keep them all
+	Tolerance float64
+}
+
+// Apply returns a new slice with the points selected by the Filter, applying Since, Bucket and then Tolerance
+func (f Filter) Apply(points []types.Point) []types.Point {
+	return Simplify(Downsample(Since(points, f.Since), f.Bucket), f.Tolerance)
+}
+
+// Since returns the points of a second strictly more recent than the one of since.
+//
+// The times of the points are serialized with a resolution of one second: the time of the last point received
+// by a client comes back truncated as the cursor of the next fetch, hence the comparison on whole seconds.
+func Since(points []types.Point, since time.Time) []types.Point {
+	since = since.Truncate(time.Second)
+
+	output := []types.Point{}
+	for _, point := range points {
+		if time.Time(point.Time).Truncate(time.Second).After(since) {
+			output = append(output, point)
+		}
+	}
+	return output
+}
+
+// Downsample keeps the most recent point of each time bucket. The points are expected to be ordered by time.
+func Downsample(points []types.Point, bucket time.Duration) []types.Point {
+	if bucket <= 0 {
+		return append([]types.Point{}, points...)
+	}
+
+	output := []types.Point{}
+	for i, point := range points {
+		current := time.Time(point.Time).Truncate(bucket)
+		if i+1 < len(points) && time.Time(points[i+1].Time).Truncate(bucket).Equal(current) {
+			continue
+		}
+		output = append(output, point)
+	}
+	return output
+}
+
+// Simplify reduces the number of points of the track with the Douglas-Peucker algorithm: the removed points are
+// within tolerance meters of the simplified track. The first and last points are always kept.
+func Simplify(points []types.Point, tolerance float64) []types.Point {
+	if tolerance <= 0 || len(points) < 3 {
+		return append([]types.Point{}, points...)
+	}
+
+	keep := make([]bool, len(points))
+	keep[0] = true
+	keep[len(points)-1] = true
+
+	// explicit stack of [first:last] ranges rather than recursion - the trace can be long
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		farthest, maxDistance := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			d := segmentDistance(points[i], points[first], points[last])
+			if d > maxDistance {
+				farthest, maxDistance = i, d
+			}
+		}
+
+		if farthest != -1 {
+			keep[farthest] = true
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+
+	output := []types.Point{}
+	for i, point := range points {
+		if keep[i] {
+			output = append(output, point)
+		}
+	}
+	return output
+}
+
+// segmentDistance is the distance in meters from position to the great-circle segment [start:end]
+func segmentDistance(position, start, end types.Point) float64 {
+	length := geo.Distance(start, end)
+	if length == 0 {
+		return geo.Distance(position, start)
+	}
+
+	along := geo.AlongTrackDistance(position, start, end)
+	switch {
+	case along < 0:
+		return geo.Distance(position, start)
+	case along > length:
+		return geo.Distance(position, end)
+	}
+	return math.Abs(geo.CrossTrackDistance(position, start, end))
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-11 21:32:18
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-13 16:05:31
+ */
+
+package tracer
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/geo"
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+var _ = Describe("simplify", func() {
+
+	var (
+		start time.Time
+	)
+
+	BeforeEach(func() {
+		start = time.Date(2015, time.November, 11, 12, 0, 0, 0, time.UTC)
+	})
+
+	// line builds a track going north with one point per second, every 10 meters
+	line := func(n int) []types.Point {
+		points := []types.Point{}
+		origin := types.Point{Latitude: 45., Longitude: 4.}
+		for i := 0; i < n; i++ {
+			p := geo.Destination(origin, 0, float64(i)*10.)
+			p.Time = types.JSONTime(start.Add(time.Duration(i) * time.Second))
+			points = append(points, p)
+		}
+		return points
+	}
+
+	Describe("Since", func() {
+		It("only keeps the points strictly more recent", func() {
+			points := line(10)
+			Expect(Since(points, start.Add(6*time.Second))).To(Equal(points[7:]))
+		})
+
+		It("keeps all the points with a zero time", func() {
+			points := line(10)
+			Expect(Since(points, time.Time{})).To(Equal(points))
+		})
+
+		It("does not return the last point again when its time is fed back as the cursor", func() {
+			start = start.Add(400 * time.Millisecond)
+			points := line(10)
+
+			fetched := Since(points[:5], time.Time{})
+			Expect(fetched).To(HaveLen(5))
+
+			// the client only gets the time in seconds
+			data, err := fetched[4].Time.MarshalJSON()
+			Expect(err).NotTo(HaveOccurred())
+			var cursor types.JSONTime
+			Expect(cursor.UnmarshalJSON(data)).To(Succeed())
+
+			Expect(Since(points[:5], time.Time(cursor))).To(BeEmpty())
+			Expect(Since(points, time.Time(cursor))).To(Equal(points[5:]))
+		})
+	})
+
+	Describe("Downsample", func() {
+		It("keeps the most recent point of each bucket", func() {
+			points := line(25)
+			Expect(Downsample(points, 10*time.Second)).To(Equal([]types.Point{points[9], points[19], points[24]}))
+		})
+
+		It("keeps all the points without bucket", func() {
+			points := line(5)
+			Expect(Downsample(points, 0)).To(Equal(points))
+		})
+	})
+
+	Describe("Simplify", func() {
+		It("reduces a straight line to its ends", func() {
+			points := line(50)
+			Expect(Simplify(points, 1.)).To(Equal([]types.Point{points[0], points[49]}))
+		})
+
+		It("keeps the corners", func() {
+			points := line(11)
+			corner := points[10]
+			for i := 1; i <= 10; i++ {
+				p := geo.Destination(corner, 90, float64(i)*10.)
+				p.Time = types.JSONTime(start.Add(time.Duration(10+i) * time.Second))
+				points = append(points, p)
+			}
+
+			Expect(Simplify(points, 1.)).To(Equal([]types.Point{points[0], points[10], points[20]}))
+		})
+
+		It("ignores the deviations within the tolerance", func() {
+			points := line(3)
+			points[1] = geo.Destination(points[1], 90, 4.)
+
+			Expect(Simplify(points, 5.)).To(HaveLen(2))
+			Expect(Simplify(points, 3.)).To(HaveLen(3))
+		})
+
+		It("keeps all the points without tolerance", func() {
+			points := line(10)
+			Expect(Simplify(points, 0)).To(Equal(points))
+		})
+	})
+
+	Describe("Filter", func() {
+		It("does not return the tracer storage", func() {
+			points := line(3)
+			latitude := points[0].Latitude
+			filtered := Filter{}.Apply(points)
+			filtered[0].Latitude = 0
+
+			Expect(points[0].Latitude).To(Equal(latitude))
+		})
+
+		It("applies since, bucket and tolerance", func() {
+			points := line(30)
+			filter := Filter{Since: start.Add(4 * time.Second), Bucket: 5 * time.Second, Tolerance: 1.}
+
+			Expect(filter.Apply(points)).To(Equal([]types.Point{points[9], points[29]}))
+		})
+	})
+})
@@ -0,0 +1,208 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-09 23:16:05
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-11 21:18:02
+ */
+
+package tracer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+const trackFilePrefix = "track-"
+const trackFileSuffix = ".jsonl"
+
+// Store is an append-only on-disk store of points.
+//
+// Points are written as one JSON object per line in files named track-YYYYMMDD-NNN.jsonl.
+// A new file is started every day (UTC) and when the current one grows beyond maxFileSize bytes.
+// Query can be called from any goroutine while the points are appended: the files are append-only and
+// a line being written is skipped like a truncated one.
+type Store struct {
+	directory   string
+	maxFileSize int64
+
+	mu   sync.Mutex // protects the current file
+	file *os.File
+	day  string
+	size int64
+}
+
+// NewStore creates a Store writing in directory - which is created if needed
+func NewStore(directory string, maxFileSize int64) (*Store, error) {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{directory: directory, maxFileSize: maxFileSize}, nil
+}
+
+// files returns the track files of the store sorted by name (and thus chronologically)
+func (s *Store) files() ([]string, error) {
+	infos, err := ioutil.ReadDir(s.directory)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, info := range infos {
+		name := info.Name()
+		if !info.IsDir() && strings.HasPrefix(name, trackFilePrefix) && strings.HasSuffix(name, trackFileSuffix) {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// rotate closes the current file and opens the next one for the day
+func (s *Store) rotate(day string) error {
+	if err := s.close(); err != nil {
+		return err
+	}
+
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+
+	// next sequence number for the day
+	sequence := 0
+	prefix := trackFilePrefix + day + "-"
+	for _, name := range files {
+		if strings.HasPrefix(name, prefix) {
+			var n int
+			if _, err := fmt.Sscanf(strings.TrimPrefix(name, prefix), "%03d", &n); err == nil && n >= sequence {
+				sequence = n + 1
+			}
+		}
+	}
+
+	name := filepath.Join(s.directory, fmt.Sprintf("%s%s-%03d%s", trackFilePrefix, day, sequence, trackFileSuffix))
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Writing the track to %s", name)
+	s.file = file
+	s.day = day
+	s.size = 0
+	return nil
+}
+
+// Append writes a point to the store
+func (s *Store) Append(point types.Point) error {
+	data, err := json.Marshal(point)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	day := time.Time(point.Time).UTC().Format("20060102")
+	if s.file == nil || day != s.day || (s.maxFileSize > 0 && s.size+int64(len(data)) > s.maxFileSize) {
+		if err = s.rotate(day); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	return err
+}
+
+// Query returns the points of the store with a time in [from:to]
+func (s *Store) Query(from, to time.Time) ([]types.Point, error) {
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+
+	points := []types.Point{}
+	first, last := from.UTC().Format("20060102"), to.UTC().Format("20060102")
+	for _, name := range files {
+		// skip the files of the days out of the range
+		day := strings.SplitN(strings.TrimPrefix(name, trackFilePrefix), "-", 2)[0]
+		if day < first || day > last {
+			continue
+		}
+
+		points, err = s.read(filepath.Join(s.directory, name), from, to, points)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+func (s *Store) read(name string, from, to time.Time, points []types.Point) ([]types.Point, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return points, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var point types.Point
+		if err := json.Unmarshal(scanner.Bytes(), &point); err != nil {
+			// a truncated line after a power loss - keep going
+			log.Warning("Skipping invalid line in %s: %v", name, err)
+			continue
+		}
+
+		t := time.Time(point.Time)
+		if !t.Before(from) && !t.After(to) {
+			points = append(points, point)
+		}
+	}
+	return points, scanner.Err()
+}
+
+// Close closes the current file of the store
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.close()
+}
+
+func (s *Store) close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2015 Sebastien Soudan
+
+Licensed under the Apache License, Version 2.0 (the "License"); you may not
+use this file except in compliance with the License. You may obtain a copy
+of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+License for the specific language governing permissions and limitations
+under the License.
+*/
+
+/*
+* @Author: Sebastien Soudan
+* @Date:   2015-11-09 23:16:05
+* @Last Modified by:   Sebastien Soudan
+* @Last Modified time: 2015-12-11 21:18:02
+ */
+
+package tracer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
+)
+
+var _ = Describe("store", func() {
+
+	var (
+		directory string
+		store     *Store
+		day       time.Time
+	)
+
+	mkPoint := func(t time.Time, latitude float64) types.Point {
+		return types.Point{Latitude: latitude, Longitude: 4., Time: types.JSONTime(t)}
+	}
+
+	files := func() []string {
+		names, err := filepath.Glob(filepath.Join(directory, "track-*.jsonl"))
+		Expect(err).NotTo(HaveOccurred())
+		return names
+	}
+
+	BeforeEach(func() {
+		var err error
+		directory, err = ioutil.TempDir("", "tracer")
+		Expect(err).NotTo(HaveOccurred())
+
+		store, err = NewStore(directory, 1024)
+		Expect(err).NotTo(HaveOccurred())
+
+		day = time.Date(2015, time.November, 9, 10, 0, 0, 0, time.UTC)
+	})
+
+	AfterEach(func() {
+		store.Close()
+		os.RemoveAll(directory)
+	})
+
+	It("is empty at first", func() {
+		Expect(store.Query(day.Add(-time.Hour), day.Add(time.Hour))).To(Equal([]types.Point{}))
+	})
+
+	It("returns the points within the time range", func() {
+		points := []types.Point{}
+		for i := 0; i < 5; i++ {
+			p := mkPoint(day.Add(time.Duration(i)*time.Minute), 45.+float64(i))
+			Expect(store.Append(p)).To(Succeed())
+			points = append(points, p)
+		}
+
+		track, err := store.Query(day.Add(time.Minute), day.Add(3*time.Minute))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(track).To(HaveLen(3))
+		Expect(track[0].Latitude).To(Equal(points[1].Latitude))
+		Expect(time.Time(track[0].Time).Unix()).To(Equal(time.Time(points[1].Time).Unix()))
+		Expect(track[2].Latitude).To(Equal(points[3].Latitude))
+	})
+
+	It("rotates every day", func() {
+		Expect(store.Append(mkPoint(day, 45.))).To(Succeed())
+		Expect(store.Append(mkPoint(day.Add(24*time.Hour), 46.))).To(Succeed())
+
+		Expect(files()).To(HaveLen(2))
+
+		track, err := store.Query(day.Add(12*time.Hour), day.Add(36*time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(track).To(HaveLen(1))
+		Expect(track[0].Latitude).To(Equal(46.))
+	})
+
+	It("rotates when the file is too large", func() {
+		for i := 0; i < 50; i++ {
+			Expect(store.Append(mkPoint(day.Add(time.Duration(i)*time.Second), 45.))).To(Succeed())
+		}
+
+		Expect(len(files())).To(BeNumerically(">", 1))
+
+		track, err := store.Query(day, day.Add(time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(track).To(HaveLen(50))
+	})
+
+	It("keeps the previous files when reopened", func() {
+		Expect(store.Append(mkPoint(day, 45.))).To(Succeed())
+		Expect(store.Close()).To(Succeed())
+
+		var err error
+		store, err = NewStore(directory, 1024)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(store.Append(mkPoint(day.Add(time.Minute), 46.))).To(Succeed())
+
+		Expect(files()).To(HaveLen(2))
+		Expect(store.Query(day, day.Add(time.Hour))).To(HaveLen(2))
+	})
+
+	It("is used by the tracer", func() {
+		c := make(chan interface{})
+		tracer := New(3)
+		tracer.SetInputChan(c)
+		tracer.SetStore(store)
+		tracer.Start()
+
+		c <- MkAddPointMessage(mkPoint(day, 45.))
+
+		Eventually(func() int {
+			track, _ := tracer.GetTrack(day.Add(-time.Hour), day.Add(time.Hour))
+			return len(track)
+		}).Should(Equal(1))
+	})
+
+	It("is queried without going through the event loop of the tracer", func() {
+		Expect(store.Append(mkPoint(day, 45.))).To(Succeed())
+
+		// the event loop is not started: a query through it would block forever
+		tracer := New(3)
+		tracer.SetInputChan(make(chan interface{}))
+		tracer.SetStore(store)
+
+		Expect(tracer.GetTrack(day.Add(-time.Hour), day.Add(time.Hour))).To(HaveLen(1))
+	})
+
+	It("rejects the time ranges wider than the maximum", func() {
+		tracer := New(3)
+		tracer.SetStore(store)
+		tracer.SetMaxRange(24 * time.Hour)
+
+		_, err := tracer.GetTrack(day, day.Add(25*time.Hour))
+		Expect(err).To(Equal(ErrRangeTooLarge))
+
+		_, err = tracer.GetTrack(day, day.Add(24*time.Hour))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+})
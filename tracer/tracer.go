@@ -18,24 +18,36 @@ under the License.
 * @Author: Sebastien Soudan
 * @Date:   2015-10-21 15:37:36
 * @Last Modified by:   Sebastien Soudan
-* @Last Modified time: 2015-10-22 18:10:06
+* @Last Modified time: 2015-11-11 21:32:18
  */
 
 package tracer
 
 import (
+	"errors"
+	"time"
+
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/logger"
 	"github.com/ssoudan/edisonIsThePilot/infrastructure/types"
 )
 
 var log = logger.Log("tracer")
 
+// InputBufferSize is the suggested capacity of the input channel of the Tracer - the pilot drops the points
+// rather than waiting when it is full
+const InputBufferSize = 32
+
+// ErrRangeTooLarge is returned by GetTrack when the time range is wider than the one set with SetMaxRange
+var ErrRangeTooLarge = errors.New("track time range is too large")
+
 // Tracer is the component that stores the recent position history
 type Tracer struct {
 	maxPoints        uint32
 	nextPosition     uint32
 	points           []types.Point
 	fullyInitialized bool
+	store            *Store
+	maxRange         time.Duration
 
 	// Channels
 	inputChan    chan interface{}
@@ -57,6 +69,16 @@ func (t *Tracer) SetInputChan(inputChan chan interface{}) {
 	t.inputChan = inputChan
 }
 
+// SetStore sets the Store where the points are persisted
+func (t *Tracer) SetStore(store *Store) {
+	t.store = store
+}
+
+// SetMaxRange sets the widest time range accepted by GetTrack - 0 for no limit
+func (t *Tracer) SetMaxRange(maxRange time.Duration) {
+	t.maxRange = maxRange
+}
+
 // SetPanicChan sets the channel where panics are sent
 func (t *Tracer) SetPanicChan(panicChan chan interface{}) {
 	t.panicChan = panicChan
@@ -71,6 +93,11 @@ func (t *Tracer) Shutdown() {
 
 func (t *Tracer) shutdown() {
 
+	if t.store != nil {
+		if err := t.store.Close(); err != nil {
+			log.Error("Failed to close the track store: %v", err)
+		}
+	}
 	close(t.shutdownChan)
 }
 
@@ -85,6 +112,13 @@ func (t *Tracer) processPoint(point types.Point) {
 		}
 	}
 
+	if t.store != nil {
+		// losing the track is not a reason to stop steering
+		if err := t.store.Append(point); err != nil {
+			log.Error("Failed to persist point: %v", err)
+		}
+	}
+
 }
 
 // MkAddPointMessage creates a new action message to add a Point to the trace
@@ -107,6 +141,60 @@ func (t *Tracer) GetPoints() []types.Point {
 	return <-c
 }
 
+type getFilteredPointsMessage struct {
+	filter Filter
+	c      chan []types.Point
+}
+
+// GetFilteredPoints returns the Point in the trace selected by the Filter
+func (t *Tracer) GetFilteredPoints(filter Filter) []types.Point {
+	c := make(chan []types.Point)
+	t.inputChan <- getFilteredPointsMessage{filter: filter, c: c}
+	return <-c
+}
+
+type getTrackMessage struct {
+	from time.Time
+	to   time.Time
+	c    chan []types.Point
+}
+
+// GetTrack returns the persisted points with a time in [from:to] - or the ones in memory when there is no Store.
+//
+// The Store is queried from the calling goroutine so that reading days of track does not hold the event loop.
+func (t *Tracer) GetTrack(from, to time.Time) ([]types.Point, error) {
+	if t.maxRange > 0 && to.Sub(from) > t.maxRange {
+		return nil, ErrRangeTooLarge
+	}
+
+	if t.store != nil {
+		return t.store.Query(from, to)
+	}
+
+	c := make(chan []types.Point)
+	t.inputChan <- getTrackMessage{from: from, to: to, c: c}
+	return <-c, nil
+}
+
+func (t *Tracer) getTrack(from, to time.Time) []types.Point {
+	points := []types.Point{}
+	for _, point := range t.orderedPoints() {
+		pt := time.Time(point.Time)
+		if !pt.Before(from) && !pt.After(to) {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+// orderedPoints returns the points in memory from the oldest to the most recent
+func (t *Tracer) orderedPoints() []types.Point {
+	if !t.fullyInitialized {
+		return t.points[:t.nextPosition]
+	}
+	return append(append([]types.Point{}, t.points[t.nextPosition:]...), t.points[:t.nextPosition]...)
+}
+
 func (t *Tracer) getPoints() []types.Point {
 	if t.maxPoints == 0 {
 		return []types.Point{}
@@ -141,6 +229,10 @@ func (t *Tracer) Start() {
 					m.c <- t.getPoints()
 				case addPointMessage:
 					t.processPoint(m.point)
+				case getFilteredPointsMessage:
+					m.c <- m.filter.Apply(t.orderedPoints())
+				case getTrackMessage:
+					m.c <- t.getTrack(m.from, m.to)
 				}
 			case <-t.shutdownChan:
 				t.shutdown()